@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Azure/gocover/pkg/dbclient"
@@ -63,6 +67,17 @@ gocover test --coverage-mode diff --compare-branch=origin/master --outputdir /tm
 
 # Run unit tests and generate full coverage result on the whole module.
 gocover test --coverage-mode full --outputdir /tmp
+
+# Run unit tests of all the modules in the repository, 4 modules at the same time, and generate one report.
+gocover test --coverage-mode full --all-modules --parallel 4 --outputdir /tmp
+
+# Run each test with its own cover profile, and show which tests cover the changed lines in the HTML report.
+gocover test --coverage-mode diff --attribution test --format html --outputdir /tmp
+
+# Run unit tests with gotestsum, and collect the cover profile it writes.
+gocover test --coverage-mode full --executor-mode custom --outputdir /tmp \
+	--custom-command 'gotestsum -- -coverprofile={{.OutputDir}}/unit.out -coverpkg=./... ./...' \
+	--custom-cover-profile '{{.OutputDir}}/unit.out'
 `
 )
 
@@ -90,6 +105,33 @@ func createLogger(cmd *cobra.Command) *logrus.Logger {
 	return logger
 }
 
+// newTimeoutContext returns the context that is done when the timeout flag expires or gocover is interrupted,
+// no timeout if the flag is not positive. The test processes run in their own process groups,
+// they don't receive the signals sent to gocover from the terminal, and are killed by the context.
+func newTimeoutContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timeoutInSeconds <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutInSeconds)*time.Second)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// withTimeoutExitCode returns the error with timeout exit code if the timeout flag expires.
+func withTimeoutExitCode(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	var e *gocover.GoCoverError
+	if errors.As(err, &e) && e.ExitCode == gocover.TimeoutErrorExitCode {
+		return err
+	}
+	return gocover.WrapErrorWithCode(fmt.Errorf("timed out after %d seconds: %w", timeoutInSeconds, err), gocover.TimeoutErrorExitCode, "")
+}
+
 // NewGoCoverCommand creates a command object for generating diff coverage reporter.
 func NewGoCoverCommand(version, commit, date string) *cobra.Command {
 
@@ -115,11 +157,13 @@ func NewGoCoverCommand(version, commit, date string) *cobra.Command {
 	cmd.PersistentFlags().StringVar(&dbOption.KustoOption.IgnoreEvent, "ignore-event", "", "kusto event for ignore information")
 	cmd.PersistentFlags().StringVar(&dbOption.KustoOption.ManagedIdentityResouceID, "managed-identity-resource-id", "", "managed identity resource id for auth for kusto")
 	cmd.PersistentFlags().StringSliceVar(&dbOption.KustoOption.CustomColumns, "custom-columns", []string{}, "custom kusto columns, format: {column}:{datatype}:{value}")
-	cmd.PersistentFlags().IntVar(&timeoutInSeconds, "timeout", defaultTimeoutInSeconds, "execute timeout in seconds")
+	cmd.PersistentFlags().IntVar(&timeoutInSeconds, "timeout", defaultTimeoutInSeconds, "execute timeout in seconds, the tests are killed when it expires, 0 means no timeout")
 
 	cmd.AddCommand(newDiffCoverageCommand())
 	cmd.AddCommand(newFullCoverageCommand())
 	cmd.AddCommand(newGoCoverTestCommand())
+	cmd.AddCommand(newMergeCommand())
+	cmd.AddCommand(newWhoCoversCommand())
 	cmd.AddCommand(newVersionCommand(version, commit, date))
 	return cmd
 }
@@ -140,25 +184,34 @@ func newDiffCoverageCommand() *cobra.Command {
 				return fmt.Errorf("NewDiffCover: %w", err)
 			}
 
-			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeoutInSeconds*time.Second)
+			ctx, cancel := newTimeoutContext()
 			defer cancel()
 
 			if err := diff.Run(ctx); err != nil {
-				return fmt.Errorf("generate diff coverage: %w", err)
+				return withTimeoutExitCode(ctx, fmt.Errorf("generate diff coverage: %w", err))
 			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().StringSliceVar(&o.CoverProfiles, "cover-profile", []string{}, `coverage profile produced by 'go test'`)
+	cmd.Flags().StringSliceVar(&o.CoverProfiles, "cover-profile", []string{}, `coverage profile produced by 'go test', or GOCOVERDIR directory written by binaries built with 'go build -cover'`)
+	cmd.Flags().StringSliceVar(&o.CompareCoverProfiles, "compare-cover-profile", []string{}, "coverage profile of the compare branch, the unchanged lines that are covered by it but not covered any more are reported as indirect coverage loss")
 	cmd.Flags().StringVar(&o.CompareBranch, "compare-branch", o.CompareBranch, `branch to compare`)
+	cmd.Flags().BoolVar(&o.IncludeUncommitted, "include-uncommitted", false, "include the staged and unstaged changes of the working tree in diff coverage, untracked files are not included")
+	cmd.Flags().StringVar(&o.DiffFile, "diff-file", "", "unified diff file used instead of comparing with compare-branch, such as the output of 'git diff' or the .diff of a pull request, '-' means reading from stdin")
+	cmd.Flags().StringVar(&o.From, "from", "", "start revision of the diff range, the changes are computed from the merge base of from and to, default is compare-branch")
+	cmd.Flags().StringVar(&o.To, "to", "", "end revision of the diff range, default is HEAD")
+	cmd.Flags().BoolVar(&o.FetchMissing, "fetch-missing", false, "fetch the compared branch and deepen the history with git binary when they are missing, such as in a shallow clone")
 	cmd.Flags().StringVar(&o.RepositoryPath, "repository-path", "./", `the root directory of git repository`)
-	cmd.Flags().StringVar(&o.ModuleDir, "module-dir", "./", "module directory contains go.mod file that relative to the project")
-	cmd.Flags().StringVar(&o.ReportFormat, "format", o.ReportFormat, "format of the diff coverage report, one of: html, json, markdown")
+	cmd.Flags().StringVar(&o.ModuleDir, "module-dir", "./", "module directory contains go.mod file, or go.work file of the workspace, that relative to the project")
+	cmd.Flags().StringVar((*string)(&o.StaleProfile), "stale-profile", string(o.StaleProfile), `how to handle the cover profile that doesn't match the source file, "fail" or "warn"`)
+	cmd.Flags().StringArrayVar(&o.BuildFlags, "build-flags", []string{}, "build flags passed to 'go list' for resolving the packages of cover profiles, such as -tags=integration or -mod=vendor, can be specified multiple times")
+	cmd.Flags().StringVar(&o.ReportFormat, "format", o.ReportFormat, "format of the diff coverage report, one of: html, json, markdown, cobertura, lcov, sarif")
 	cmd.Flags().StringSliceVar(&o.Excludes, "excludes", []string{}, "exclude files for diff coverage calucation")
 	cmd.Flags().StringVarP(&o.OutputDir, "outputdir", "o", o.OutputDir, "diff coverage output directory")
 	cmd.Flags().Float64Var(&o.CoverageBaseline, "coverage-baseline", o.CoverageBaseline, "returns an error code if coverage or quality score is less than coverage baseline")
+	cmd.Flags().StringArrayVar(&o.Thresholds, "threshold", []string{}, "coverage threshold rule in the format of pattern=percent for packages and files that match the doublestar pattern, can be specified multiple times, the last matching rule wins")
 	cmd.Flags().StringVar(&o.ReportName, "report-name", "coverage", "diff coverage report name")
 	cmd.Flags().StringVar(&o.Style, "style", "colorful", "coverage report code format style, refer to https://pygments.org/docs/styles for more information")
 
@@ -184,26 +237,32 @@ func newFullCoverageCommand() *cobra.Command {
 				return fmt.Errorf("NewFullCover: %w", err)
 			}
 
-			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeoutInSeconds*time.Second)
+			ctx, cancel := newTimeoutContext()
 			defer cancel()
 
 			if err := full.Run(ctx); err != nil {
-				return fmt.Errorf("generate full coverage: %w", err)
+				return withTimeoutExitCode(ctx, fmt.Errorf("generate full coverage: %w", err))
 			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().StringSliceVar(&o.CoverProfiles, "cover-profile", []string{}, `coverage profiles produced by 'go test'`)
+	cmd.Flags().StringSliceVar(&o.CoverProfiles, "cover-profile", []string{}, `coverage profiles produced by 'go test', or GOCOVERDIR directories written by binaries built with 'go build -cover'`)
 	cmd.Flags().StringVar(&o.RepositoryPath, "repository-path", "./", `the root directory of git repository`)
-	cmd.Flags().StringVar(&o.ModuleDir, "module-dir", "./", "module directory contains go.mod file that relative to the project")
-	cmd.Flags().StringVar(&o.ReportFormat, "format", o.ReportFormat, "format of the diff coverage report, one of: html, json, markdown")
+	cmd.Flags().StringVar(&o.ModuleDir, "module-dir", "./", "module directory contains go.mod file, or go.work file of the workspace, that relative to the project")
+	cmd.Flags().StringVar((*string)(&o.StaleProfile), "stale-profile", string(o.StaleProfile), `how to handle the cover profile that doesn't match the source file, "fail" or "warn"`)
+	cmd.Flags().StringArrayVar(&o.BuildFlags, "build-flags", []string{}, "build flags passed to 'go list' for resolving the packages of cover profiles, such as -tags=integration or -mod=vendor, can be specified multiple times")
+	cmd.Flags().StringVar(&o.ReportFormat, "format", o.ReportFormat, "format of the diff coverage report, one of: html, json, markdown, cobertura, lcov, sarif")
 	cmd.Flags().StringSliceVar(&o.Excludes, "excludes", []string{}, "exclude files for diff coverage calucation")
 	cmd.Flags().StringVarP(&o.OutputDir, "outputdir", "o", o.OutputDir, "diff coverage output directory")
 	cmd.Flags().Float64Var(&o.CoverageBaseline, "coverage-baseline", o.CoverageBaseline, "returns an error code if coverage or quality score is less than coverage baseline")
+	cmd.Flags().StringArrayVar(&o.Thresholds, "threshold", []string{}, "coverage threshold rule in the format of pattern=percent for packages and files that match the doublestar pattern, can be specified multiple times, the last matching rule wins")
 	cmd.Flags().StringVar(&o.ReportName, "report-name", "coverage", "diff coverage report name")
 	cmd.Flags().StringVar(&o.Style, "style", "colorful", "coverage report code format style, refer to https://pygments.org/docs/styles for more information")
+	cmd.Flags().StringVar(&o.RatchetFile, "ratchet-file", "", "ratchet snapshot file of per package coverage, fails if any package coverage drops more than the tolerance, the file is created if it does not exist")
+	cmd.Flags().Float64Var(&o.RatchetTolerance, "ratchet-tolerance", 0, "the coverage percent that a package is allowed to drop compared with the ratchet snapshot")
+	cmd.Flags().BoolVar(&o.UpdateRatchet, "update-ratchet", false, "overwrite the ratchet snapshot file with current coverage instead of comparing")
 
 	cmd.MarkFlagRequired("cover-profile")
 
@@ -223,31 +282,53 @@ func newGoCoverTestCommand() *cobra.Command {
 			o.DbOption = dbOption
 			o.StdOut = cmd.OutOrStdout()
 			o.StdErr = cmd.ErrOrStderr()
+			// the default baseline is for diff coverage, full coverage is only gated when the baseline is given explicitly
+			if o.CoverageMode == gocover.FullCoverage && !cmd.Flags().Changed("coverage-baseline") {
+				o.CoverageBaseline = 0
+			}
 
-			ctx, cancel := context.WithTimeout(context.Background(), defaultTimeoutInSeconds*time.Second)
+			ctx, cancel := newTimeoutContext()
 			defer cancel()
 
 			t, err := gocover.NewGoCoverTestExecutor(o)
 			if err != nil {
 				return fmt.Errorf("NewGoCoverTestExecutor: %w", err)
 			}
-			return t.Run(ctx)
+			return withTimeoutExitCode(ctx, t.Run(ctx))
 		},
 	}
 
 	cmd.Flags().StringSliceVar(&o.CoverProfiles, "cover-profile", []string{}, `coverage profile produced by 'go test'`)
+	cmd.Flags().StringSliceVar(&o.CompareCoverProfiles, "compare-cover-profile", []string{}, "coverage profile of the compare branch, the unchanged lines that are covered by it but not covered any more are reported as indirect coverage loss")
 	cmd.Flags().StringVar(&o.CompareBranch, "compare-branch", o.CompareBranch, `branch to compare`)
+	cmd.Flags().BoolVar(&o.IncludeUncommitted, "include-uncommitted", false, "include the staged and unstaged changes of the working tree in diff coverage, untracked files are not included")
+	cmd.Flags().StringVar(&o.DiffFile, "diff-file", "", "unified diff file used instead of comparing with compare-branch, such as the output of 'git diff' or the .diff of a pull request, '-' means reading from stdin")
+	cmd.Flags().StringVar(&o.From, "from", "", "start revision of the diff range, the changes are computed from the merge base of from and to, default is compare-branch")
+	cmd.Flags().StringVar(&o.To, "to", "", "end revision of the diff range, default is HEAD")
+	cmd.Flags().BoolVar(&o.FetchMissing, "fetch-missing", false, "fetch the compared branch and deepen the history with git binary when they are missing, such as in a shallow clone")
 	cmd.Flags().StringVar(&o.RepositoryPath, "repository-path", "./", `the root directory of git repository`)
 	cmd.Flags().StringVar(&o.ModuleDir, "module-dir", "./", "module directory contains go.mod file that relative to the project")
-	cmd.Flags().StringVar(&o.ReportFormat, "format", o.ReportFormat, "format of the diff coverage report, one of: html, json, markdown")
+	cmd.Flags().StringVar((*string)(&o.StaleProfile), "stale-profile", string(o.StaleProfile), `how to handle the cover profile that doesn't match the source file, "fail" or "warn"`)
+	cmd.Flags().StringVar(&o.ReportFormat, "format", o.ReportFormat, "format of the diff coverage report, one of: html, json, markdown, cobertura, lcov, sarif")
 	cmd.Flags().StringSliceVar(&o.Excludes, "excludes", []string{}, "exclude files for diff coverage calucation")
 	cmd.Flags().StringVarP(&o.OutputDir, "outputdir", "o", o.OutputDir, "diff coverage output directory")
-	cmd.Flags().Float64Var(&o.CoverageBaseline, "coverage-baseline", o.CoverageBaseline, "returns an error code if coverage or quality score is less than coverage baseline")
+	cmd.Flags().Float64Var(&o.CoverageBaseline, "coverage-baseline", o.CoverageBaseline, "returns an error code if coverage or quality score is less than coverage baseline, full coverage mode is only gated when it's set explicitly")
+	cmd.Flags().StringArrayVar(&o.Thresholds, "threshold", []string{}, "coverage threshold rule in the format of pattern=percent for packages and files that match the doublestar pattern, can be specified multiple times, the last matching rule wins")
 	cmd.Flags().StringVar(&o.ReportName, "report-name", "coverage", "diff coverage report name")
 	cmd.Flags().StringVar(&o.Style, "style", "colorful", "coverage report code format style, refer to https://pygments.org/docs/styles for more information")
 	cmd.Flags().StringVar((*string)(&o.CoverageMode), "coverage-mode", string(gocover.FullCoverage), `mode for coverage, "full" or "diff"`)
-	cmd.Flags().StringVar((*string)(&o.ExecutorMode), "executor-mode", string(gocover.GoExecutor), `unit test mode, "go" or "ginkgo"`)
+	cmd.Flags().StringVar((*string)(&o.ExecutorMode), "executor-mode", string(gocover.GoExecutor), `unit test mode, "go", "ginkgo" or "custom"`)
 	cmd.Flags().StringSliceVar(&o.GinkgoFlags, "ginkgo-flags", []string{"-r", "-trace", "-cover", "-coverpkg=./..."}, "ginkgo flags")
 	cmd.Flags().StringSliceVar(&o.GoFlags, "go-flags", []string{}, "go flags")
+	cmd.Flags().StringVar(&o.CustomCommand, "custom-command", "", "command line of the custom executor, it runs in the module directory with the shell, {{.OutputDir}} and {{.ModuleDir}} are replaced with the absolute paths of the directories")
+	cmd.Flags().StringArrayVar(&o.CustomCoverProfiles, "custom-cover-profile", []string{}, "doublestar pattern of the cover profiles written by the custom command, relative to the module directory, supports the same placeholders, can be specified multiple times")
+	cmd.Flags().BoolVar(&o.AllModules, "all-modules", false, "run the tests of all the modules under module-dir, and aggregate them into one report, the modules are the ones used by go.work or found by walking for go.mod")
+	cmd.Flags().IntVar(&o.Parallel, "parallel", o.Parallel, "the number of modules whose tests run at the same time with all-modules")
+	cmd.Flags().BoolVar(&o.SelectTests, "select-tests", false, "only run the tests of the packages that reach the changed packages, supported by the go executor in diff coverage mode")
+	cmd.Flags().StringVar((*string)(&o.Attribution), "attribution", "", `run each test ("test") or the tests of each package ("package") with its own cover profile, and write the index of which tests cover each statement into attribution.json of the output directory, supported by the go executor`)
+	cmd.Flags().StringVar(&o.JUnitFile, "junit-file", "", "write the test results into the JUnit xml file, only supported by the go executor")
+	cmd.Flags().StringVar(&o.RatchetFile, "ratchet-file", "", "ratchet snapshot file of per package coverage, fails if any package coverage drops more than the tolerance, the file is created if it does not exist")
+	cmd.Flags().Float64Var(&o.RatchetTolerance, "ratchet-tolerance", 0, "the coverage percent that a package is allowed to drop compared with the ratchet snapshot")
+	cmd.Flags().BoolVar(&o.UpdateRatchet, "update-ratchet", false, "overwrite the ratchet snapshot file with current coverage instead of comparing")
 	return cmd
 }
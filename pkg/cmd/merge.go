@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Azure/gocover/pkg/gocover"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeLong = `Merge cover profiles into a single cover profile.
+
+The cover profiles can be generated with different cover packages, or from unit tests and e2e tests.
+count and atomic modes are merged into atomic mode, the counts of the same block are summed up,
+set mode can't be merged with the other modes. GOCOVERDIR directories are accepted as well.
+`
+
+	mergeExample = `# Merge the cover profiles of unit tests and e2e tests
+gocover merge unit.out e2e.out --output coverage.out
+
+# Merge the cover profile of unit tests with the GOCOVERDIR directory of e2e tests, and write to stdout
+gocover merge unit.out /tmp/covdata > coverage.out
+`
+)
+
+func newMergeCommand() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:     "merge [cover profiles...]",
+		Short:   "merge cover profiles into a single cover profile",
+		Long:    mergeLong,
+		Example: mergeExample,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := newTimeoutContext()
+			defer cancel()
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("create output file: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			return withTimeoutExitCode(ctx, gocover.MergeCoverProfiles(ctx, args, w))
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "merged cover profile file, default is stdout")
+	return cmd
+}
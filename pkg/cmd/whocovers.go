@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"github.com/Azure/gocover/pkg/gocover"
+	"github.com/spf13/cobra"
+)
+
+var (
+	whoCoversLong = `Show the tests that cover a line of a go file.
+
+The attribution index is written by 'gocover test --attribution test' or 'gocover test --attribution package'
+into the output directory, each test, or the tests of each package, is run with its own cover profile.
+The file is the file name in the cover profiles, or the end of it, such as a path relative to the module.
+`
+
+	whoCoversExample = `# Run each test with its own cover profile, then show the tests that cover line 123 of pkg/foo/foo.go
+gocover test --coverage-mode diff --attribution test --outputdir /tmp
+gocover who-covers pkg/foo/foo.go:123 --index /tmp/attribution.json
+`
+)
+
+func newWhoCoversCommand() *cobra.Command {
+	var indexFile string
+	cmd := &cobra.Command{
+		Use:     "who-covers file.go:line",
+		Short:   "show the tests that cover a line of a go file",
+		Long:    whoCoversLong,
+		Example: whoCoversExample,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return gocover.WhoCovers(indexFile, args[0], cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVar(&indexFile, "index", "attribution.json", "attribution index file written by 'gocover test --attribution'")
+	return cmd
+}
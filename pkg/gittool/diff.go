@@ -3,6 +3,7 @@ package gittool
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -30,9 +31,23 @@ func NewGitClient(
 	}, nil
 }
 
+var ErrNoMergeBase = errors.New("no merge base found")
+
+// ErrRevisionNotCheckedOut indicates the revision is not the HEAD commit, the source files don't match it.
+var ErrRevisionNotCheckedOut = errors.New("revision is not checked out")
+
 type GitClient interface {
-	// DiffChangesFromCommitted returns the diff changes between HEAD and compared branch commit.
+	// DiffChangesFromCommitted returns the diff changes between HEAD and the merge base of HEAD and compared branch commit.
 	DiffChangesFromCommitted(compareBranch string) ([]*Change, error)
+	// DiffChangesFromRange returns the diff changes between to and the merge base of from and to,
+	// from and to can be any revision, such as branch name, tag or commit hash, to is HEAD if it's empty.
+	DiffChangesFromRange(from, to string) ([]*Change, error)
+	// DiffChangesFromWorkingTree returns the diff changes between the working tree and compared branch commit,
+	// it includes the committed changes, as well as the staged and unstaged changes.
+	DiffChangesFromWorkingTree(compareBranch string) ([]*Change, error)
+	// CheckCheckedOut returns ErrRevisionNotCheckedOut if the revision doesn't resolve to the HEAD commit,
+	// otherwise it returns the go files that are staged or modified in the working tree, which don't match the revision.
+	CheckCheckedOut(revision string) ([]string, error)
 }
 
 type gitClient struct {
@@ -43,7 +58,15 @@ type gitClient struct {
 var _ GitClient = (*gitClient)(nil)
 
 func (g *gitClient) DiffChangesFromCommitted(compareBranch string) ([]*Change, error) {
-	changes, err := g.diffChanges(compareBranch)
+	return g.DiffChangesFromRange(compareBranch, plumbing.HEAD.String())
+}
+
+func (g *gitClient) DiffChangesFromRange(from, to string) ([]*Change, error) {
+	if to == "" {
+		to = plumbing.HEAD.String()
+	}
+
+	changes, err := g.diffChanges(from, to)
 	if err != nil {
 		return nil, fmt.Errorf("execute diff: %w", err)
 	}
@@ -73,42 +96,66 @@ func (g *gitClient) DiffChangesFromCommitted(compareBranch string) ([]*Change, e
 	return diffChanges, nil
 }
 
-// diffChanges get the diff changes between compared branch and HEAD commit.
-// It equals to executing command `git diff {comparedBranch}...HEAD`.
+// diffChanges get the diff changes between the merge base of from and to, and the to commit.
+// It equals to executing command `git diff {from}...{to}`, so the changes made on from
+// after the branch point are not taken as the changes of to.
+//
+// Renamed files are detected by the similarity of the contents like git does, so a file moved with
+// small edits is a single change instead of a deleted file and an added file.
 //
 // It uses package github.com/go-git/go-git to get such output.
-func (g *gitClient) diffChanges(comparedBranch string) (gogitobj.Changes, error) {
-	// get commit object of HEAD
-	head, err := g.repository.Head()
+func (g *gitClient) diffChanges(from, to string) (gogitobj.Changes, error) {
+	toCommit, err := g.revisionCommit(plumbing.Revision(to))
+	if err != nil {
+		return gogitobj.Changes{}, err
+	}
+	toTree, err := toCommit.Tree()
 	if err != nil {
-		return gogitobj.Changes{}, fmt.Errorf("get HEAD %w", err)
+		return gogitobj.Changes{}, fmt.Errorf("get %s tree object %w", to, err)
 	}
 
-	headCommit, err := g.repository.CommitObject(head.Hash())
+	baseTree, err := g.mergeBaseTree(plumbing.Revision(from), toCommit)
 	if err != nil {
-		return gogitobj.Changes{}, fmt.Errorf("get HEAD commit %w", err)
+		return gogitobj.Changes{}, err
 	}
-	headTree, err := headCommit.Tree()
+
+	return gogitobj.DiffTreeWithOptions(context.Background(), baseTree, toTree, gogitobj.DefaultDiffTreeOptions)
+}
+
+// mergeBaseTree returns the tree object of the best common ancestor of the revision and the commit.
+func (g *gitClient) mergeBaseTree(revision plumbing.Revision, commit *gogitobj.Commit) (*gogitobj.Tree, error) {
+	revisionCommit, err := g.revisionCommit(revision)
 	if err != nil {
-		return gogitobj.Changes{}, fmt.Errorf("get HEAD tree object %w", err)
+		return nil, err
 	}
 
-	// get commit object of compared branch
-	comparedHash, err := g.repository.ResolveRevision(plumbing.Revision(comparedBranch))
+	bases, err := revisionCommit.MergeBase(commit)
 	if err != nil {
-		return gogitobj.Changes{}, fmt.Errorf("get %s %w", comparedBranch, err)
+		return nil, g.wrapHistoryError(fmt.Errorf("get merge base of %s and %s %w", revision, commit.Hash, err), revision)
+	}
+	if len(bases) == 0 {
+		return nil, g.wrapHistoryError(fmt.Errorf("%w: %s and %s", ErrNoMergeBase, revision, commit.Hash), revision)
 	}
 
-	comparedCommit, err := g.repository.CommitObject(*comparedHash)
+	tree, err := bases[0].Tree()
 	if err != nil {
-		return gogitobj.Changes{}, fmt.Errorf("get %s commit %w", comparedBranch, err)
+		return nil, fmt.Errorf("get merge base %s tree object %w", bases[0].Hash, err)
 	}
-	comparedTree, err := comparedCommit.Tree()
+	return tree, nil
+}
+
+// revisionCommit resolves the revision, such as HEAD, branch name or commit hash, and returns its commit.
+func (g *gitClient) revisionCommit(revision plumbing.Revision) (*gogitobj.Commit, error) {
+	hash, err := g.repository.ResolveRevision(revision)
 	if err != nil {
-		return gogitobj.Changes{}, fmt.Errorf("get %s tree object %w", comparedBranch, err)
+		return nil, g.wrapHistoryError(fmt.Errorf("get %s %w", revision, err), revision)
 	}
 
-	return gogitobj.DiffTree(comparedTree, headTree)
+	commit, err := g.repository.CommitObject(*hash)
+	if err != nil {
+		return nil, g.wrapHistoryError(fmt.Errorf("get %s commit %w", revision, err), revision)
+	}
+	return commit, nil
 }
 
 // buildChangeFromPatch builds the diff change from file patch.
@@ -123,9 +170,18 @@ func (g *gitClient) buildChangeFromPatch(filePatch diff.FilePatch) (*Change, err
 	switch {
 	// modify or rename file
 	case from != nil && to != nil:
-		if isGoFile(to) {
-			return g.buildChangeFromChunks(to.Path(), filePatch.Chunks())
+		if !isGoFile(to) {
+			return nil, nil
+		}
+		change, err := g.buildChangeFromChunks(to.Path(), filePatch.Chunks())
+		if err != nil {
+			return nil, err
 		}
+		if from.Path() != to.Path() {
+			change.Mode = RenameMode
+			change.OldFileName = from.Path()
+		}
+		return change, nil
 
 	// new file
 	case from == nil:
@@ -143,20 +199,26 @@ func (g *gitClient) buildChangeFromPatch(filePatch diff.FilePatch) (*Change, err
 }
 
 func isGoFile(fileInfo diff.File) bool {
-	return fileInfo.Mode() == filemode.Regular &&
-		strings.HasSuffix(fileInfo.Path(), ".go") &&
-		!strings.HasSuffix(fileInfo.Path(), "_test.go")
+	return fileInfo.Mode() == filemode.Regular && isGoFileName(fileInfo.Path())
+}
+
+// isGoFileName checks whether the file is a go source file, test files are not included.
+func isGoFileName(filename string) bool {
+	return strings.HasSuffix(filename, ".go") && !strings.HasSuffix(filename, "_test.go")
 }
 
 // buildChangeFromChunks builds the diff change from git chunks.
-// It's used when modify the existing file. and only contains the added chunks which will be used for later diff coverage.
+// It's used when modify the existing file. and only contains the added chunks which will be used for later diff coverage,
+// the deleted chunks are kept apart for mapping the unchanged lines.
 // Input chunks are sorted in sequence and guaranteed by the calling library github.com/go-git/go-git.
 func (g *gitClient) buildChangeFromChunks(filename string, chunks []diff.Chunk) (*Change, error) {
 
 	// count the total lines of the file
 	// equals lines + added lines should be equal with total lines.
 	totalCount := 0
-	var sections []*Section
+	// count the lines of the file in compared branch, equals lines + deleted lines.
+	oldCount := 0
+	var sections, deletedSections []*Section
 
 	for _, chunk := range chunks {
 
@@ -165,6 +227,7 @@ func (g *gitClient) buildChangeFromChunks(filename string, chunks []diff.Chunk)
 			scanner := bufio.NewScanner(bytes.NewBufferString(chunk.Content()))
 			for scanner.Scan() {
 				totalCount++
+				oldCount++
 			}
 
 		case diff.Add:
@@ -190,14 +253,34 @@ func (g *gitClient) buildChangeFromChunks(filename string, chunks []diff.Chunk)
 			})
 
 		case diff.Delete:
-			// we omit delete chunks
+			// the deleted chunks are only used to map the unchanged lines
+			startLine := oldCount + 1
+
+			scanner := bufio.NewScanner(bytes.NewBufferString(chunk.Content()))
+			var contents []string
+			for scanner.Scan() {
+				oldCount++
+				contents = append(contents, scanner.Text())
+			}
+
+			if len(contents) == 0 {
+				continue
+			}
+			deletedSections = append(deletedSections, &Section{
+				StartLine: startLine,
+				EndLine:   oldCount,
+				Count:     len(contents),
+				Contents:  contents,
+				Operation: Delete,
+			})
 		}
 	}
 
 	return &Change{
-		FileName: filename,
-		Sections: sections,
-		Mode:     ModifyMode,
+		FileName:        filename,
+		Sections:        sections,
+		DeletedSections: deletedSections,
+		Mode:            ModifyMode,
 	}, nil
 }
 
@@ -1,8 +1,11 @@
 package gittool
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,7 +48,7 @@ func TestDiffChanges(t *testing.T) {
 		defer clean()
 
 		client := &gitClient{repository: repo}
-		_, err := client.diffChanges(branch)
+		_, err := client.diffChanges(branch, "HEAD")
 		if err != nil {
 			t.Errorf("diff change: %s", err)
 		}
@@ -193,6 +196,14 @@ line6`
 		if section.Contents[1] != "line4" {
 			t.Errorf("first item should be 'line4', but get: %s", section.Contents[1])
 		}
+
+		if len(change.DeletedSections) != 1 {
+			t.Fatalf("change should contain 1 deleted section, but get %d", len(change.DeletedSections))
+		}
+		deleted := change.DeletedSections[0]
+		if deleted.Operation != Delete || deleted.StartLine != 3 || deleted.EndLine != 4 || deleted.Count != 2 {
+			t.Errorf("unexpected deleted section: %+v", deleted)
+		}
 	})
 }
 
@@ -533,3 +544,150 @@ func (chunk *mockChunk) Content() string {
 func (chunk *mockChunk) Type() diff.Operation {
 	return chunk.TypeFn()
 }
+
+func TestDiffChangesFromRange(t *testing.T) {
+	commit := func(worktree *gogit.Worktree, path, filename, contents string) plumbing.Hash {
+		checkError(os.WriteFile(filepath.Join(path, filename), []byte(contents), 0644))
+		_, err := worktree.Add(filename)
+		checkError(err)
+		hash, err := worktree.Commit("commit "+filename, &gogit.CommitOptions{
+			Author: &object.Signature{Name: "foo", Email: "foo@bar.org", When: time.Now()},
+		})
+		checkError(err)
+		return hash
+	}
+
+	t.Run("changes on compared branch after branch point are not included", func(t *testing.T) {
+		path, repo, clean := temporalRepository("")
+		defer clean()
+		worktree, err := repo.Worktree()
+		checkError(err)
+
+		checkError(worktree.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+		first := commit(worktree, path, "feature.go", "package foo\n")
+		second := commit(worktree, path, "feature2.go", "package foo\n")
+
+		checkError(worktree.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("master")}))
+		commit(worktree, path, "master.go", "package foo\n")
+		checkError(worktree.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature")}))
+
+		g := &gitClient{repositoryPath: path, repository: repo}
+		changes, err := g.DiffChangesFromCommitted("master")
+		if err != nil {
+			t.Fatalf("should not return error, but get: %s", err)
+		}
+		if len(changes) != 2 || changes[0].FileName != "feature.go" || changes[1].FileName != "feature2.go" {
+			t.Errorf("should only contain the changes of feature branch, but get %+v", changes)
+		}
+
+		changes, err = g.DiffChangesFromRange(first.String(), second.String())
+		if err != nil {
+			t.Fatalf("should not return error, but get: %s", err)
+		}
+		if len(changes) != 1 || changes[0].FileName != "feature2.go" {
+			t.Errorf("should only contain the changes between the commits, but get %+v", changes)
+		}
+	})
+
+	t.Run("revision not found", func(t *testing.T) {
+		path, repo, clean := temporalRepository("")
+		defer clean()
+
+		g := &gitClient{repositoryPath: path, repository: repo}
+		if _, err := g.DiffChangesFromRange("master", "nonexist"); err == nil {
+			t.Error("should return error")
+		}
+	})
+}
+
+func TestCheckCheckedOut(t *testing.T) {
+	path, repo, clean := temporalRepository("")
+	defer clean()
+	worktree, err := repo.Worktree()
+	checkError(err)
+
+	commit := func(filename string) plumbing.Hash {
+		checkError(os.WriteFile(filepath.Join(path, filename), []byte("package foo\n"), 0644))
+		_, err := worktree.Add(filename)
+		checkError(err)
+		hash, err := worktree.Commit("commit "+filename, &gogit.CommitOptions{
+			Author: &object.Signature{Name: "foo", Email: "foo@bar.org", When: time.Now()},
+		})
+		checkError(err)
+		return hash
+	}
+	first := commit("foo.go")
+	second := commit("bar.go")
+
+	g := &gitClient{repositoryPath: path, repository: repo}
+	if _, err := g.CheckCheckedOut(first.String()); !errors.Is(err, ErrRevisionNotCheckedOut) {
+		t.Errorf("should return ErrRevisionNotCheckedOut, but get: %v", err)
+	}
+
+	modified, err := g.CheckCheckedOut(second.String())
+	if err != nil || len(modified) != 0 {
+		t.Errorf("should be checked out without modified files, but get: %v, %v", modified, err)
+	}
+
+	checkError(os.WriteFile(filepath.Join(path, "foo.go"), []byte("package foo\n\nvar a = 1\n"), 0644))
+	checkError(os.WriteFile(filepath.Join(path, "new.go"), []byte("package foo\n"), 0644))
+	modified, err = g.CheckCheckedOut("master")
+	if err != nil || len(modified) != 1 || modified[0] != "foo.go" {
+		t.Errorf("should return the modified go file, but get: %v, %v", modified, err)
+	}
+}
+
+func TestDiffChangesWithRenames(t *testing.T) {
+	source := "package foo\n\nfunc foo() {\n\ta := 1\n\tb := 2\n\tc := 3\n\tprintln(a, b, c)\n}\n\nfunc bar() {\n\tprintln()\n}\n"
+	moved := strings.Replace(source, "package foo", "package bar", 1) + "\nvar d = 4\n"
+
+	path, repo, clean := temporalRepository("")
+	defer clean()
+	worktree, err := repo.Worktree()
+	checkError(err)
+
+	commit := func(message string) {
+		_, err := worktree.Commit(message, &gogit.CommitOptions{
+			Author: &object.Signature{Name: "foo", Email: "foo@bar.org", When: time.Now()},
+		})
+		checkError(err)
+	}
+
+	checkError(os.MkdirAll(filepath.Join(path, "foo"), 0755))
+	checkError(os.WriteFile(filepath.Join(path, "foo", "foo.go"), []byte(source), 0644))
+	_, err = worktree.Add("foo/foo.go")
+	checkError(err)
+	commit("add foo")
+
+	checkError(worktree.Checkout(&gogit.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+	checkError(os.MkdirAll(filepath.Join(path, "bar"), 0755))
+	checkError(os.WriteFile(filepath.Join(path, "bar", "foo.go"), []byte(moved), 0644))
+	_, err = worktree.Add("bar/foo.go")
+	checkError(err)
+	_, err = worktree.Remove("foo/foo.go")
+	checkError(err)
+	commit("move foo to bar")
+
+	g := &gitClient{repositoryPath: path, repository: repo}
+	changes, err := g.DiffChangesFromCommitted("master")
+	if err != nil {
+		t.Fatalf("should not return error, but get: %s", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("should have 1 change, but get %d", len(changes))
+	}
+
+	change := changes[0]
+	if change.FileName != "bar/foo.go" || change.OldFileName != "foo/foo.go" || change.Mode != RenameMode {
+		t.Errorf("unexpected change: %+v", change)
+	}
+	var lines []int
+	for _, section := range change.Sections {
+		for i := section.StartLine; i <= section.EndLine; i++ {
+			lines = append(lines, i)
+		}
+	}
+	if fmt.Sprint(lines) != "[1 13 14]" {
+		t.Errorf("only the edited lines should be changed, but get %v", lines)
+	}
+}
@@ -0,0 +1,186 @@
+package gittool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const devNull = "/dev/null"
+
+var (
+	ErrInvalidPatch = errors.New("invalid unified diff")
+
+	// hunkHeaderRegexp matches the hunk header, such as `@@ -1,3 +1,4 @@ func foo() {`.
+	hunkHeaderRegexp = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+)
+
+// ParseUnifiedDiff parses the unified diff, such as the output of `git diff` or the `.diff` of a pull request,
+// into the same changes as DiffChangesFromCommitted, so it can be used when the compared branch is not available.
+// The added lines are kept for the coverage and the deleted lines for mapping the unchanged lines,
+// deleted files and non go files are omitted.
+// Renamed files are recognized by the `rename from` and `rename to` headers of git diff.
+func ParseUnifiedDiff(r io.Reader) ([]*Change, error) {
+	var (
+		changes []*Change
+		current *Change
+		oldFile string
+		// renameFrom is the old name in `rename from` header of current file
+		renameFrom string
+		// oldRemain and newRemain are the lines left in current hunk
+		oldRemain, newRemain int
+		oldLine, newLine     int
+		// section and deleted are the added and deleted lines being read
+		section, deleted *Section
+		lineNumber       int
+	)
+
+	closeSection := func() {
+		if section != nil && current != nil {
+			current.Sections = append(current.Sections, section)
+		}
+		if deleted != nil && current != nil {
+			current.DeletedSections = append(current.DeletedSections, deleted)
+		}
+		section, deleted = nil, nil
+	}
+
+	closeChange := func() {
+		closeSection()
+		if current != nil && isGoFileName(current.FileName) && (len(current.Sections) != 0 || current.Mode == RenameMode) {
+			changes = append(changes, current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNumber++
+
+		// lines of the hunk
+		if oldRemain > 0 || newRemain > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				if deleted != nil {
+					closeSection()
+				}
+				if section == nil {
+					section = &Section{Operation: Add, StartLine: newLine}
+				}
+				section.Count++
+				section.EndLine = newLine
+				section.Contents = append(section.Contents, line[1:])
+				newLine++
+				newRemain--
+			case strings.HasPrefix(line, "-"):
+				if section != nil {
+					closeSection()
+				}
+				if deleted == nil {
+					deleted = &Section{Operation: Delete, StartLine: oldLine}
+				}
+				deleted.Count++
+				deleted.EndLine = oldLine
+				deleted.Contents = append(deleted.Contents, line[1:])
+				oldLine++
+				oldRemain--
+			case strings.HasPrefix(line, " "), line == "":
+				// some tools strip the trailing whitespace of the empty context line
+				closeSection()
+				oldLine++
+				newLine++
+				oldRemain--
+				newRemain--
+			case strings.HasPrefix(line, `\`):
+				// \ No newline at end of file
+			default:
+				return nil, fmt.Errorf("%w: line %d: unexpected line in hunk: %s", ErrInvalidPatch, lineNumber, line)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			closeChange()
+			renameFrom = ""
+
+		case strings.HasPrefix(line, `\`):
+			// \ No newline at end of file
+
+		case strings.HasPrefix(line, "rename from "):
+			renameFrom = patchFileName(strings.TrimPrefix(line, "rename from "), "")
+
+		case strings.HasPrefix(line, "rename to "):
+			current = &Change{FileName: patchFileName(strings.TrimPrefix(line, "rename to "), ""), Mode: RenameMode, OldFileName: renameFrom}
+
+		case strings.HasPrefix(line, "--- "):
+			// the renamed file opened by the rename headers is closed when the next file starts
+			if current == nil || current.Mode != RenameMode || len(current.Sections) != 0 {
+				closeChange()
+			}
+			oldFile = patchFileName(line[4:], "a/")
+
+		case strings.HasPrefix(line, "+++ "):
+			newFile := patchFileName(line[4:], "b/")
+			if newFile == devNull || current != nil {
+				// deleted file, we don't care about it, or renamed file that already opened
+				continue
+			}
+			current = &Change{FileName: newFile, Mode: ModifyMode}
+			if oldFile == devNull {
+				current.Mode = NewMode
+			}
+
+		case strings.HasPrefix(line, "@@"):
+			// hunks of deleted files are parsed as well, so that their lines are not taken as headers
+			m := hunkHeaderRegexp.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("%w: line %d: bad hunk header: %s", ErrInvalidPatch, lineNumber, line)
+			}
+			closeSection()
+			oldLine, _ = strconv.Atoi(m[1])
+			oldRemain = hunkLength(m[2])
+			newLine, _ = strconv.Atoi(m[3])
+			newRemain = hunkLength(m[4])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read diff: %w", err)
+	}
+	if oldRemain > 0 || newRemain > 0 {
+		return nil, fmt.Errorf("%w: unexpected end of hunk", ErrInvalidPatch)
+	}
+	closeChange()
+
+	return changes, nil
+}
+
+// patchFileName trims the timestamp and the prefix of the file name in `---` and `+++` lines.
+func patchFileName(name string, prefix string) string {
+	if i := strings.Index(name, "\t"); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSpace(name)
+	if name == devNull {
+		return name
+	}
+	if unquoted, err := strconv.Unquote(name); err == nil {
+		name = unquoted
+	}
+	return strings.TrimPrefix(name, prefix)
+}
+
+// hunkLength parses the line count of the hunk range, which is 1 when it's omitted.
+func hunkLength(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,212 @@
+package gittool
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	t.Run("parse git diff", func(t *testing.T) {
+		patch := `diff --git a/pkg/foo/foo.go b/pkg/foo/foo.go
+index 3b18e51..a9b3c2d 100644
+--- a/pkg/foo/foo.go
++++ b/pkg/foo/foo.go
+@@ -1,6 +1,8 @@
+ package foo
+
+ func foo() {
+-	println("foo")
++	println("bar")
++	println("zoo")
+ }
+
++var a = 1
+@@ -20,3 +22,4 @@ func bar() {
+ 	a := 1
+ 	b := 2
++	c := 3
+ }
+\ No newline at end of file
+diff --git a/pkg/foo/new.go b/pkg/foo/new.go
+new file mode 100644
+index 0000000..e69de29
+--- /dev/null
++++ b/pkg/foo/new.go
+@@ -0,0 +1,2 @@
++package foo
++
+diff --git a/pkg/foo/deleted.go b/pkg/foo/deleted.go
+deleted file mode 100644
+index e69de29..0000000
+--- a/pkg/foo/deleted.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package foo
+--- comment
+diff --git a/pkg/foo/foo_test.go b/pkg/foo/foo_test.go
+index 3b18e51..a9b3c2d 100644
+--- a/pkg/foo/foo_test.go
++++ b/pkg/foo/foo_test.go
+@@ -1 +1,2 @@
+ package foo
++
+diff --git a/README.md b/README.md
+index 3b18e51..a9b3c2d 100644
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-# foo
++# bar
+`
+		changes, err := ParseUnifiedDiff(strings.NewReader(patch))
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if len(changes) != 2 {
+			t.Fatalf("should have 2 changes, but get %d", len(changes))
+		}
+
+		foo := changes[0]
+		if foo.FileName != "pkg/foo/foo.go" || foo.Mode != ModifyMode {
+			t.Errorf("unexpected change: %+v", foo)
+		}
+		expected := []Section{
+			{Operation: Add, Count: 2, StartLine: 4, EndLine: 5, Contents: []string{`	println("bar")`, `	println("zoo")`}},
+			{Operation: Add, Count: 1, StartLine: 8, EndLine: 8, Contents: []string{"var a = 1"}},
+			{Operation: Add, Count: 1, StartLine: 24, EndLine: 24, Contents: []string{"	c := 3"}},
+		}
+		if len(foo.Sections) != len(expected) {
+			t.Fatalf("should have %d sections, but get %d", len(expected), len(foo.Sections))
+		}
+		for i, section := range foo.Sections {
+			if section.StartLine != expected[i].StartLine || section.EndLine != expected[i].EndLine ||
+				section.Count != expected[i].Count || strings.Join(section.Contents, "\n") != strings.Join(expected[i].Contents, "\n") {
+				t.Errorf("expect section %+v, but get %+v", expected[i], section)
+			}
+		}
+		if len(foo.DeletedSections) != 1 || foo.DeletedSections[0].StartLine != 4 || foo.DeletedSections[0].EndLine != 4 ||
+			foo.DeletedSections[0].Operation != Delete || foo.DeletedSections[0].Contents[0] != `	println("foo")` {
+			t.Errorf("unexpected deleted sections: %+v", foo.DeletedSections)
+		}
+		// the unchanged lines of the compared branch are mapped to the lines of HEAD
+		for oldLine, newLine := range map[int]int{1: 1, 3: 3, 5: 6, 6: 7, 20: 22, 22: 25} {
+			if line, ok := foo.MapLine(oldLine); !ok || line != newLine {
+				t.Errorf("line %d should be mapped to %d, but get %d, %t", oldLine, newLine, line, ok)
+			}
+		}
+		if _, ok := foo.MapLine(4); ok {
+			t.Error("deleted line should not be mapped")
+		}
+		if !foo.HasAddedLines(3, 4) || foo.HasAddedLines(6, 7) {
+			t.Error("unexpected added lines")
+		}
+
+		newFile := changes[1]
+		if newFile.FileName != "pkg/foo/new.go" || newFile.Mode != NewMode {
+			t.Errorf("unexpected change: %+v", newFile)
+		}
+		if len(newFile.Sections) != 1 || newFile.Sections[0].StartLine != 1 || newFile.Sections[0].EndLine != 2 {
+			t.Errorf("unexpected sections: %+v", newFile.Sections)
+		}
+	})
+
+	t.Run("parse diff without prefix and with timestamp", func(t *testing.T) {
+		patch := "--- foo.go\t2024-01-01 00:00:00\n+++ foo.go\t2024-01-02 00:00:00\n@@ -1 +1,2 @@\n package foo\n+var a = 1\n"
+		changes, err := ParseUnifiedDiff(strings.NewReader(patch))
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if len(changes) != 1 || changes[0].FileName != "foo.go" || changes[0].Sections[0].StartLine != 2 {
+			t.Errorf("unexpected changes: %+v", changes)
+		}
+	})
+
+	t.Run("parse renamed files", func(t *testing.T) {
+		patch := `diff --git a/pkg/foo/moved.go b/pkg/bar/moved.go
+similarity index 100%
+rename from pkg/foo/moved.go
+rename to pkg/bar/moved.go
+diff --git a/pkg/foo/edited.go b/pkg/bar/edited.go
+similarity index 90%
+rename from pkg/foo/edited.go
+rename to pkg/bar/edited.go
+index 3b18e51..a9b3c2d 100644
+--- a/pkg/foo/edited.go
++++ b/pkg/bar/edited.go
+@@ -1,2 +1,3 @@
+ package foo
+ 
++var a = 1
+diff --git a/foo.go b/foo.go
+index 3b18e51..a9b3c2d 100644
+--- a/foo.go
++++ b/foo.go
+@@ -1 +1,2 @@
+ package foo
++var b = 1
+`
+		changes, err := ParseUnifiedDiff(strings.NewReader(patch))
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if len(changes) != 3 {
+			t.Fatalf("should have 3 changes, but get %d", len(changes))
+		}
+
+		moved := changes[0]
+		if moved.FileName != "pkg/bar/moved.go" || moved.OldFileName != "pkg/foo/moved.go" || moved.Mode != RenameMode || len(moved.Sections) != 0 {
+			t.Errorf("unexpected change: %+v", moved)
+		}
+		edited := changes[1]
+		if edited.FileName != "pkg/bar/edited.go" || edited.OldFileName != "pkg/foo/edited.go" || edited.Mode != RenameMode ||
+			len(edited.Sections) != 1 || edited.Sections[0].StartLine != 3 {
+			t.Errorf("unexpected change: %+v", edited)
+		}
+		foo := changes[2]
+		if foo.FileName != "foo.go" || foo.Mode != ModifyMode || len(foo.Sections) != 1 || foo.Sections[0].StartLine != 2 {
+			t.Errorf("unexpected change: %+v", foo)
+		}
+	})
+
+	t.Run("parse diff with CRLF line endings", func(t *testing.T) {
+		// the diffs downloaded from windows hosts end lines with CRLF, the CR is dropped by bufio.ScanLines
+		patch := "diff --git a/pkg/foo/a.go b/pkg/bar/a.go\r\nsimilarity index 90%\r\nrename from pkg/foo/a.go\r\nrename to pkg/bar/a.go\r\n" +
+			"--- a/pkg/foo/a.go\r\n+++ b/pkg/bar/a.go\r\n@@ -1 +1,2 @@\r\n package foo\r\n+var a = 1\r\n" +
+			"diff --git a/foo.go b/foo.go\r\n--- a/foo.go\r\n+++ b/foo.go\r\n@@ -1 +1,2 @@\r\n package foo\r\n+var b = 1\r\n"
+		changes, err := ParseUnifiedDiff(strings.NewReader(patch))
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if len(changes) != 2 {
+			t.Fatalf("should have 2 changes, but get %d", len(changes))
+		}
+		if changes[0].FileName != "pkg/bar/a.go" || changes[0].OldFileName != "pkg/foo/a.go" || changes[0].Mode != RenameMode {
+			t.Errorf("unexpected change: %+v", changes[0])
+		}
+		if changes[1].FileName != "foo.go" || len(changes[1].Sections) != 1 || changes[1].Sections[0].Contents[0] != "var b = 1" {
+			t.Errorf("unexpected change: %+v", changes[1])
+		}
+	})
+
+	t.Run("bad hunk header", func(t *testing.T) {
+		_, err := ParseUnifiedDiff(strings.NewReader("--- a/foo.go\n+++ b/foo.go\n@@ -1 +a @@\n"))
+		if !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("should return ErrInvalidPatch, but get: %v", err)
+		}
+	})
+
+	t.Run("truncated hunk", func(t *testing.T) {
+		_, err := ParseUnifiedDiff(strings.NewReader("--- a/foo.go\n+++ b/foo.go\n@@ -1,3 +1,4 @@\n package foo\n"))
+		if !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("should return ErrInvalidPatch, but get: %v", err)
+		}
+	})
+
+	t.Run("empty diff", func(t *testing.T) {
+		changes, err := ParseUnifiedDiff(strings.NewReader(""))
+		if err != nil || len(changes) != 0 {
+			t.Errorf("should have no changes, but get %v, %v", changes, err)
+		}
+	})
+}
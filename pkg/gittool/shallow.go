@@ -0,0 +1,162 @@
+package gittool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// fetchDepthStep is the number of commits fetched each time when looking for the merge base.
+	fetchDepthStep = 50
+	// maxDeepenAttempts is the max attempts to deepen the history before fetching the whole history.
+	maxDeepenAttempts = 4
+	// defaultRemote is the remote used when the compared branch is not a remote tracking branch.
+	defaultRemote = "origin"
+	// gitWaitDelay is how long to wait for the output of the killed git binary.
+	gitWaitDelay = 5 * time.Second
+)
+
+var (
+	// ErrShallowRepository indicates the revision or the merge base is not available because the repository is a shallow clone.
+	ErrShallowRepository = errors.New("git history is not available in the shallow repository")
+	// ErrRevisionNotFound indicates the revision is not available in the repository.
+	ErrRevisionNotFound = errors.New("git revision is not found")
+)
+
+// isShallow checks whether the repository is a shallow clone, such as `git clone --depth 1`.
+func (g *gitClient) isShallow() bool {
+	shallows, err := g.repository.Storer.Shallow()
+	return err == nil && len(shallows) != 0
+}
+
+// wrapHistoryError converts the errors of missing objects or references to actionable errors,
+// so the users know how to fetch the history that diff coverage needs.
+func (g *gitClient) wrapHistoryError(err error, revision plumbing.Revision) error {
+	missing := errors.Is(err, plumbing.ErrReferenceNotFound) ||
+		errors.Is(err, plumbing.ErrObjectNotFound) ||
+		errors.Is(err, ErrNoMergeBase)
+	if !missing {
+		return err
+	}
+
+	if g.isShallow() {
+		return fmt.Errorf("%w: %s or its merge base with HEAD is missing (%v), fetch more history with "+
+			"`git fetch --deepen=<depth>` or `git fetch --unshallow`, use `fetch-depth: 0` for actions/checkout, "+
+			"or use --fetch-missing to fetch it automatically", ErrShallowRepository, revision, err)
+	}
+	return fmt.Errorf("%w: %s (%v), fetch it with `git fetch %s`", ErrRevisionNotFound, revision, err, fetchHint(string(revision)))
+}
+
+func fetchHint(revision string) string {
+	remote, branch, ok := strings.Cut(revision, "/")
+	if !ok {
+		return defaultRemote + " " + revision
+	}
+	return remote + " " + branch
+}
+
+// FetchMissingHistory fetches the compared branch and deepens the history with system git binary,
+// until the merge base of the to revision, HEAD if empty, and the compared branch is found. go-git doesn't
+// support deepening a shallow repository, so the git binary is required. A complete repository is not deepened,
+// only the compared branch is fetched if it's missing.
+// The compared branch that is not in the repository is fetched into its remote tracking branch, such as
+// origin/main for main, it returns the revision to use as the compared branch.
+func FetchMissingHistory(ctx context.Context, repositoryPath string, compareBranch string, to string) (string, error) {
+	if to == "" {
+		to = "HEAD"
+	}
+	remote, branch := defaultRemote, compareBranch
+	if r, b, ok := strings.Cut(compareBranch, "/"); ok && isRemote(ctx, repositoryPath, r) {
+		remote, branch = r, b
+	}
+
+	// the depth options make a complete repository shallow, they are only used when it's shallow already
+	shallow, err := isShallow(ctx, repositoryPath)
+	if err != nil {
+		return "", err
+	}
+
+	revision := compareBranch
+	if _, err := runGit(ctx, repositoryPath, "rev-parse", "--verify", "--quiet", compareBranch+"^{commit}"); err != nil {
+		refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", branch, remote, branch)
+		args := []string{"fetch", "--no-tags"}
+		if shallow {
+			args = append(args, fmt.Sprintf("--depth=%d", fetchDepthStep))
+		}
+		if _, err := runGit(ctx, repositoryPath, append(args, remote, refspec)...); err != nil {
+			return "", fmt.Errorf("fetch %s: %w", compareBranch, err)
+		}
+		revision = remote + "/" + branch
+	}
+
+	if !shallow {
+		if _, err := runGit(ctx, repositoryPath, "merge-base", to, revision); err != nil {
+			return "", fmt.Errorf("%w: %s and %s in the complete history: %v", ErrNoMergeBase, to, revision, err)
+		}
+		return revision, nil
+	}
+
+	for i := 0; i < maxDeepenAttempts; i++ {
+		if _, err := runGit(ctx, repositoryPath, "merge-base", to, revision); err == nil {
+			return revision, nil
+		}
+		if _, err := runGit(ctx, repositoryPath, "fetch", "--no-tags", fmt.Sprintf("--deepen=%d", fetchDepthStep), remote); err != nil {
+			return "", fmt.Errorf("deepen history: %w", err)
+		}
+	}
+
+	if _, err := runGit(ctx, repositoryPath, "merge-base", to, revision); err == nil {
+		return revision, nil
+	}
+	if _, err := runGit(ctx, repositoryPath, "fetch", "--no-tags", "--unshallow", remote); err != nil {
+		return "", fmt.Errorf("unshallow history: %w", err)
+	}
+	if _, err := runGit(ctx, repositoryPath, "merge-base", to, revision); err != nil {
+		return "", fmt.Errorf("%w: %s and %s after fetching the whole history: %v", ErrNoMergeBase, to, revision, err)
+	}
+	return revision, nil
+}
+
+// isShallow returns whether the repository is a shallow clone.
+func isShallow(ctx context.Context, repositoryPath string) (bool, error) {
+	out, err := runGit(ctx, repositoryPath, "rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return false, fmt.Errorf("check shallow repository: %w", err)
+	}
+	return strings.TrimSpace(out) == "true", nil
+}
+
+func isRemote(ctx context.Context, repositoryPath string, name string) bool {
+	out, err := runGit(ctx, repositoryPath, "remote")
+	if err != nil {
+		return false
+	}
+	for _, remote := range strings.Fields(out) {
+		if remote == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runGit runs the git binary, which is killed when the context is done, such as a fetch hanging on the network.
+func runGit(ctx context.Context, repositoryPath string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repositoryPath
+	// the helpers of git, such as ssh, may hold the output pipes after git is killed
+	cmd.WaitDelay = gitWaitDelay
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,170 @@
+package gittool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+)
+
+func TestWrapHistoryError(t *testing.T) {
+	t.Run("revision not found", func(t *testing.T) {
+		path, repo, clean := temporalRepository("")
+		defer clean()
+
+		g := &gitClient{repositoryPath: path, repository: repo}
+		_, err := g.DiffChangesFromCommitted("origin/master")
+		if !errors.Is(err, ErrRevisionNotFound) {
+			t.Errorf("should return ErrRevisionNotFound, but get: %v", err)
+		}
+	})
+
+	t.Run("shallow repository", func(t *testing.T) {
+		path, repo, clean := temporalRepository("")
+		defer clean()
+
+		head, err := repo.Head()
+		checkError(err)
+		checkError(os.WriteFile(filepath.Join(path, ".git", "shallow"), []byte(head.Hash().String()+"\n"), 0644))
+
+		g := &gitClient{repositoryPath: path, repository: repo}
+		if !g.isShallow() {
+			t.Fatal("should be shallow repository")
+		}
+		_, err = g.DiffChangesFromCommitted("origin/master")
+		if !errors.Is(err, ErrShallowRepository) {
+			t.Errorf("should return ErrShallowRepository, but get: %v", err)
+		}
+	})
+
+	t.Run("other errors are not wrapped", func(t *testing.T) {
+		g := &gitClient{}
+		err := errors.New("foo")
+		if wrapped := g.wrapHistoryError(err, "master"); wrapped != err {
+			t.Errorf("should return the original error, but get: %v", wrapped)
+		}
+	})
+}
+
+func TestFetchMissingHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary is not found")
+	}
+
+	git := func(dir string, args ...string) {
+		args = append([]string{"-c", "user.name=foo", "-c", "user.email=foo@bar.org", "-c", "init.defaultBranch=master"}, args...)
+		if _, err := runGit(context.Background(), dir, args...); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	origin := t.TempDir()
+	git(origin, "init")
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		checkError(os.WriteFile(filepath.Join(origin, name), []byte("package foo\n"), 0644))
+		git(origin, "add", name)
+		git(origin, "commit", "-m", name)
+	}
+	git(origin, "checkout", "-b", "feature")
+	for _, name := range []string{"d.go", "e.go"} {
+		checkError(os.WriteFile(filepath.Join(origin, name), []byte("package foo\n"), 0644))
+		git(origin, "add", name)
+		git(origin, "commit", "-m", name)
+	}
+
+	shallowClone := func() string {
+		clone := filepath.Join(t.TempDir(), "clone")
+		git(origin, "clone", "--depth=1", "--branch=feature", "file://"+origin, clone)
+		return clone
+	}
+	diffChanges := func(clone, compareBranch string) ([]*Change, error) {
+		repo, err := gogit.PlainOpen(clone)
+		checkError(err)
+		g := &gitClient{repositoryPath: clone, repository: repo}
+		return g.DiffChangesFromCommitted(compareBranch)
+	}
+
+	t.Run("remote tracking branch", func(t *testing.T) {
+		clone := shallowClone()
+		if _, err := diffChanges(clone, "origin/master"); !errors.Is(err, ErrShallowRepository) {
+			t.Fatalf("should return ErrShallowRepository, but get: %v", err)
+		}
+
+		revision, err := FetchMissingHistory(context.Background(), clone, "origin/master", "")
+		if err != nil {
+			t.Fatalf("should fetch missing history, but get: %s", err)
+		}
+		if revision != "origin/master" {
+			t.Errorf("revision should be origin/master, but get: %s", revision)
+		}
+
+		changes, err := diffChanges(clone, revision)
+		if err != nil {
+			t.Fatalf("should not return error, but get: %s", err)
+		}
+		if len(changes) != 2 || changes[0].FileName != "d.go" || changes[1].FileName != "e.go" {
+			t.Errorf("unexpected changes: %+v", changes)
+		}
+	})
+
+	t.Run("local branch is fetched into remote tracking branch", func(t *testing.T) {
+		clone := shallowClone()
+		revision, err := FetchMissingHistory(context.Background(), clone, "master", "HEAD~1")
+		if err != nil {
+			t.Fatalf("should fetch missing history, but get: %s", err)
+		}
+		if revision != "origin/master" {
+			t.Errorf("revision should be origin/master, but get: %s", revision)
+		}
+		if _, err := runGit(context.Background(), clone, "merge-base", "HEAD~1", revision); err != nil {
+			t.Errorf("should find merge base, but get: %s", err)
+		}
+	})
+
+	t.Run("complete repository is not made shallow", func(t *testing.T) {
+		clone := filepath.Join(t.TempDir(), "clone")
+		git(origin, "clone", "--single-branch", "--branch=feature", "file://"+origin, clone)
+		revision, err := FetchMissingHistory(context.Background(), clone, "master", "")
+		if err != nil {
+			t.Fatalf("should fetch the compared branch, but get: %s", err)
+		}
+		if revision != "origin/master" {
+			t.Errorf("revision should be origin/master, but get: %s", revision)
+		}
+		if shallow, err := isShallow(context.Background(), clone); err != nil || shallow {
+			t.Errorf("the repository should not be shallow, but get: %t, %v", shallow, err)
+		}
+	})
+
+	t.Run("no merge base in complete repository", func(t *testing.T) {
+		tree, err := runGit(context.Background(), origin, "rev-parse", "master^{tree}")
+		checkError(err)
+		orphan, err := runGit(context.Background(), origin, "-c", "user.name=foo", "-c", "user.email=foo@bar.org",
+			"commit-tree", strings.TrimSpace(tree), "-m", "orphan")
+		checkError(err)
+		git(origin, "branch", "orphan", strings.TrimSpace(orphan))
+
+		clone := filepath.Join(t.TempDir(), "clone")
+		git(origin, "clone", "--single-branch", "--branch=feature", "file://"+origin, clone)
+		if _, err := FetchMissingHistory(context.Background(), clone, "orphan", ""); !errors.Is(err, ErrNoMergeBase) {
+			t.Errorf("should return ErrNoMergeBase, but get: %v", err)
+		}
+		if shallow, err := isShallow(context.Background(), clone); err != nil || shallow {
+			t.Errorf("the repository should not be shallow, but get: %t, %v", shallow, err)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		clone := shallowClone()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := FetchMissingHistory(ctx, clone, "origin/master", ""); err == nil {
+			t.Errorf("should return error")
+		}
+	})
+}
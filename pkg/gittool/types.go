@@ -52,9 +52,51 @@ type Change struct {
 	// Mode indicates what kind of the change, whether it's a new created file,
 	// or a modified file, or deleted file, or renamed file.
 	Mode DiffMode
+	// OldFileName indicates the file name in compared branch, it's only set for RenameMode.
+	OldFileName string
 	// Sections indicates the change details.
-	// For NewMode and RenameMode it contains all the contents of the new file
-	// For ModifyMode it contains the each change sections made to compared branch
+	// For NewMode it contains all the contents of the new file
+	// For ModifyMode and RenameMode it contains the each change sections made to compared branch,
+	// so the lines moved along with the renamed file are not taken as changes
 	// For DeleteMode it's empty
 	Sections []*Section
+	// DeletedSections indicates the sections deleted from the compared branch, numbered by the lines of the compared branch.
+	// Along with Sections, they map the unchanged lines of ModifyMode and RenameMode between the compared branch and HEAD.
+	DeletedSections []*Section
+}
+
+// MapLine returns the line in HEAD of the line in compared branch,
+// false is returned if the line is deleted or the file is new created.
+func (c *Change) MapLine(line int) (int, bool) {
+	if c.Mode == NewMode || c.Mode == DeleteMode {
+		return 0, false
+	}
+
+	// the line is the n-th unchanged line, which is the n-th unchanged line in HEAD as well
+	for _, section := range c.DeletedSections {
+		if line < section.StartLine {
+			break
+		}
+		if line <= section.EndLine {
+			return 0, false
+		}
+		line -= section.Count
+	}
+	for _, section := range c.Sections {
+		if section.StartLine > line {
+			break
+		}
+		line += section.Count
+	}
+	return line, true
+}
+
+// HasAddedLines returns whether any line in [startLine, endLine] of HEAD is added.
+func (c *Change) HasAddedLines(startLine, endLine int) bool {
+	for _, section := range c.Sections {
+		if section.StartLine <= endLine && section.EndLine >= startLine {
+			return true
+		}
+	}
+	return false
 }
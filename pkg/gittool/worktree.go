@@ -0,0 +1,306 @@
+package gittool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	gogitobj "github.com/go-git/go-git/v5/plumbing/object"
+	utildiff "github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+func (g *gitClient) DiffChangesFromWorkingTree(compareBranch string) ([]*Change, error) {
+	head, err := g.revisionCommit(plumbing.Revision(plumbing.HEAD))
+	if err != nil {
+		return nil, fmt.Errorf("execute diff: %w", err)
+	}
+	comparedTree, err := g.mergeBaseTree(plumbing.Revision(compareBranch), head)
+	if err != nil {
+		return nil, fmt.Errorf("execute diff: %w", err)
+	}
+
+	files, renames, err := g.changedFiles(compareBranch)
+	if err != nil {
+		return nil, fmt.Errorf("get changed files: %w", err)
+	}
+	if err := g.detectRenames(comparedTree, files, renames); err != nil {
+		return nil, fmt.Errorf("detect renames: %w", err)
+	}
+
+	var diffChanges []*Change
+	for _, filename := range files {
+		diffChange, err := g.buildChangeFromWorkingTree(comparedTree, filename, renames[filename])
+		if err != nil {
+			return nil, fmt.Errorf("build change from working tree: %w", err)
+		}
+
+		if diffChange != nil {
+			diffChanges = append(diffChanges, diffChange)
+		}
+	}
+
+	return diffChanges, nil
+}
+
+func (g *gitClient) CheckCheckedOut(revision string) ([]string, error) {
+	head, err := g.revisionCommit(plumbing.Revision(plumbing.HEAD))
+	if err != nil {
+		return nil, err
+	}
+	commit, err := g.revisionCommit(plumbing.Revision(revision))
+	if err != nil {
+		return nil, err
+	}
+	if commit.Hash != head.Hash {
+		return nil, fmt.Errorf("%w: %s is %s, but HEAD is %s", ErrRevisionNotCheckedOut, revision, commit.Hash, head.Hash)
+	}
+
+	worktree, err := g.repository.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("get worktree: %w", err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("get worktree status: %w", err)
+	}
+	var modified []string
+	for filename, s := range status {
+		if !isGoFileName(filename) || s.Worktree == gogit.Untracked {
+			continue
+		}
+		if s.Staging != gogit.Unmodified || s.Worktree != gogit.Unmodified {
+			modified = append(modified, filename)
+		}
+	}
+	sort.Strings(modified)
+	return modified, nil
+}
+
+// changedFiles returns the sorted files that are changed in the commits since the merge base,
+// or staged, or modified in the working tree. Untracked files are not included.
+// It also returns the files renamed in the commits, the key is the new name and the value is the old name.
+func (g *gitClient) changedFiles(compareBranch string) ([]string, map[string]string, error) {
+	changes, err := g.diffChanges(compareBranch, plumbing.HEAD.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	worktree, err := g.repository.Worktree()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get worktree: %w", err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get worktree status: %w", err)
+	}
+
+	m := make(map[string]bool)
+	renames := make(map[string]string)
+	for _, change := range changes {
+		if change.To.Name != "" {
+			m[change.To.Name] = true
+		}
+		if change.From.Name != "" && change.To.Name != "" && change.From.Name != change.To.Name {
+			renames[change.To.Name] = change.From.Name
+		}
+	}
+	for filename, s := range status {
+		if s.Worktree == gogit.Untracked {
+			continue
+		}
+		if s.Staging != gogit.Unmodified || s.Worktree != gogit.Unmodified {
+			m[filename] = true
+		}
+	}
+
+	files := make([]string, 0, len(m))
+	for filename := range m {
+		files = append(files, filename)
+	}
+	sort.Strings(files)
+	return files, renames, nil
+}
+
+// detectRenames pairs the go files that are not in the merge base tree with the go files deleted
+// from the working tree, by the similarity of their contents, as git status doesn't detect the renames
+// that are not committed yet. The detected renames are added to renames.
+func (g *gitClient) detectRenames(comparedTree *gogitobj.Tree, files []string, renames map[string]string) error {
+	var added, deleted []string
+	for _, filename := range files {
+		if !isGoFileName(filename) {
+			continue
+		}
+		if _, ok := renames[filename]; ok {
+			continue
+		}
+		_, err := comparedTree.File(filename)
+		inTree := err == nil
+		if err != nil && !errors.Is(err, gogitobj.ErrFileNotFound) {
+			return fmt.Errorf("get %s from compared tree: %w", filename, err)
+		}
+		_, err = os.Lstat(filepath.Join(g.repositoryPath, filename))
+		onDisk := err == nil
+		switch {
+		case !inTree && onDisk:
+			added = append(added, filename)
+		case inTree && !onDisk:
+			deleted = append(deleted, filename)
+		}
+	}
+	if len(added) == 0 || len(deleted) == 0 {
+		return nil
+	}
+
+	contents := make(map[string]string, len(deleted))
+	for _, filename := range deleted {
+		file, err := comparedTree.File(filename)
+		if err != nil {
+			return fmt.Errorf("get %s from compared tree: %w", filename, err)
+		}
+		if contents[filename], err = file.Contents(); err != nil {
+			return fmt.Errorf("get contents of %s: %w", filename, err)
+		}
+	}
+
+	for _, filename := range added {
+		data, err := os.ReadFile(filepath.Join(g.repositoryPath, filename))
+		if err != nil {
+			return err
+		}
+
+		best, bestScore := -1, gogitobj.DefaultDiffTreeOptions.RenameScore-1
+		for i, oldFileName := range deleted {
+			if oldFileName == "" {
+				continue
+			}
+			if score := similarity(contents[oldFileName], string(data)); score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best >= 0 {
+			renames[filename] = deleted[best]
+			// a deleted file can only be renamed once
+			deleted[best] = ""
+		}
+	}
+	return nil
+}
+
+// similarity returns the percent of the lines that the two texts have in common, like the similarity index of git.
+func similarity(src, dst string) uint {
+	var common, total int
+	for _, d := range utildiff.Do(src, dst) {
+		lines := countLines(d.Text)
+		if d.Type == diffmatchpatch.DiffEqual {
+			// equal lines are in both texts
+			lines *= 2
+			common += lines
+		}
+		total += lines
+	}
+	if total == 0 {
+		return 100
+	}
+	return uint(common * 100 / total)
+}
+
+func countLines(text string) int {
+	n := strings.Count(text, "\n")
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
+
+// buildChangeFromWorkingTree builds the diff change by comparing the file in the merge base tree
+// with the file contents on disk. It returns nil when the file is deleted, not a go file, or not changed at all.
+// For the renamed file, oldFileName is the name in the merge base tree, otherwise it's empty.
+func (g *gitClient) buildChangeFromWorkingTree(comparedTree *gogitobj.Tree, filename string, oldFileName string) (*Change, error) {
+	if !isGoFileName(filename) {
+		return nil, nil
+	}
+
+	fullFilePath := filepath.Join(g.repositoryPath, filename)
+	info, err := os.Lstat(fullFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	comparedFileName := filename
+	if oldFileName != "" {
+		comparedFileName = oldFileName
+	}
+	comparedFile, err := comparedTree.File(comparedFileName)
+	if errors.Is(err, gogitobj.ErrFileNotFound) {
+		return g.buildChangeFromFile(filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get %s from compared tree: %w", comparedFileName, err)
+	}
+
+	src, err := comparedFile.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("get contents of %s: %w", filename, err)
+	}
+	dst, err := os.ReadFile(fullFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if src == string(dst) && oldFileName == "" {
+		return nil, nil
+	}
+
+	var chunks []diff.Chunk
+	for _, d := range utildiff.Do(src, string(dst)) {
+		chunks = append(chunks, &textChunk{content: d.Text, operation: toChunkOperation(d.Type)})
+	}
+	change, err := g.buildChangeFromChunks(filename, chunks)
+	if err != nil {
+		return nil, err
+	}
+	if oldFileName != "" {
+		change.Mode = RenameMode
+		change.OldFileName = oldFileName
+	}
+	return change, nil
+}
+
+// textChunk implements diff.Chunk for the line oriented diffs of two texts.
+type textChunk struct {
+	content   string
+	operation diff.Operation
+}
+
+var _ diff.Chunk = (*textChunk)(nil)
+
+func (c *textChunk) Content() string {
+	return c.content
+}
+
+func (c *textChunk) Type() diff.Operation {
+	return c.operation
+}
+
+func toChunkOperation(op diffmatchpatch.Operation) diff.Operation {
+	switch op {
+	case diffmatchpatch.DiffInsert:
+		return diff.Add
+	case diffmatchpatch.DiffDelete:
+		return diff.Delete
+	default:
+		return diff.Equal
+	}
+}
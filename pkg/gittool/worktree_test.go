@@ -0,0 +1,156 @@
+package gittool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestDiffChangesFromWorkingTree(t *testing.T) {
+	t.Run("compare branch not found", func(t *testing.T) {
+		path, repo, clean := temporalRepository("")
+		defer clean()
+
+		g := &gitClient{repositoryPath: path, repository: repo}
+		if _, err := g.DiffChangesFromWorkingTree("nonexist"); err == nil {
+			t.Error("should return error")
+		}
+	})
+
+	t.Run("include committed, staged and unstaged changes", func(t *testing.T) {
+		path, repo, clean := temporalRepository("")
+		defer clean()
+		worktree, err := repo.Worktree()
+		checkError(err)
+
+		writeFile := func(name, contents string) {
+			checkError(os.WriteFile(filepath.Join(path, name), []byte(contents), 0644))
+		}
+
+		writeFile("staged.go", "package foo\n\nfunc foo() {\n}\n")
+		writeFile("unstaged.go", "package foo\n\nfunc bar() {\n}\n")
+		writeFile("deleted.go", "package foo\n")
+		writeFile("unchanged.go", "package foo\n")
+		for _, name := range []string{"staged.go", "unstaged.go", "deleted.go", "unchanged.go"} {
+			_, err := worktree.Add(name)
+			checkError(err)
+		}
+		_, err = worktree.Commit("base commit", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "foo", Email: "foo@bar.org", When: time.Now()},
+		})
+		checkError(err)
+		checkError(worktree.Checkout(&gogit.CheckoutOptions{Branch: "refs/heads/feature", Create: true}))
+
+		// committed change
+		writeFile("committed.go", "package foo\n\nvar a = 1\n")
+		_, err = worktree.Add("committed.go")
+		checkError(err)
+		_, err = worktree.Commit("feature commit", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "foo", Email: "foo@bar.org", When: time.Now()},
+		})
+		checkError(err)
+
+		// staged change
+		writeFile("staged.go", "package foo\n\nfunc foo() {\n\tprintln()\n}\n")
+		_, err = worktree.Add("staged.go")
+		checkError(err)
+		// unstaged change
+		writeFile("unstaged.go", "package foo\n\nfunc bar() {\n}\n\nfunc zoo() {\n}\n")
+		// deleted file and untracked file are not included
+		checkError(os.Remove(filepath.Join(path, "deleted.go")))
+		writeFile("untracked.go", "package foo\n")
+
+		g := &gitClient{repositoryPath: path, repository: repo}
+		changes, err := g.DiffChangesFromWorkingTree("master")
+		if err != nil {
+			t.Fatalf("should not return error, but get: %s", err)
+		}
+
+		if len(changes) != 3 {
+			t.Fatalf("should have 3 changes, but get %d", len(changes))
+		}
+
+		committed := changes[0]
+		if committed.FileName != "committed.go" || committed.Mode != NewMode {
+			t.Errorf("unexpected change: %+v", committed)
+		}
+
+		staged := changes[1]
+		if staged.FileName != "staged.go" || staged.Mode != ModifyMode || len(staged.Sections) != 1 {
+			t.Fatalf("unexpected change: %+v", staged)
+		}
+		if staged.Sections[0].StartLine != 4 || staged.Sections[0].EndLine != 4 || staged.Sections[0].Contents[0] != "\tprintln()" {
+			t.Errorf("unexpected section: %+v", staged.Sections[0])
+		}
+
+		unstaged := changes[2]
+		if unstaged.FileName != "unstaged.go" || unstaged.Mode != ModifyMode || len(unstaged.Sections) != 1 {
+			t.Fatalf("unexpected change: %+v", unstaged)
+		}
+		if unstaged.Sections[0].StartLine != 5 || unstaged.Sections[0].EndLine != 7 {
+			t.Errorf("unexpected section: %+v", unstaged.Sections[0])
+		}
+	})
+
+	t.Run("detect uncommitted renames", func(t *testing.T) {
+		path, repo, clean := temporalRepository("")
+		defer clean()
+		worktree, err := repo.Worktree()
+		checkError(err)
+
+		source := "package foo\n\nfunc foo() {\n\ta := 1\n\tb := 2\n\tprintln(a, b)\n}\n"
+		checkError(os.WriteFile(filepath.Join(path, "old.go"), []byte(source), 0644))
+		_, err = worktree.Add("old.go")
+		checkError(err)
+		_, err = worktree.Commit("base commit", &gogit.CommitOptions{
+			Author: &object.Signature{Name: "foo", Email: "foo@bar.org", When: time.Now()},
+		})
+		checkError(err)
+
+		// git mv old.go new.go with a small edit
+		checkError(os.Remove(filepath.Join(path, "old.go")))
+		checkError(os.WriteFile(filepath.Join(path, "new.go"), []byte(source+"\nvar c = 3\n"), 0644))
+		_, err = worktree.Add("old.go")
+		checkError(err)
+		_, err = worktree.Add("new.go")
+		checkError(err)
+
+		g := &gitClient{repositoryPath: path, repository: repo}
+		changes, err := g.DiffChangesFromWorkingTree("master")
+		if err != nil {
+			t.Fatalf("should not return error, but get: %s", err)
+		}
+		if len(changes) != 1 {
+			t.Fatalf("should have 1 change, but get %d", len(changes))
+		}
+		change := changes[0]
+		if change.FileName != "new.go" || change.OldFileName != "old.go" || change.Mode != RenameMode || len(change.Sections) != 1 {
+			t.Fatalf("unexpected change: %+v", change)
+		}
+		if change.Sections[0].StartLine != 8 || change.Sections[0].EndLine != 9 {
+			t.Errorf("unexpected section: %+v", change.Sections[0])
+		}
+	})
+}
+
+func TestSimilarity(t *testing.T) {
+	testSuites := []struct {
+		src, dst string
+		expected uint
+	}{
+		{src: "", dst: "", expected: 100},
+		{src: "a\nb\n", dst: "a\nb\n", expected: 100},
+		{src: "a\nb\n", dst: "c\nd\n", expected: 0},
+		{src: "a\nb\nc\n", dst: "a\nb\nd\n", expected: 66},
+		{src: "a\nb\n", dst: "a\nb\nc\nd\n", expected: 66},
+	}
+	for _, testSuite := range testSuites {
+		if actual := similarity(testSuite.src, testSuite.dst); actual != testSuite.expected {
+			t.Errorf("similarity of %q and %q should be %d, but get %d", testSuite.src, testSuite.dst, testSuite.expected, actual)
+		}
+	}
+}
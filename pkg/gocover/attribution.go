@@ -0,0 +1,445 @@
+package gocover
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/gocover/pkg/parser"
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/tools/cover"
+)
+
+// AttributionGranularity is how the tests are run for attributing the coverage to them.
+type AttributionGranularity string
+
+const (
+	// TestAttribution runs each test with its own cover profile.
+	TestAttribution AttributionGranularity = "test"
+	// PackageAttribution runs the tests of each package with its own cover profile.
+	PackageAttribution AttributionGranularity = "package"
+
+	// attributionIndexFile is the file name of the attribution index in the output directory.
+	attributionIndexFile = "attribution.json"
+)
+
+var (
+	ErrUnknownAttributionGranularity = errors.New("unknown attribution granularity")
+	ErrAttributionNotSupported       = errors.New("coverage attribution is only supported by the go executor of a single module")
+	// ErrInvalidLocation indicates the location of who-covers is not in the form of file:line.
+	ErrInvalidLocation = errors.New("invalid location, expect file:line")
+	// ErrFileNotInIndex indicates the file of the location is not covered by any test of the index.
+	ErrFileNotInIndex = errors.New("file not found in attribution index")
+	// ErrAmbiguousFile indicates the file of the location matches several files of the index.
+	ErrAmbiguousFile = errors.New("file matches several files in attribution index")
+	// ErrNoAttributedCoverProfile indicates none of the tests run for attribution produces a cover profile.
+	ErrNoAttributedCoverProfile = errors.New("no cover profile was produced by any test")
+)
+
+// testNamePattern matches the names listed by `go test -list`, benchmarks are not run by `go test`.
+var testNamePattern = regexp.MustCompile(`^(Test|Example|Fuzz)[\p{L}\p{N}_]*$`)
+
+// validateAttributionGranularity checks the granularity, empty granularity means no attribution.
+func validateAttributionGranularity(granularity AttributionGranularity) error {
+	switch granularity {
+	case "", TestAttribution, PackageAttribution:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownAttributionGranularity, granularity)
+	}
+}
+
+// AttributionIndex is the index from the covered blocks of the cover profiles to the tests that cover them.
+type AttributionIndex struct {
+	// Granularity is how the tests are run, the tests are named as package.TestName for test granularity,
+	// and as the package import path for package granularity.
+	Granularity AttributionGranularity `json:"granularity"`
+	// Files are the covered blocks of each file, keyed by the file name of the cover profiles.
+	Files map[string][]*AttributedBlock `json:"files"`
+
+	// blocks are the blocks of Files keyed by file and position, so a block is found in constant time while the index is built
+	blocks map[string]map[blockPosition]*AttributedBlock
+}
+
+// AttributedBlock is a block of the cover profile and the tests that cover it.
+type AttributedBlock struct {
+	StartLine int      `json:"startLine"`
+	StartCol  int      `json:"startCol"`
+	EndLine   int      `json:"endLine"`
+	EndCol    int      `json:"endCol"`
+	Tests     []string `json:"tests"`
+}
+
+func newAttributionIndex(granularity AttributionGranularity) *AttributionIndex {
+	return &AttributionIndex{
+		Granularity: granularity,
+		Files:       make(map[string][]*AttributedBlock),
+		blocks:      make(map[string]map[blockPosition]*AttributedBlock),
+	}
+}
+
+// add attributes the covered blocks of the cover profiles to the test.
+func (idx *AttributionIndex) add(test string, profiles []*cover.Profile) {
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			if b.Count == 0 {
+				continue
+			}
+			block := idx.findOrCreate(p.FileName, b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+			block.Tests = append(block.Tests, test)
+		}
+	}
+}
+
+// findOrCreate returns the block at the position, the blocks of a file are not in order until sort is called.
+func (idx *AttributionIndex) findOrCreate(file string, startLine, startCol, endLine, endCol int) *AttributedBlock {
+	blocks, ok := idx.blocks[file]
+	if !ok {
+		blocks = make(map[blockPosition]*AttributedBlock)
+		idx.blocks[file] = blocks
+	}
+	pos := blockPosition{startLine: startLine, startCol: startCol, endLine: endLine, endCol: endCol}
+	if block, ok := blocks[pos]; ok {
+		return block
+	}
+	block := &AttributedBlock{StartLine: startLine, StartCol: startCol, EndLine: endLine, EndCol: endCol}
+	blocks[pos] = block
+	idx.Files[file] = append(idx.Files[file], block)
+	return block
+}
+
+// sort sorts the blocks by position and the tests by name, so the index file is stable.
+func (idx *AttributionIndex) sort() {
+	for _, blocks := range idx.Files {
+		sort.Slice(blocks, func(i, j int) bool {
+			if blocks[i].StartLine != blocks[j].StartLine {
+				return blocks[i].StartLine < blocks[j].StartLine
+			}
+			if blocks[i].StartCol != blocks[j].StartCol {
+				return blocks[i].StartCol < blocks[j].StartCol
+			}
+			if blocks[i].EndLine != blocks[j].EndLine {
+				return blocks[i].EndLine < blocks[j].EndLine
+			}
+			return blocks[i].EndCol < blocks[j].EndCol
+		})
+		for _, block := range blocks {
+			sort.Strings(block.Tests)
+		}
+	}
+}
+
+// TestsOfLine returns the sorted tests that cover any block of the file containing the line.
+func (idx *AttributionIndex) TestsOfLine(file string, line int) []string {
+	tests := make(map[string]bool)
+	for _, block := range idx.Files[file] {
+		if block.StartLine <= line && line <= block.EndLine {
+			for _, test := range block.Tests {
+				tests[test] = true
+			}
+		}
+	}
+	return sortedKeys(tests)
+}
+
+// findFile returns the file of the index that is the name or ends with the name, such as a path relative to the module.
+func (idx *AttributionIndex) findFile(name string) (string, error) {
+	name = path.Clean(filepath.ToSlash(name))
+	if _, ok := idx.Files[name]; ok {
+		return name, nil
+	}
+
+	var matched []string
+	for file := range idx.Files {
+		if strings.HasSuffix(file, "/"+strings.TrimPrefix(name, "./")) {
+			matched = append(matched, file)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrFileNotInIndex, name)
+	case 1:
+		return matched[0], nil
+	default:
+		sort.Strings(matched)
+		return "", fmt.Errorf("%w: %s", ErrAmbiguousFile, strings.Join(matched, ", "))
+	}
+}
+
+// writeAttributionIndex writes the index into the json file.
+func writeAttributionIndex(filename string, idx *AttributionIndex) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal attribution index: %w", err)
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// readAttributionIndex reads the index from the json file.
+func readAttributionIndex(filename string) (*AttributionIndex, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	idx := &AttributionIndex{}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("unmarshal attribution index: %w", err)
+	}
+	return idx, nil
+}
+
+// WhoCovers writes the tests that cover the location of the attribution index into w,
+// the location is file:line, file is the file name of the cover profiles or the end of it, such as pkg/foo/foo.go.
+func WhoCovers(indexFile string, location string, w io.Writer) error {
+	i := strings.LastIndex(location, ":")
+	if i <= 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidLocation, location)
+	}
+	line, err := strconv.Atoi(location[i+1:])
+	if err != nil || line <= 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidLocation, location)
+	}
+
+	idx, err := readAttributionIndex(indexFile)
+	if err != nil {
+		return fmt.Errorf("read attribution index: %w", err)
+	}
+	file, err := idx.findFile(location[:i])
+	if err != nil {
+		return err
+	}
+
+	tests := idx.TestsOfLine(file, line)
+	if len(tests) == 0 {
+		_, err := fmt.Fprintf(w, "%s:%d is not covered by any %s\n", file, line, idx.Granularity)
+		return err
+	}
+	for _, test := range tests {
+		if _, err := fmt.Fprintln(w, test); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachCoveringTests attaches the tests that cover the statements of the coverage profiles,
+// the consecutive statements covered by the same tests are merged into one range.
+func attachCoveringTests(statistics *report.Statistics, idx *AttributionIndex) {
+	if idx == nil {
+		return
+	}
+	for _, coverProfile := range statistics.CoverageProfile {
+		var last *report.CoveringTests
+		for _, fun := range coverProfile.Functions {
+			for _, st := range fun.Statements {
+				if st.Reached == 0 {
+					last = nil
+					continue
+				}
+				tests := idx.TestsOfLine(coverProfile.FileName, st.StartLine)
+				if len(tests) == 0 {
+					continue
+				}
+				if last != nil && strings.Join(last.Tests, "\n") == strings.Join(tests, "\n") {
+					last.EndLine = st.EndLine
+					continue
+				}
+				last = &report.CoveringTests{StartLine: st.StartLine, EndLine: st.EndLine, Tests: tests}
+				coverProfile.CoveringTests = append(coverProfile.CoveringTests, last)
+			}
+			last = nil
+		}
+	}
+}
+
+// attributionUnit is a run of the tests with its own cover profile.
+type attributionUnit struct {
+	// name is the name of the unit in the attribution index.
+	name string
+	pkg  string
+	// run is the -run pattern of the test, empty for all the tests of the package.
+	run string
+}
+
+// attributedCoverProfiles runs the tests unit by unit, each unit is a test or the tests of a package with its own cover profile,
+// and builds the attribution index from the cover profiles. The cover profiles are merged into one for the report.
+func (t *goBuiltInTestExecutor) attributedCoverProfiles(ctx context.Context, packages []string, goFlags []string, logger logrus.FieldLogger) ([]string, error) {
+	workingDir := filepath.Join(t.repositoryPath, t.moduleDir)
+	tests, err := t.listTests(ctx, packages, goFlags)
+	if err != nil {
+		return nil, WrapErrorWithCode(fmt.Errorf("list tests: %w", err), UnitTestFailedErrorExitCode, "")
+	}
+
+	var units []*attributionUnit
+	for _, pkg := range tests {
+		if t.option.Attribution == PackageAttribution {
+			units = append(units, &attributionUnit{name: pkg.pkg, pkg: pkg.pkg})
+			continue
+		}
+		for _, test := range pkg.tests {
+			units = append(units, &attributionUnit{name: pkg.pkg + "." + test, pkg: pkg.pkg, run: "^" + test + "$"})
+		}
+	}
+
+	profileDir := filepath.Join(t.outputDir, "attribution")
+	if err := os.MkdirAll(profileDir, 0755); err != nil {
+		return nil, fmt.Errorf("create attribution directory: %w", err)
+	}
+	defer os.RemoveAll(profileDir)
+
+	logger.Infof("run %d units of tests for %s attribution", len(units), t.option.Attribution)
+	idx := newAttributionIndex(t.option.Attribution)
+	var profiles []string
+	var results []*report.TestResults
+	failed := false
+	for i, unit := range units {
+		coverFile := filepath.Join(profileDir, fmt.Sprintf("%d.out", i))
+		goArgs := []string{"test", unit.pkg}
+		if unit.run != "" {
+			goArgs = append(goArgs, "-run", unit.run)
+		}
+		goArgs = append(goArgs, goFlags...)
+		goArgs = append(goArgs, "-coverprofile", coverFile, "-coverpkg=./...", "-json")
+
+		events := newTestEventWriter(t.stdout)
+		cmd := newCommand(ctx, t.executable, goArgs...)
+		cmd.Dir = workingDir
+		cmd.Stdout = events
+		cmd.Stderr = t.stderr
+		logger.Debugf("run unit tests: '%s'", cmd.String())
+		if err := cmd.Run(); err != nil {
+			t.logger.WithError(err).Errorf(`run unit test '%s'`, cmd.String())
+			failed = true
+		}
+		_ = events.Close()
+		results = append(results, events.results)
+		if err := contextError(ctx, func() []string { return []string{unit.name} }); err != nil {
+			t.results = mergeTestResults(results)
+			return nil, err
+		}
+
+		if _, err := os.Stat(coverFile); err != nil {
+			continue
+		}
+		unitProfiles, err := parser.ParseCoverProfiles([]string{coverFile})
+		if err != nil {
+			return nil, fmt.Errorf("parse cover profile of %s: %w", unit.name, err)
+		}
+		idx.add(unit.name, unitProfiles)
+		profiles = append(profiles, coverFile)
+	}
+	idx.sort()
+	t.results = mergeTestResults(results)
+	t.attribution = idx
+
+	indexFile := filepath.Join(t.outputDir, attributionIndexFile)
+	if err := writeAttributionIndex(indexFile, idx); err != nil {
+		return nil, fmt.Errorf("write attribution index: %w", err)
+	}
+	logger.Infof("attribution index: %s", indexFile)
+
+	var coverFile string
+	if len(profiles) != 0 {
+		if coverFile, err = mergeCoverProfiles(t.outputDir, profiles); err != nil {
+			return nil, fmt.Errorf("merge cover profiles: %w", err)
+		}
+	}
+	if failed {
+		if message := failedTestsMessage(t.results, maxFailedTestsInMessage); message != "" {
+			return nil, WrapErrorWithCode(fmt.Errorf("unit test failed: %s", message), UnitTestFailedErrorExitCode, "")
+		}
+		return nil, WrapErrorWithCode(errors.New("unit test failed"), UnitTestFailedErrorExitCode, "")
+	}
+	if coverFile == "" {
+		return nil, ErrNoAttributedCoverProfile
+	}
+	return []string{coverFile}, nil
+}
+
+// packageTests are the tests of a package.
+type packageTests struct {
+	pkg   string
+	tests []string
+}
+
+// listTests lists the tests of the packages with `go test -list`, the packages without tests are not listed.
+func (t *goBuiltInTestExecutor) listTests(ctx context.Context, packages []string, goFlags []string) ([]*packageTests, error) {
+	goArgs := []string{"test", "-list", "."}
+	goArgs = append(goArgs, packages...)
+	goArgs = append(goArgs, goFlags...)
+	goArgs = append(goArgs, "-json")
+
+	var stdout, stderr bytes.Buffer
+	cmd := newCommand(ctx, t.executable, goArgs...)
+	cmd.Dir = filepath.Join(t.repositoryPath, t.moduleDir)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s%s", err, stdout.String(), stderr.String())
+	}
+	return decodeTestList(&stdout)
+}
+
+// decodeTestList decodes the events of `go test -list -json`, the test names are the output lines of the packages.
+func decodeTestList(r io.Reader) ([]*packageTests, error) {
+	var result []*packageTests
+	m := make(map[string]*packageTests)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Action != "output" || event.Test != "" {
+			continue
+		}
+		name := strings.TrimSpace(event.Output)
+		if !testNamePattern.MatchString(name) {
+			continue
+		}
+		pkg, ok := m[event.Package]
+		if !ok {
+			pkg = &packageTests{pkg: event.Package}
+			m[event.Package] = pkg
+			result = append(result, pkg)
+		}
+		pkg.tests = append(pkg.tests, name)
+	}
+	return result, scanner.Err()
+}
+
+// mergeTestResults merges the results of the runs by package, the package fails if it fails in any run.
+func mergeTestResults(results []*report.TestResults) *report.TestResults {
+	merged := &report.TestResults{}
+	m := make(map[string]*report.PackageTestResult)
+	for _, r := range results {
+		for _, pkg := range r.Packages {
+			result, ok := m[pkg.Package]
+			if !ok {
+				result = &report.PackageTestResult{Package: pkg.Package, Result: pkg.Result}
+				m[pkg.Package] = result
+				merged.Packages = append(merged.Packages, result)
+			}
+			switch {
+			case pkg.Result == report.TestFail:
+				result.Result = report.TestFail
+			case pkg.Result == report.TestPass && result.Result == report.TestSkip:
+				result.Result = report.TestPass
+			}
+			result.Elapsed += pkg.Elapsed
+			result.Output += pkg.Output
+			result.Tests = append(result.Tests, pkg.Tests...)
+		}
+	}
+	return merged
+}
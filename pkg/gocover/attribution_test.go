@@ -0,0 +1,178 @@
+package gocover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/cover"
+)
+
+func newTestAttributionIndex() *AttributionIndex {
+	idx := newAttributionIndex(TestAttribution)
+	idx.add("example.com/foo.TestFoo", []*cover.Profile{{
+		FileName: "example.com/foo/foo.go",
+		Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 2, EndLine: 3, EndCol: 10, NumStmt: 1, Count: 1},
+			{StartLine: 4, StartCol: 3, EndLine: 5, EndCol: 1, NumStmt: 1, Count: 1},
+			{StartLine: 6, StartCol: 2, EndLine: 6, EndCol: 10, NumStmt: 1, Count: 0},
+		},
+	}})
+	idx.add("example.com/foo.TestBar", []*cover.Profile{{
+		FileName: "example.com/foo/foo.go",
+		Blocks: []cover.ProfileBlock{
+			{StartLine: 3, StartCol: 2, EndLine: 3, EndCol: 10, NumStmt: 1, Count: 1},
+			{StartLine: 6, StartCol: 2, EndLine: 6, EndCol: 10, NumStmt: 1, Count: 2},
+		},
+	}})
+	idx.sort()
+	return idx
+}
+
+func TestAttributionIndex(t *testing.T) {
+	idx := newTestAttributionIndex()
+
+	assert.Len(t, idx.Files["example.com/foo/foo.go"], 3)
+	assert.Equal(t, []string{"example.com/foo.TestBar", "example.com/foo.TestFoo"}, idx.TestsOfLine("example.com/foo/foo.go", 3))
+	assert.Equal(t, []string{"example.com/foo.TestFoo"}, idx.TestsOfLine("example.com/foo/foo.go", 5))
+	assert.Equal(t, []string{"example.com/foo.TestBar"}, idx.TestsOfLine("example.com/foo/foo.go", 6))
+	assert.Empty(t, idx.TestsOfLine("example.com/foo/foo.go", 7))
+
+	t.Run("blocks are sorted by position", func(t *testing.T) {
+		idx := newAttributionIndex(TestAttribution)
+		idx.add("example.com/foo.TestFoo", []*cover.Profile{{
+			FileName: "example.com/foo/foo.go",
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 6, StartCol: 2, EndLine: 6, EndCol: 10, NumStmt: 1, Count: 1},
+				{StartLine: 3, StartCol: 2, EndLine: 3, EndCol: 10, NumStmt: 1, Count: 1},
+			},
+		}})
+		idx.add("example.com/foo.TestBar", []*cover.Profile{{
+			FileName: "example.com/foo/foo.go",
+			Blocks: []cover.ProfileBlock{
+				{StartLine: 6, StartCol: 2, EndLine: 6, EndCol: 10, NumStmt: 1, Count: 1},
+			},
+		}})
+		idx.sort()
+
+		blocks := idx.Files["example.com/foo/foo.go"]
+		if assert.Len(t, blocks, 2) {
+			assert.Equal(t, 3, blocks[0].StartLine)
+			assert.Equal(t, []string{"example.com/foo.TestBar", "example.com/foo.TestFoo"}, blocks[1].Tests)
+		}
+	})
+
+	t.Run("find file", func(t *testing.T) {
+		idx := newTestAttributionIndex()
+		idx.Files["example.com/bar/foo.go"] = nil
+
+		file, err := idx.findFile("./foo/foo.go")
+		assert.NoError(t, err)
+		assert.Equal(t, "example.com/foo/foo.go", file)
+
+		_, err = idx.findFile("foo.go")
+		assert.True(t, errors.Is(err, ErrAmbiguousFile))
+
+		_, err = idx.findFile("zoo.go")
+		assert.True(t, errors.Is(err, ErrFileNotInIndex))
+	})
+}
+
+func TestWhoCovers(t *testing.T) {
+	indexFile := filepath.Join(t.TempDir(), attributionIndexFile)
+	assert.NoError(t, writeAttributionIndex(indexFile, newTestAttributionIndex()))
+
+	var buf bytes.Buffer
+	assert.NoError(t, WhoCovers(indexFile, "foo/foo.go:3", &buf))
+	assert.Equal(t, "example.com/foo.TestBar\nexample.com/foo.TestFoo\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, WhoCovers(indexFile, "example.com/foo/foo.go:7", &buf))
+	assert.Equal(t, "example.com/foo/foo.go:7 is not covered by any test\n", buf.String())
+
+	for _, location := range []string{"foo.go", "foo.go:x", ":3", "foo.go:0"} {
+		assert.True(t, errors.Is(WhoCovers(indexFile, location, &buf), ErrInvalidLocation), location)
+	}
+}
+
+func TestAttachCoveringTests(t *testing.T) {
+	statistics := &report.Statistics{CoverageProfile: []*report.CoverageProfile{{
+		FileName: "example.com/foo/foo.go",
+		Functions: []*report.FunctionProfile{{
+			Statements: []*report.StatementProfile{
+				{StartLine: 3, EndLine: 3, Reached: 1},
+				{StartLine: 4, EndLine: 4, Reached: 1},
+				{StartLine: 5, EndLine: 5, Reached: 1},
+				{StartLine: 6, EndLine: 6, Reached: 1},
+			},
+		}},
+	}}}
+	idx := newTestAttributionIndex()
+	// the statement of line 4 is covered by the same tests as line 5
+	idx.Files["example.com/foo/foo.go"][1].StartLine = 4
+
+	attachCoveringTests(statistics, idx)
+	assert.Equal(t, []*report.CoveringTests{
+		{StartLine: 3, EndLine: 3, Tests: []string{"example.com/foo.TestBar", "example.com/foo.TestFoo"}},
+		{StartLine: 4, EndLine: 5, Tests: []string{"example.com/foo.TestFoo"}},
+		{StartLine: 6, EndLine: 6, Tests: []string{"example.com/foo.TestBar"}},
+	}, statistics.CoverageProfile[0].CoveringTests)
+}
+
+func TestDecodeTestList(t *testing.T) {
+	output := `{"Action":"start","Package":"example.com/foo"}
+{"Action":"output","Package":"example.com/foo","Output":"TestFoo\n"}
+{"Action":"output","Package":"example.com/foo","Output":"ExampleFoo\n"}
+{"Action":"output","Package":"example.com/foo","Output":"BenchmarkFoo\n"}
+{"Action":"output","Package":"example.com/foo","Output":"ok  \texample.com/foo\t0.003s\n"}
+{"Action":"pass","Package":"example.com/foo","Elapsed":0.003}
+{"Action":"output","Package":"example.com/bar","Output":"?   \texample.com/bar\t[no test files]\n"}
+`
+	tests, err := decodeTestList(strings.NewReader(output))
+	assert.NoError(t, err)
+	if assert.Len(t, tests, 1) {
+		assert.Equal(t, &packageTests{pkg: "example.com/foo", tests: []string{"TestFoo", "ExampleFoo"}}, tests[0])
+	}
+}
+
+func TestMergeTestResults(t *testing.T) {
+	merged := mergeTestResults([]*report.TestResults{
+		{Packages: []*report.PackageTestResult{{Package: "example.com/foo", Result: report.TestPass, Elapsed: 1, Tests: []*report.TestResult{{Name: "TestFoo", Result: report.TestPass}}}}},
+		{Packages: []*report.PackageTestResult{{Package: "example.com/foo", Result: report.TestFail, Elapsed: 2, Tests: []*report.TestResult{{Name: "TestBar", Result: report.TestFail}}}}},
+		{Packages: []*report.PackageTestResult{{Package: "example.com/bar", Result: report.TestSkip}}},
+	})
+	if assert.Len(t, merged.Packages, 2) {
+		assert.Equal(t, report.TestFail, merged.Packages[0].Result)
+		assert.Equal(t, float64(3), merged.Packages[0].Elapsed)
+		assert.Len(t, merged.Packages[0].Tests, 2)
+		assert.Equal(t, report.TestSkip, merged.Packages[1].Result)
+	}
+}
+
+func TestAttributedCoverProfiles_NoCoverProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeWorkspaceFile(t, dir, "go.mod", "module example.com/root\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "a/a.go", "package a\n\nfunc A() int { return 1 }\n")
+
+	var buf bytes.Buffer
+	executor := &goBuiltInTestExecutor{
+		repositoryPath: dir,
+		mode:           FullCoverage,
+		executable:     goCmd(),
+		outputDir:      t.TempDir(),
+		option:         &GoCoverTestOption{Attribution: TestAttribution},
+		stdout:         &buf,
+		stderr:         &buf,
+		logger:         logrus.New(),
+	}
+
+	// the package has no tests, so no unit is run
+	_, err := executor.attributedCoverProfiles(context.Background(), []string{"./..."}, nil, executor.logger)
+	assert.ErrorIs(t, err, ErrNoAttributedCoverProfile)
+}
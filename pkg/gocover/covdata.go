@@ -0,0 +1,72 @@
+package gocover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrConvertCoverDir indicates the binary coverage data directory can't be converted into text cover profile.
+var ErrConvertCoverDir = errors.New("convert binary coverage data")
+
+// resolveCoverProfiles returns the text cover profiles that parser.Parser consumes.
+// The directories in coverProfiles are taken as GOCOVERDIR, that contains the covmeta and covcounters files
+// written by the binaries built with `go build -cover`, they are converted into a single text cover profile
+// with `go tool covdata textfmt`, which requires go 1.20 or later.
+// The returned function removes the converted cover profile.
+func resolveCoverProfiles(ctx context.Context, coverProfiles []string) ([]string, func(), error) {
+	var profiles, coverDirs []string
+	for _, p := range coverProfiles {
+		// missing files are reported when parsing the profiles
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			coverDirs = append(coverDirs, p)
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	if len(coverDirs) == 0 {
+		return profiles, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "gocover-covdata")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create temporary directory: %w", err)
+	}
+	clean := func() { _ = os.RemoveAll(dir) }
+
+	output := filepath.Join(dir, outCoverageProfile)
+	if err := convertCoverDirs(ctx, coverDirs, output); err != nil {
+		clean()
+		return nil, nil, err
+	}
+	return append(profiles, output), clean, nil
+}
+
+// convertCoverDirs merges the binary coverage data of the directories into the output text cover profile.
+func convertCoverDirs(ctx context.Context, coverDirs []string, output string) error {
+	for _, dir := range coverDirs {
+		// a covmeta file is written for each binary that writes coverage data
+		metas, err := filepath.Glob(filepath.Join(dir, "covmeta.*"))
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrConvertCoverDir, err)
+		}
+		if len(metas) == 0 {
+			return fmt.Errorf("%w: no coverage data found in %s", ErrConvertCoverDir, dir)
+		}
+	}
+
+	args := []string{"tool", "covdata", "textfmt", "-i=" + strings.Join(coverDirs, ","), "-o=" + output}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, goCmd(), args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: go %s: %v: %s", ErrConvertCoverDir, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
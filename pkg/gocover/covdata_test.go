@@ -0,0 +1,97 @@
+package gocover
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestResolveCoverProfiles(t *testing.T) {
+	t.Run("text cover profiles are kept", func(t *testing.T) {
+		profiles, clean, err := resolveCoverProfiles(context.Background(), []string{"coverage.out", "nonexist.out"})
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		defer clean()
+		if strings.Join(profiles, ",") != "coverage.out,nonexist.out" {
+			t.Errorf("unexpected profiles: %v", profiles)
+		}
+	})
+
+	t.Run("empty cover dir", func(t *testing.T) {
+		_, _, err := resolveCoverProfiles(context.Background(), []string{t.TempDir()})
+		if !errors.Is(err, ErrConvertCoverDir) {
+			t.Errorf("should return ErrConvertCoverDir, but get: %v", err)
+		}
+	})
+
+	t.Run("convert cover dir", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skip building binary in short mode")
+		}
+
+		dir := t.TempDir()
+		writeFile := func(name, contents string) {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		writeFile("go.mod", "module example.com/foo\n\ngo 1.20\n")
+		writeFile("main.go", "package main\n\nfunc main() {\n\tif len(\"foo\") > 5 {\n\t\tprintln()\n\t}\n}\n")
+
+		binary := filepath.Join(dir, "foo")
+		build := exec.Command(goCmd(), "build", "-cover", "-o", binary, ".")
+		build.Dir = dir
+		if out, err := build.CombinedOutput(); err != nil {
+			t.Fatalf("build binary: %s: %s", err, out)
+		}
+
+		coverDir := filepath.Join(dir, "covdata")
+		if err := os.Mkdir(coverDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		run := exec.Command(binary)
+		run.Env = append(os.Environ(), "GOCOVERDIR="+coverDir)
+		if out, err := run.CombinedOutput(); err != nil {
+			t.Fatalf("run binary: %s: %s", err, out)
+		}
+
+		profiles, clean, err := resolveCoverProfiles(context.Background(), []string{coverDir})
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if len(profiles) != 1 {
+			t.Fatalf("should have 1 profile, but get %v", profiles)
+		}
+
+		p, err := cover.ParseProfiles(profiles[0])
+		if err != nil {
+			t.Fatalf("parse converted profile: %s", err)
+		}
+		if len(p) != 1 || p[0].FileName != "example.com/foo/main.go" {
+			t.Fatalf("unexpected profiles: %+v", p)
+		}
+		var covered, uncovered int
+		for _, b := range p[0].Blocks {
+			if b.Count > 0 {
+				covered++
+			} else {
+				uncovered++
+			}
+		}
+		if covered == 0 || uncovered == 0 {
+			t.Errorf("should have both covered and uncovered blocks, but get %+v", p[0].Blocks)
+		}
+
+		clean()
+		if _, err := os.Stat(profiles[0]); !os.IsNotExist(err) {
+			t.Errorf("converted profile should be removed, but get: %v", err)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package gocover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrCustomCommandRequired      = errors.New("custom command is required by the custom executor")
+	ErrCustomCoverProfileRequired = errors.New("cover profile pattern is required by the custom executor")
+	ErrNoCoverProfileFound        = errors.New("no cover profile found")
+	ErrJUnitFileNotSupported      = errors.New("junit file is not supported by the custom executor, use the junit report of the test harness instead")
+)
+
+// customCommandData is the data of the templates of the custom command and the cover profile patterns.
+type customCommandData struct {
+	// OutputDir is the absolute path of the output directory.
+	OutputDir string
+	// ModuleDir is the absolute path of the module directory, where the command runs.
+	ModuleDir string
+}
+
+// validateCustomExecutor checks the options of the custom executor.
+func validateCustomExecutor(o *GoCoverTestOption) error {
+	if strings.TrimSpace(o.CustomCommand) == "" {
+		return ErrCustomCommandRequired
+	}
+	if len(o.CustomCoverProfiles) == 0 {
+		return ErrCustomCoverProfileRequired
+	}
+	if o.JUnitFile != "" {
+		return ErrJUnitFileNotSupported
+	}
+	return nil
+}
+
+// customTestExecutor runs the tests with the command of the user, such as gotestsum or a make target,
+// and collects the cover profiles written by it with glob patterns.
+type customTestExecutor struct {
+	repositoryPath string
+	moduleDir      string
+	mode           CoverageMode
+	command        string
+	// profilePatterns are the doublestar patterns of the cover profiles, relative to the module directory if not absolute
+	profilePatterns []string
+	outputDir       string
+	option          *GoCoverTestOption
+	stdout          io.Writer
+	stderr          io.Writer
+	logger          logrus.FieldLogger
+}
+
+var _ moduleTestExecutor = (*customTestExecutor)(nil)
+
+func newCustomTestExecutor(o *GoCoverTestOption, repositoryAbsPath string) (*customTestExecutor, error) {
+	if err := validateCustomExecutor(o); err != nil {
+		return nil, err
+	}
+	outputDir, err := filepath.Abs(o.OutputDir)
+	if err != nil {
+		return nil, fmt.Errorf("get absolute path of output directory: %w", err)
+	}
+	data := &customCommandData{
+		OutputDir: outputDir,
+		ModuleDir: filepath.Join(repositoryAbsPath, o.ModuleDir),
+	}
+
+	command, err := renderCustomTemplate(o.CustomCommand, data)
+	if err != nil {
+		return nil, fmt.Errorf("custom command: %w", err)
+	}
+	var patterns []string
+	for _, p := range o.CustomCoverProfiles {
+		pattern, err := renderCustomTemplate(p, data)
+		if err != nil {
+			return nil, fmt.Errorf("cover profile pattern: %w", err)
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(data.ModuleDir, pattern)
+		}
+		if !doublestar.ValidatePathPattern(pattern) {
+			return nil, fmt.Errorf("invalid cover profile pattern: %s", p)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return &customTestExecutor{
+		repositoryPath:  repositoryAbsPath,
+		moduleDir:       o.ModuleDir,
+		mode:            o.CoverageMode,
+		command:         command,
+		profilePatterns: patterns,
+		outputDir:       outputDir,
+		option:          o,
+		stdout:          o.StdOut,
+		stderr:          o.StdErr,
+		logger:          o.Logger.WithField("source", "GoCoverTest"),
+	}, nil
+}
+
+// renderCustomTemplate renders the placeholders of the text, such as {{.OutputDir}} and {{.ModuleDir}}.
+func renderCustomTemplate(text string, data *customCommandData) (string, error) {
+	tmpl, err := template.New("custom").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (e *customTestExecutor) Run(ctx context.Context) error {
+	coverFiles, err := e.coverProfiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	gocover, err := buildGoCover(e.mode, e.option, &testRun{coverProfiles: coverFiles}, e.logger)
+	if err != nil {
+		return err
+	}
+
+	e.logger.Infof("cover profile: %s", strings.Join(coverFiles, ", "))
+	if err := gocover.Run(ctx); err != nil {
+		err := fmt.Errorf("run gocover: %w", err)
+		e.logger.WithError(err).Error()
+		return err
+	}
+
+	return nil
+}
+
+// coverProfiles runs the custom command in the module directory, and merges the cover profiles that match the patterns
+// into the output directory.
+func (e *customTestExecutor) coverProfiles(ctx context.Context) ([]string, error) {
+	workingDir := filepath.Join(e.repositoryPath, e.moduleDir)
+	logger := e.logger.WithFields(logrus.Fields{
+		"moduledir":  e.moduleDir,
+		"workingdir": workingDir,
+		"executor":   "custom",
+	})
+
+	// the command usually writes the cover profiles into the output directory
+	if err := os.MkdirAll(e.outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("create output directory: %w", err)
+	}
+	// remove the cover profile of the last run, so a stale one is not collected by the patterns
+	_ = os.Remove(filepath.Join(e.outputDir, outCoverageProfile))
+
+	// the cover profiles written before the command starts are left by the last run, they are not collected,
+	// the modification time is truncated as some file systems only keep it in seconds
+	start := time.Now().Truncate(time.Second)
+	cmd := newCommand(ctx, shell[0], append(shell[1:], e.command)...)
+	cmd.Dir = workingDir
+	cmd.Stdin = nil
+	cmd.Stdout = e.stdout
+	cmd.Stderr = e.stderr
+	logger.Infof("executing cmd: %s", e.command)
+	if err := cmd.Run(); err != nil {
+		logger.WithError(err).Errorf(`executing cmd %s`, e.command)
+		if err := contextError(ctx, func() []string { return []string{fmt.Sprintf("'%s' in %s", e.command, workingDir)} }); err != nil {
+			return nil, err
+		}
+		return nil, WrapErrorWithCode(fmt.Errorf("unit test failed: %w", err), UnitTestFailedErrorExitCode, "")
+	}
+
+	coverFiles, err := globCoverProfiles(e.profilePatterns, start, logger)
+	if err != nil {
+		return nil, err
+	}
+	if len(coverFiles) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoCoverProfileFound, strings.Join(e.profilePatterns, ", "))
+	}
+	logger.Debugf("total: %d", len(coverFiles))
+	for _, f := range coverFiles {
+		logger.Debugf("%s", f)
+	}
+
+	mergedFile, err := mergeCoverProfiles(e.outputDir, coverFiles)
+	if err != nil {
+		return nil, fmt.Errorf("merge cover profiles: %w", err)
+	}
+	return []string{mergedFile}, nil
+}
+
+// testResults returns nil as the output of the custom command is unknown.
+func (e *customTestExecutor) testResults() *report.TestResults {
+	return nil
+}
+
+// shell runs the custom command, so the command can be a pipeline or use the environment variables.
+var shell = func() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C"}
+	}
+	return []string{"sh", "-c"}
+}()
+
+// globCoverProfiles returns the files that match any of the patterns and are modified since the time,
+// each file is returned once.
+func globCoverProfiles(patterns []string, since time.Time, logger logrus.FieldLogger) ([]string, error) {
+	files := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := doublestar.FilepathGlob(pattern, doublestar.WithFilesOnly())
+		if err != nil {
+			return nil, fmt.Errorf("glob cover profiles %s: %w", pattern, err)
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("stat cover profile: %w", err)
+			}
+			if info.ModTime().Before(since) {
+				logger.Warnf("skip cover profile %s, it's not written by the command", match)
+				continue
+			}
+			files[match] = true
+		}
+	}
+	result := make([]string, 0, len(files))
+	for f := range files {
+		result = append(result, f)
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package gocover
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomTestExecutor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are written for sh")
+	}
+
+	newOption := func(repo, command string, profiles ...string) *GoCoverTestOption {
+		var buf bytes.Buffer
+		return &GoCoverTestOption{
+			RepositoryPath:      repo,
+			ModuleDir:           "module",
+			OutputDir:           filepath.Join(repo, "out"),
+			CoverageMode:        FullCoverage,
+			ExecutorMode:        CustomExecutor,
+			CustomCommand:       command,
+			CustomCoverProfiles: profiles,
+			StdOut:              &buf,
+			StdErr:              &buf,
+			Logger:              logrus.New(),
+		}
+	}
+
+	t.Run("collect cover profiles by patterns", func(t *testing.T) {
+		repo := t.TempDir()
+		writeWorkspaceFile(t, repo, "module/go.mod", "module example.com/root\n\ngo 1.20\n")
+		command := `mkdir -p a/b && printf 'mode: set\nexample.com/root/a.go:3.20,3.34 1 1\n' > {{.OutputDir}}/unit.out && ` +
+			`printf 'mode: set\nexample.com/root/a/b/b.go:3.20,3.34 1 0\n' > a/b/b.coverprofile`
+		executor, err := newCustomTestExecutor(newOption(repo, command, "{{.OutputDir}}/*.out", "**/*.coverprofile"), repo)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		profiles, err := executor.coverProfiles(context.Background())
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{filepath.Join(repo, "out", outCoverageProfile)}, profiles)
+			content, err := os.ReadFile(profiles[0])
+			assert.NoError(t, err)
+			assert.Contains(t, string(content), "example.com/root/a.go:3.20,3.34 1 1")
+			assert.Contains(t, string(content), "example.com/root/a/b/b.go:3.20,3.34 1 0")
+		}
+		assert.Nil(t, executor.testResults())
+	})
+
+	t.Run("command fails", func(t *testing.T) {
+		repo := t.TempDir()
+		writeWorkspaceFile(t, repo, "module/go.mod", "module example.com/root\n\ngo 1.20\n")
+		executor, err := newCustomTestExecutor(newOption(repo, "exit 2", "*.out"), repo)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = executor.coverProfiles(context.Background())
+		var e *GoCoverError
+		if assert.ErrorAs(t, err, &e) {
+			assert.Equal(t, UnitTestFailedErrorExitCode, e.ExitCode)
+		}
+	})
+
+	t.Run("no cover profile found", func(t *testing.T) {
+		repo := t.TempDir()
+		writeWorkspaceFile(t, repo, "module/go.mod", "module example.com/root\n\ngo 1.20\n")
+		executor, err := newCustomTestExecutor(newOption(repo, "true", "*.out"), repo)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = executor.coverProfiles(context.Background())
+		assert.ErrorIs(t, err, ErrNoCoverProfileFound)
+	})
+
+	t.Run("skip cover profiles left by the last run", func(t *testing.T) {
+		repo := t.TempDir()
+		writeWorkspaceFile(t, repo, "module/go.mod", "module example.com/root\n\ngo 1.20\n")
+		writeWorkspaceFile(t, repo, "out/unit.out", "mode: set\nexample.com/root/a.go:3.20,3.34 1 1\n")
+		lastRun := time.Now().Add(-time.Hour)
+		assert.NoError(t, os.Chtimes(filepath.Join(repo, "out", "unit.out"), lastRun, lastRun))
+		executor, err := newCustomTestExecutor(newOption(repo, "true", "{{.OutputDir}}/*.out"), repo)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = executor.coverProfiles(context.Background())
+		assert.ErrorIs(t, err, ErrNoCoverProfileFound)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		repo := t.TempDir()
+		_, err := newCustomTestExecutor(newOption(repo, " ", "*.out"), repo)
+		assert.ErrorIs(t, err, ErrCustomCommandRequired)
+
+		_, err = newCustomTestExecutor(newOption(repo, "make test"), repo)
+		assert.ErrorIs(t, err, ErrCustomCoverProfileRequired)
+
+		_, err = newCustomTestExecutor(newOption(repo, "make test OUT={{.Output}}", "*.out"), repo)
+		assert.Error(t, err)
+
+		option := newOption(repo, "make test", "*.out")
+		option.JUnitFile = "junit.xml"
+		_, err = newCustomTestExecutor(option, repo)
+		assert.ErrorIs(t, err, ErrJUnitFileNotSupported)
+	})
+}
@@ -2,9 +2,12 @@ package gocover
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"go/build"
+	"io"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/Azure/gocover/pkg/annotation"
@@ -15,6 +18,11 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	ErrDiffFileWithUncommitted = errors.New("diff file can not be used with including uncommitted changes")
+	ErrRevisionRangeConflict   = errors.New("revision range can not be used with diff file or including uncommitted changes")
+)
+
 func NewDiffCover(o *DiffOption) (GoCover, error) {
 	var (
 		dbClient dbclient.DbClient
@@ -39,27 +47,73 @@ func NewDiffCover(o *DiffOption) (GoCover, error) {
 		return nil, fmt.Errorf("get absolute path of repo: %w", err)
 	}
 
-	modulePath, err := parseGoModulePath(filepath.Join(repositoryAbsPath, o.ModuleDir))
+	moduleAbsPath := filepath.Join(repositoryAbsPath, o.ModuleDir)
+	ws, err := loadWorkspace(moduleAbsPath, o.Workspace)
 	if err != nil {
-		return nil, fmt.Errorf("parse go module path: %w", err)
+		return nil, fmt.Errorf("load workspace: %w", err)
+	}
+	// a workspace has several modules, the report is rooted at the workspace and each module is a node of it
+	var modulePath string
+	if ws == nil {
+		modulePath, err = parseGoModulePath(moduleAbsPath)
+		if err != nil {
+			return nil, fmt.Errorf("parse go module path: %w", err)
+		}
 	}
 
 	logger.Debugf("repository path: %s, module path: %s, output dir: %s, exclude patterns: %s",
 		repositoryAbsPath, modulePath, o.OutputDir, o.Excludes)
 
+	if o.DiffFile != "" && o.IncludeUncommitted {
+		return nil, ErrDiffFileWithUncommitted
+	}
+	if (o.From != "" || o.To != "") && (o.DiffFile != "" || o.IncludeUncommitted) {
+		return nil, ErrRevisionRangeConflict
+	}
+
+	if err := validateStaleProfilePolicy(o.StaleProfile); err != nil {
+		return nil, err
+	}
+
+	thresholdRules, err := ParseThresholdRules(o.Thresholds)
+	if err != nil {
+		return nil, fmt.Errorf("parse threshold rules: %w", err)
+	}
+
+	reportGenerator, err := report.NewReportGenerator(report.ReportFormat(o.ReportFormat), o.Style, o.OutputDir, o.ReportName, o.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("new report generator: %w", err)
+	}
+
 	return &diffCover{
-		repositoryPath:   repositoryAbsPath,
-		comparedBranch:   o.CompareBranch,
-		moduleDir:        o.ModuleDir,
-		modulePath:       modulePath,
-		excludeFiles:     make(excludeFileCache),
-		excludePatterns:  o.Excludes,
-		coverageTree:     report.NewCoverageTree(modulePath),
-		coverFilenames:   o.CoverProfiles,
-		coverageBaseline: o.CoverageBaseline,
-		dbClient:         dbClient,
-		reportGenerator:  report.NewReportGenerator(o.Style, o.OutputDir, o.ReportName, o.Logger),
-		logger:           logger,
+		repositoryPath:        repositoryAbsPath,
+		comparedBranch:        o.CompareBranch,
+		includeUncommitted:    o.IncludeUncommitted,
+		diffFile:              o.DiffFile,
+		fromRevision:          o.From,
+		toRevision:            o.To,
+		fetchMissing:          o.FetchMissing,
+		stdin:                 os.Stdin,
+		moduleDir:             o.ModuleDir,
+		modulePath:            modulePath,
+		buildFlags:            o.BuildFlags,
+		moduleErrors:          o.ModuleErrors,
+		testResults:           o.TestResults,
+		attribution:           o.Attribution,
+		testSelection:         o.TestSelection,
+		changes:               o.Changes,
+		workspace:             ws,
+		excludeFiles:          make(excludeFileCache),
+		excludePatterns:       o.Excludes,
+		coverageTree:          report.NewCoverageTree(modulePath),
+		coverFilenames:        o.CoverProfiles,
+		compareCoverFilenames: o.CompareCoverProfiles,
+		staleProfilePolicy:    o.StaleProfile,
+		coverageBaseline:      o.CoverageBaseline,
+		thresholdRules:        thresholdRules,
+		dbClient:              dbClient,
+		reportGenerator:       reportGenerator,
+		logger:                logger,
 	}, nil
 
 }
@@ -68,15 +122,33 @@ var _ GoCover = (*diffCover)(nil)
 
 // diffCoverage implements the GoCover interface and generate the diff coverage statistics.
 type diffCover struct {
-	comparedBranch   string // git diff base branch
-	repositoryPath   string
-	excludePatterns  []string
-	ignoreProfiles   []*annotation.IgnoreProfile
-	excludeFiles     excludeFileCache
-	moduleDir        string
-	modulePath       string
-	coverFilenames   []string
-	coverageBaseline float64
+	comparedBranch     string // git diff base branch
+	includeUncommitted bool   // whether includes the staged and unstaged changes
+	diffFile           string // unified diff file used instead of git diff, "-" means stdin
+	stdin              io.Reader
+	fromRevision       string // start revision of the diff range, compared branch is used if it's empty
+	toRevision         string // end revision of the diff range, HEAD is used if it's empty
+	fetchMissing       bool   // whether fetches the missing history with git binary
+	repositoryPath     string
+	excludePatterns    []string
+	ignoreProfiles     []*annotation.IgnoreProfile
+	excludeFiles       excludeFileCache
+	moduleDir          string
+	modulePath         string
+	workspace          *workspace // nil if the module dir is a single module
+	moduleErrors       map[string]string
+	testResults        *report.TestResults
+	attribution        *AttributionIndex
+	testSelection      *report.TestSelection
+	buildFlags         []string // build flags for resolving the packages, such as -tags and -mod=vendor
+	coverFilenames     []string
+	// cover profiles of the compared branch, used for finding the indirect coverage loss
+	compareCoverFilenames []string
+	staleProfilePolicy    parser.StaleProfilePolicy
+	coverageBaseline      float64
+	thresholdRules        []*ThresholdRule
+	// changes computed by gocover test for selecting the tests, git diff is skipped if it's not nil
+	changes []*gittool.Change
 
 	reportGenerator report.ReportGenerator
 	coverageTree    report.CoverageTree
@@ -87,38 +159,42 @@ type diffCover struct {
 
 func (diff *diffCover) Run(ctx context.Context) error {
 
-	statistics, err := diff.generateStatistics()
+	coverFilenames, clean, err := resolveCoverProfiles(ctx, diff.coverFilenames)
 	if err != nil {
 		return fmt.Errorf("diff: %w", err)
 	}
+	defer clean()
+	diff.coverFilenames = coverFilenames
 
-	if err := diff.reportGenerator.GenerateReport(statistics); err != nil {
-		return fmt.Errorf("generate report: %w", err)
+	compareCoverFilenames, cleanCompare, err := resolveCoverProfiles(ctx, diff.compareCoverFilenames)
+	if err != nil {
+		return fmt.Errorf("diff: %w", err)
 	}
+	defer cleanCompare()
+	diff.compareCoverFilenames = compareCoverFilenames
 
-	if err := diff.dump(ctx); err != nil {
-		return fmt.Errorf("%w", err)
+	statistics, err := diff.generateStatistics(ctx)
+	if err != nil {
+		return fmt.Errorf("diff: %w", err)
 	}
 
-	if err := diff.pass(statistics); err != nil {
-		return fmt.Errorf("%w", err)
+	statistics.ThresholdViolations = checkThresholds(diff.coverageTree.All(), diff.thresholdRules)
+	for _, v := range statistics.ThresholdViolations {
+		diff.logger.Warnf("coverage of %s is %.2f%%, lower than %.2f%% required by rule %s", v.Path, v.Coverage, v.Threshold, v.Pattern)
 	}
 
-	return nil
-}
+	if err := diff.reportGenerator.GenerateReport(statistics); err != nil {
+		return fmt.Errorf("generate report: %w", err)
+	}
 
-func (diff *diffCover) pass(statistics *report.Statistics) error {
-	if statistics.TotalCoveragePercent < diff.coverageBaseline {
-		return WrapErrorWithCode(
-			fmt.Errorf("the coverage baseline pass rate is %.2f, currently is %.2f",
-				diff.coverageBaseline,
-				statistics.TotalCoveragePercent,
-			),
-			LowCoverageErrorExitCode,
-			"",
-		)
+	if err := diff.dump(ctx); err != nil {
+		return fmt.Errorf("%w", err)
 	}
-	return nil
+
+	return combineGateErrors(
+		passThresholds(statistics.ThresholdViolations),
+		passCoverageBaseline(statistics, diff.coverageBaseline),
+	)
 }
 
 func (diff *diffCover) dump(ctx context.Context) error {
@@ -139,53 +215,216 @@ func (diff *diffCover) dump(ctx context.Context) error {
 	return nil
 }
 
-func (diff *diffCover) getGitChanges() ([]*gittool.Change, error) {
+func (diff *diffCover) getGitChanges(ctx context.Context) ([]*gittool.Change, error) {
+	if diff.changes != nil {
+		return diff.changes, nil
+	}
+	if diff.diffFile != "" {
+		return diff.getPatchChanges()
+	}
+
+	changes, err := diff.diffGitChanges()
+	if err != nil && diff.fetchMissing && isMissingGitHistory(err) {
+		diff.logger.Warnf("git history is missing, fetch it with git binary: %s", err)
+		revision, err := gittool.FetchMissingHistory(ctx, diff.repositoryPath, diff.startRevision(), diff.toRevision)
+		if err != nil {
+			return nil, WrapErrorWithCode(fmt.Errorf("fetch missing history: %w", err), GitHistoryErrorExitCode, "")
+		}
+		diff.setStartRevision(revision)
+		changes, err = diff.diffGitChanges()
+	}
+	if err != nil {
+		if isMissingGitHistory(err) {
+			return nil, WrapErrorWithCode(fmt.Errorf("git diff: %w", err), GitHistoryErrorExitCode, "")
+		}
+		return nil, fmt.Errorf("git diff: %w", err)
+	}
+	if err := diff.checkToRevision(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// checkToRevision checks the to revision is checked out, as the statements of the cover profile are located
+// in the source files of the working tree, while the changed lines come from the to revision.
+func (diff *diffCover) checkToRevision() error {
+	if diff.toRevision == "" {
+		return nil
+	}
+	gitClient, err := gittool.NewGitClient(diff.repositoryPath)
+	if err != nil {
+		return fmt.Errorf("git repository: %w", err)
+	}
+	modified, err := gitClient.CheckCheckedOut(diff.toRevision)
+	if err != nil {
+		return fmt.Errorf("check --to revision: %w, check it out before running the tests, or use --diff-file", err)
+	}
+	if len(modified) != 0 {
+		diff.logger.Warnf("go files are modified in the working tree, the changed lines of %s may not match them: %s",
+			diff.toRevision, strings.Join(modified, ", "))
+	}
+	return nil
+}
+
+// diffGitChanges opens the git repository and gets the changes, the repository is opened every time
+// so that the history fetched by git binary can be seen.
+func (diff *diffCover) diffGitChanges() ([]*gittool.Change, error) {
 	gitClient, err := gittool.NewGitClient(diff.repositoryPath)
 	if err != nil {
 		return nil, fmt.Errorf("git repository: %w", err)
 	}
-	changes, err := gitClient.DiffChangesFromCommitted(diff.comparedBranch)
+
+	if diff.isRevisionRange() {
+		return gitClient.DiffChangesFromRange(diff.startRevision(), diff.toRevision)
+	}
+	if diff.includeUncommitted {
+		return gitClient.DiffChangesFromWorkingTree(diff.comparedBranch)
+	}
+	return gitClient.DiffChangesFromCommitted(diff.comparedBranch)
+}
+
+// testChanges returns the changes of the diff coverage of gocover test, so that the tests can be selected by them
+// before they run, the changes are passed to the diff coverage then, and the diff file is only read once.
+func testChanges(ctx context.Context, o *GoCoverTestOption, repositoryAbsPath string, logger logrus.FieldLogger) ([]*gittool.Change, error) {
+	if o.DiffFile != "" && o.IncludeUncommitted {
+		return nil, ErrDiffFileWithUncommitted
+	}
+	if (o.From != "" || o.To != "") && (o.DiffFile != "" || o.IncludeUncommitted) {
+		return nil, ErrRevisionRangeConflict
+	}
+
+	diff := &diffCover{
+		repositoryPath:     repositoryAbsPath,
+		comparedBranch:     o.CompareBranch,
+		includeUncommitted: o.IncludeUncommitted,
+		diffFile:           o.DiffFile,
+		fromRevision:       o.From,
+		toRevision:         o.To,
+		fetchMissing:       o.FetchMissing,
+		stdin:              os.Stdin,
+		logger:             logger,
+	}
+	changes, err := diff.getGitChanges(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("git diff: %w", err)
+		return nil, err
+	}
+	if changes == nil {
+		// not nil, so the diff coverage knows the changes are computed
+		changes = []*gittool.Change{}
+	}
+	return changes, nil
+}
+
+func isMissingGitHistory(err error) bool {
+	return errors.Is(err, gittool.ErrShallowRepository) || errors.Is(err, gittool.ErrRevisionNotFound)
+}
+
+// reportComparedBranch returns the compared branch shown in the report,
+// it's empty when the changes come from diff file because the compared branch is unknown.
+func (diff *diffCover) reportComparedBranch() string {
+	if diff.diffFile != "" {
+		return ""
+	}
+	return diff.startRevision()
+}
+
+// isRevisionRange returns whether the diff is between explicit revisions instead of compared branch and HEAD.
+func (diff *diffCover) isRevisionRange() bool {
+	return diff.fromRevision != "" || diff.toRevision != ""
+}
+
+// startRevision returns the start revision of the diff, it's the compared branch if from is not specified.
+func (diff *diffCover) startRevision() string {
+	if diff.fromRevision != "" {
+		return diff.fromRevision
+	}
+	return diff.comparedBranch
+}
+
+// setStartRevision sets the start revision of the diff, such as the remote tracking branch that the missing
+// compared branch is fetched into.
+func (diff *diffCover) setStartRevision(revision string) {
+	if diff.fromRevision != "" {
+		diff.fromRevision = revision
+		return
+	}
+	diff.comparedBranch = revision
+}
+
+// getPatchChanges reads the changes from the unified diff file, "-" means reading from stdin.
+func (diff *diffCover) getPatchChanges() ([]*gittool.Change, error) {
+	r := diff.stdin
+	if diff.diffFile != "-" {
+		f, err := os.Open(diff.diffFile)
+		if err != nil {
+			return nil, fmt.Errorf("open diff file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	changes, err := gittool.ParseUnifiedDiff(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse diff file: %w", err)
 	}
 	return changes, nil
 }
 
-func (diff *diffCover) generateStatistics() (*report.Statistics, error) {
-	changes, err := diff.getGitChanges()
+// renamedFiles returns the renamed go files in the changes, sorted by the new file path.
+func renamedFiles(changes []*gittool.Change) []*report.RenamedFile {
+	var files []*report.RenamedFile
+	for _, change := range changes {
+		if change.Mode == gittool.RenameMode {
+			files = append(files, &report.RenamedFile{From: change.OldFileName, To: change.FileName})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].To < files[j].To
+	})
+	return files
+}
+
+func (diff *diffCover) generateStatistics(ctx context.Context) (*report.Statistics, error) {
+	changes, err := diff.getGitChanges(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	packages, err := parser.NewParser(diff.coverFilenames, diff.logger).Parse(changes)
+	packages, err := parser.NewParser(diff.coverFilenames, diff.logger, parserOptions(ctx, diff.workspace, filepath.Join(diff.repositoryPath, diff.moduleDir), diff.buildFlags, diff.staleProfilePolicy)...).Parse(changes)
 	if err != nil {
 		return nil, err
 	}
 
 	statistics := &report.Statistics{
-		StatisticsType: report.DiffStatisticsType,
-		ComparedBranch: diff.comparedBranch,
+		StatisticsType:   report.DiffStatisticsType,
+		ComparedBranch:   diff.reportComparedBranch(),
+		HeadRevision:     diff.toRevision,
+		CoverageBaseline: diff.coverageBaseline,
+		RepositoryPath:   diff.repositoryPath,
+		RenamedFiles:     renamedFiles(changes),
+	}
+	if len(diff.compareCoverFilenames) != 0 {
+		statistics.IndirectCoverageLoss, err = diff.indirectCoverageLoss(ctx, changes)
+		if err != nil {
+			return nil, fmt.Errorf("find indirect coverage loss: %w", err)
+		}
 	}
 	m := make(map[string]*report.CoverageProfile)
 	fileCache := make(fileContentsCache)
 	added := make(map[string]*report.CoverageProfile)
-	keep := make(map[string]string)
+	keep := make(map[string]*goModule)
 	for _, pkg := range packages {
 		diff.logger.Debugf("package: %s", pkg.Name)
 		diff.ignoreProfiles = append(diff.ignoreProfiles, pkg.IgnoreProfiles...)
 
-		p, err := build.Import(pkg.Name, ".", build.FindOnly)
-		if err != nil {
-			return nil, fmt.Errorf("build import %w", err)
-		}
-
 		for _, fun := range pkg.Functions {
 
 			// extract into single function
 			coverProfile, ok := m[fun.File]
 			if !ok {
 				coverProfile = &report.CoverageProfile{
-					FileName: formatFilePath(p.Root, fun.File, diff.modulePath),
+					FileName:   formatFilePath(pkg.ModuleDir, fun.File, pkg.ModulePath),
+					SourceFile: fun.File,
 				}
 				m[fun.File] = coverProfile
 			}
@@ -204,6 +443,12 @@ func (diff *diffCover) generateStatistics() (*report.Statistics, error) {
 				section.Contents = append(section.Contents, fileContents[i-1])
 			}
 
+			functionProfile := &report.FunctionProfile{
+				Name:      fun.Name,
+				StartLine: fun.StartLine,
+				EndLine:   fun.EndLine,
+			}
+
 			var total, ignored, covered, coveredButIgnored int
 			violated := false
 			changed := false
@@ -214,6 +459,7 @@ func (diff *diffCover) generateStatistics() (*report.Statistics, error) {
 
 				changed = true
 				total += 1
+				functionProfile.Statements = append(functionProfile.Statements, newStatementProfile(st))
 
 				if st.Mode == parser.Ignore && st.Reached > 0 {
 					coveredButIgnored++
@@ -237,7 +483,7 @@ func (diff *diffCover) generateStatistics() (*report.Statistics, error) {
 				if ok := inExclueds(
 					diff.excludeFiles,
 					diff.excludePatterns,
-					formatFilePath(p.Root, fun.File, diff.modulePath),
+					formatFilePath(pkg.ModuleDir, fun.File, pkg.ModulePath),
 					diff.logger,
 				); ok {
 					continue
@@ -248,13 +494,14 @@ func (diff *diffCover) generateStatistics() (*report.Statistics, error) {
 				coverProfile.TotalEffectiveLines += (total - ignored)
 				coverProfile.TotalIgnoredLines += ignored
 				coverProfile.CoveredButIgnoredLines += coveredButIgnored
+				coverProfile.Functions = append(coverProfile.Functions, functionProfile)
 				if violated {
 					coverProfile.ViolationSections = append(coverProfile.ViolationSections, section)
 				}
 				if _, ok := added[fun.File]; !ok {
 					statistics.CoverageProfile = append(statistics.CoverageProfile, coverProfile)
 					added[fun.File] = coverProfile
-					keep[fun.File] = p.Root
+					keep[fun.File] = &goModule{Path: pkg.ModulePath, Dir: pkg.ModuleDir}
 				}
 			}
 		}
@@ -262,7 +509,7 @@ func (diff *diffCover) generateStatistics() (*report.Statistics, error) {
 	}
 
 	for k, v := range added {
-		node := diff.coverageTree.FindOrCreate(strings.TrimPrefix(k, keep[k]))
+		node := diff.coverageTree.FindOrCreateInModule(keep[k].Path, strings.TrimPrefix(k, keep[k].Dir))
 		node.TotalLines = int64(v.TotalLines)
 		node.TotalCoveredLines = int64(v.CoveredLines)
 		node.TotalEffectiveLines = int64(v.TotalEffectiveLines)
@@ -271,8 +518,13 @@ func (diff *diffCover) generateStatistics() (*report.Statistics, error) {
 	}
 
 	diff.coverageTree.CollectCoverageData()
+	statistics.Modules = moduleCoverages(diff.workspace, diff.coverageTree.All(), diff.moduleErrors)
+	statistics.TestResults = diff.testResults
+	statistics.TestSelection = diff.testSelection
 
 	reBuildStatistics(statistics, diff.excludeFiles)
+	attachIgnoredSections(statistics, diff.ignoreProfiles)
+	attachCoveringTests(statistics, diff.attribution)
 
 	return statistics, nil
 }
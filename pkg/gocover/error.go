@@ -4,6 +4,8 @@ const (
 	GeneralErrorExitCode        = 1  // bash general error exit code
 	UnitTestFailedErrorExitCode = 11 // unit test failed exit code
 	LowCoverageErrorExitCode    = 12 // pass rate is lower than the coverage baseline exit code
+	GitHistoryErrorExitCode     = 13 // git history for diff is not available exit code, such as in a shallow clone
+	TimeoutErrorExitCode        = 14 // tests or gocover don't finish before the timeout exit code
 )
 
 // GoCoverError carries the detail error information for gocover error
@@ -28,3 +30,7 @@ func WrapError(err error, errMessage string) *GoCoverError {
 func (e *GoCoverError) Error() string {
 	return e.Err.Error()
 }
+
+func (e *GoCoverError) Unwrap() error {
+	return e.Err
+}
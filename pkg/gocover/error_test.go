@@ -1,6 +1,7 @@
 package gocover
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,3 +20,16 @@ func TestGoCoverError(t *testing.T) {
 	assertion.Equalf(LowCoverageErrorExitCode, err.ExitCode, "general error exit code")
 	assertion.Equalf("coverage is too low", err.ErrMessage, "error message")
 }
+
+func TestTestTimeoutError(t *testing.T) {
+	err := newTestTimeoutError([]string{"example.com/foo (TestFoo)", "example.com/bar"})
+	assert.Equal(t, TimeoutErrorExitCode, err.ExitCode)
+	assert.EqualError(t, err, "tests timed out, running: example.com/foo (TestFoo), example.com/bar")
+
+	var timeoutErr *TestTimeoutError
+	if assert.ErrorAs(t, fmt.Errorf("run: %w", err), &timeoutErr) {
+		assert.Equal(t, []string{"example.com/foo (TestFoo)", "example.com/bar"}, timeoutErr.Running)
+	}
+
+	assert.EqualError(t, newTestTimeoutError(nil), "tests timed out, running: unknown")
+}
@@ -1,23 +1,27 @@
 package gocover
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 
+	"github.com/Azure/gocover/pkg/gittool"
+	"github.com/Azure/gocover/pkg/parser"
+	"github.com/Azure/gocover/pkg/report"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	outCoverageProfile = "coverage.out"
+
+	// maxFailedTestsInMessage is the number of failed tests listed in the error message, all of them are in the report.
+	maxFailedTestsInMessage = 10
 )
 
 type GoCoverTestExecutor interface {
@@ -38,6 +42,43 @@ func NewGoCoverTestExecutor(o *GoCoverTestOption) (GoCoverTestExecutor, error) {
 		o.OutputDir = dir
 	}
 
+	if o.SelectTests && (o.CoverageMode != DiffCoverage || o.ExecutorMode != GoExecutor || o.AllModules) {
+		return nil, ErrTestSelectionNotSupported
+	}
+	if err := validateAttributionGranularity(o.Attribution); err != nil {
+		return nil, err
+	}
+	if o.Attribution != "" && (o.ExecutorMode != GoExecutor || o.AllModules) {
+		return nil, ErrAttributionNotSupported
+	}
+	if o.ExecutorMode == CustomExecutor {
+		if err := validateCustomExecutor(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.AllModules {
+		return &allModulesTestExecutor{
+			repositoryPath: repositoryAbsPath,
+			moduleDir:      o.ModuleDir,
+			parallel:       o.Parallel,
+			option:         o,
+			logger:         o.Logger.WithField("source", "GoCoverTest"),
+		}, nil
+	}
+	return newModuleTestExecutor(o, repositoryAbsPath)
+}
+
+// moduleTestExecutor runs the tests of a single module.
+type moduleTestExecutor interface {
+	GoCoverTestExecutor
+	// coverProfiles runs the tests and returns the cover profiles.
+	coverProfiles(ctx context.Context) ([]string, error)
+	// testResults returns the results of the tests after they run, nil if the executor doesn't record them.
+	testResults() *report.TestResults
+}
+
+func newModuleTestExecutor(o *GoCoverTestOption, repositoryAbsPath string) (moduleTestExecutor, error) {
 	switch o.ExecutorMode {
 	case GoExecutor:
 		return &goBuiltInTestExecutor{
@@ -65,13 +106,15 @@ func NewGoCoverTestExecutor(o *GoCoverTestOption) (GoCoverTestExecutor, error) {
 			mode:           o.CoverageMode,
 			option:         o,
 		}, nil
+	case CustomExecutor:
+		return newCustomTestExecutor(o, repositoryAbsPath)
 	default:
 		return nil, fmt.Errorf("%w: %s", ErrUnknownExecutorMode, o.ExecutorMode)
 	}
 }
 
-var _ GoCoverTestExecutor = (*goBuiltInTestExecutor)(nil)
-var _ GoCoverTestExecutor = (*ginkgoTestExecutor)(nil)
+var _ moduleTestExecutor = (*goBuiltInTestExecutor)(nil)
+var _ moduleTestExecutor = (*ginkgoTestExecutor)(nil)
 
 type goBuiltInTestExecutor struct {
 	repositoryPath string
@@ -84,6 +127,10 @@ type goBuiltInTestExecutor struct {
 	stdout         io.Writer
 	stderr         io.Writer
 	logger         logrus.FieldLogger
+	results        *report.TestResults
+	selection      *report.TestSelection
+	changes        []*gittool.Change
+	attribution    *AttributionIndex
 }
 
 func (t *goBuiltInTestExecutor) Run(ctx context.Context) error {
@@ -93,6 +140,61 @@ func (t *goBuiltInTestExecutor) Run(ctx context.Context) error {
 			"executor":  "go",
 		},
 	)
+
+	coverFiles, testErr := t.coverProfiles(ctx)
+	if err := writeTestResults(t.option.JUnitFile, t.results, logger); err != nil {
+		return err
+	}
+	if testErr != nil && ctx.Err() != nil {
+		// the tests are killed, no cover profile is written
+		return testErr
+	}
+	if testErr != nil {
+		// the cover profile is still written when some tests fail, report the coverage along with the failed tests
+		coverFile := filepath.Join(t.outputDir, outCoverageProfile)
+		if _, err := os.Stat(coverFile); err != nil || !testsRun(t.results) {
+			return testErr
+		}
+		coverFiles = []string{coverFile}
+	}
+
+	gocover, err := buildGoCover(t.mode, t.option, &testRun{
+		coverProfiles: coverFiles,
+		testResults:   t.results,
+		testSelection: t.selection,
+		changes:       t.changes,
+		attribution:   t.attribution,
+	}, logger)
+	if err != nil {
+		return err
+	}
+
+	if testErr == nil {
+		logger.Info("run unit test succeeded")
+	}
+	logger.Infof("cover profile: %s", strings.Join(coverFiles, ", "))
+
+	if err := gocover.Run(ctx); err != nil {
+		err := fmt.Errorf("run gocover: %w", err)
+		logger.WithError(err).Error()
+		if testErr == nil {
+			return err
+		}
+	}
+	return testErr
+}
+
+func (t *goBuiltInTestExecutor) testResults() *report.TestResults {
+	return t.results
+}
+
+func (t *goBuiltInTestExecutor) coverProfiles(ctx context.Context) ([]string, error) {
+	logger := t.logger.WithFields(
+		logrus.Fields{
+			"moduledir": t.moduleDir,
+			"executor":  "go",
+		},
+	)
 	goFlags := []string{}
 	for _, flag := range t.flags {
 		if trimmed := strings.TrimSpace(flag); trimmed != "" {
@@ -101,38 +203,88 @@ func (t *goBuiltInTestExecutor) Run(ctx context.Context) error {
 	}
 	coverFile := filepath.Join(t.outputDir, outCoverageProfile)
 
-	goArgs := []string{"test", "./..."}
+	packages := []string{"./..."}
+	if t.option.SelectTests {
+		if err := t.selectTests(ctx); err != nil {
+			return nil, fmt.Errorf("select tests: %w", err)
+		}
+		logger.Infof("selected the tests of %d of %d packages by changed packages: %s",
+			len(t.selection.SelectedPackages), t.selection.TotalPackages, strings.Join(t.selection.ChangedPackages, ", "))
+		packages = testPackages(t.selection)
+		if len(packages) == 0 {
+			// no package is changed, the cover profile is empty, so is the diff coverage of go files
+			t.results = &report.TestResults{}
+			if err := os.WriteFile(coverFile, []byte("mode: set\n"), 0644); err != nil {
+				return nil, fmt.Errorf("write cover profile: %w", err)
+			}
+			return []string{coverFile}, nil
+		}
+	}
+
+	if t.option.Attribution != "" {
+		// remove the cover profile of the last run, so a stale one is not reported when the tests fail
+		_ = os.Remove(coverFile)
+		return t.attributedCoverProfiles(ctx, packages, goFlags, logger)
+	}
+
+	goArgs := []string{"test"}
+	goArgs = append(goArgs, packages...)
 	goArgs = append(goArgs, goFlags...)
 	goArgs = append(goArgs,
 		"-coverprofile", coverFile,
 		"-coverpkg=./...",
-		"-v")
+		"-json")
 
-	cmd := exec.Command(t.executable, goArgs...)
+	// the test events are decoded into the test results, and the test output is written to stdout as `go test -v` does
+	events := newTestEventWriter(t.stdout)
+	cmd := newCommand(ctx, t.executable, goArgs...)
 	cmd.Dir = filepath.Join(t.repositoryPath, t.moduleDir)
 	cmd.Stdin = nil
-	cmd.Stdout = t.stdout
+	cmd.Stdout = events
 	cmd.Stderr = t.stderr
 
+	// remove the cover profile of the last run, so a stale one is not reported when the tests fail
+	_ = os.Remove(coverFile)
 	logger.Infof("run unit tests: '%s'", cmd.String())
-	if err := cmd.Run(); err != nil {
-		t.logger.WithError(err).Errorf(`run unit test '%s'`, cmd.String())
-		return WrapErrorWithCode(errors.New("unit test failed"), UnitTestFailedErrorExitCode, "")
+	runErr := cmd.Run()
+	_ = events.Close()
+	t.results = events.results
+
+	passed, failed, skipped := t.results.Counts()
+	logger.Infof("tests: %d passed, %d failed, %d skipped", passed, failed, skipped)
+	if runErr != nil {
+		t.logger.WithError(runErr).Errorf(`run unit test '%s'`, cmd.String())
+		if err := contextError(ctx, events.running); err != nil {
+			return nil, err
+		}
+		if message := failedTestsMessage(t.results, maxFailedTestsInMessage); message != "" {
+			return nil, WrapErrorWithCode(fmt.Errorf("unit test failed: %s", message), UnitTestFailedErrorExitCode, "")
+		}
+		return nil, WrapErrorWithCode(errors.New("unit test failed"), UnitTestFailedErrorExitCode, "")
 	}
+	return []string{coverFile}, nil
+}
 
-	gocover, err := buildGoCover(t.mode, t.option, []string{coverFile}, logger)
+// selectTests selects the test packages that reach the changed packages of diff coverage.
+func (t *goBuiltInTestExecutor) selectTests(ctx context.Context) error {
+	changes, err := testChanges(ctx, t.option, t.repositoryPath, t.logger)
 	if err != nil {
 		return err
 	}
 
-	logger.Info("run unit test succeeded")
-	logger.Infof("cover profile: %s", coverFile)
-
-	if err := gocover.Run(ctx); err != nil {
-		err := fmt.Errorf("run gocover: %w", err)
-		logger.WithError(err).Error()
+	// go list reports the directories with symbolic links resolved
+	repositoryPath, err := filepath.EvalSymlinks(t.repositoryPath)
+	if err != nil {
+		return err
+	}
+	moduleDir := filepath.Join(repositoryPath, t.moduleDir)
+	graph, err := loadPackageGraph(ctx, t.executable, moduleDir, testBuildFlags(t.option))
+	if err != nil {
 		return err
 	}
+
+	t.changes = changes
+	t.selection = selectTestsByChanges(graph, repositoryPath, moduleDir, changes)
 	return nil
 }
 
@@ -150,36 +302,46 @@ type ginkgoTestExecutor struct {
 }
 
 func (e *ginkgoTestExecutor) Run(ctx context.Context) error {
-	err := e.runTests(ctx)
+	coverFiles, err := e.coverProfiles(ctx)
 	if err != nil {
 		return err
 	}
 
-	coverFiles, err := findCoverProfiles(filepath.Join(e.repositoryPath, e.moduleDir))
+	gocover, err := buildGoCover(e.mode, e.option, &testRun{coverProfiles: coverFiles}, e.logger)
 	if err != nil {
 		return err
 	}
 
-	e.logger.Debugf("total: %d", len(coverFiles))
-	for _, f := range coverFiles {
-		e.logger.Debugf("%s", f)
+	e.logger.Infof("cover profile: %s", strings.Join(coverFiles, ", "))
+	if err := gocover.Run(ctx); err != nil {
+		err := fmt.Errorf("run gocover: %w", err)
+		e.logger.WithError(err).Error()
+		return err
 	}
 
-	mergedFile, err := mergeCoverProfiles(e.outputDir, coverFiles)
+	return nil
+}
+
+// coverProfiles runs the ginkgo tests, and merges the cover profiles of the test suites into the output directory.
+func (e *ginkgoTestExecutor) coverProfiles(ctx context.Context) ([]string, error) {
+	err := e.runTests(ctx)
 	if err != nil {
-		return fmt.Errorf("merge cover profiles: %w", err)
+		return nil, err
 	}
 
-	gocover, err := buildGoCover(e.mode, e.option, []string{mergedFile}, e.logger)
+	coverFiles, err := findCoverProfiles(filepath.Join(e.repositoryPath, e.moduleDir))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	e.logger.Infof("cover profile: %s", mergedFile)
-	if err := gocover.Run(ctx); err != nil {
-		err := fmt.Errorf("run gocover: %w", err)
-		e.logger.WithError(err).Error()
-		return err
+	e.logger.Debugf("total: %d", len(coverFiles))
+	for _, f := range coverFiles {
+		e.logger.Debugf("%s", f)
+	}
+
+	mergedFile, err := mergeCoverProfiles(e.outputDir, coverFiles)
+	if err != nil {
+		return nil, fmt.Errorf("merge cover profiles: %w", err)
 	}
 
 	for _, f := range coverFiles {
@@ -187,10 +349,21 @@ func (e *ginkgoTestExecutor) Run(ctx context.Context) error {
 		_ = os.Remove(f)
 	}
 
+	return []string{mergedFile}, nil
+}
+
+// testResults returns nil as the ginkgo output is not decoded, ginkgo writes its own JUnit report with --junit-report.
+func (e *ginkgoTestExecutor) testResults() *report.TestResults {
 	return nil
 }
 
+// mergeCoverProfiles merges the cover profiles into a single cover profile in the output directory.
 func mergeCoverProfiles(outputdir string, coverProfiles []string) (string, error) {
+	profiles, err := parser.ParseCoverProfiles(coverProfiles)
+	if err != nil {
+		return "", err
+	}
+
 	result := filepath.Join(outputdir, outCoverageProfile)
 	f, err := os.Create(result)
 	if err != nil {
@@ -198,21 +371,9 @@ func mergeCoverProfiles(outputdir string, coverProfiles []string) (string, error
 	}
 	defer f.Close()
 
-	fmt.Fprint(f, "mode: atomic\n")
-	for _, c := range coverProfiles {
-		pf, err := os.Open(c)
-		if err != nil {
-			return "", err
-		}
-		s := bufio.NewScanner(pf)
-
-		s.Scan() // skip first line of cover profile because it's cover profile's metadata
-		for s.Scan() {
-			fmt.Fprintf(f, "%s\n", s.Text())
-		}
-		pf.Close()
+	if err := parser.WriteProfiles(f, profiles); err != nil {
+		return "", err
 	}
-
 	return result, nil
 }
 
@@ -228,13 +389,16 @@ func (executor *ginkgoTestExecutor) runTests(ctx context.Context) error {
 	buildString := fmt.Sprintf("%s %s", executor.executable, strings.Join(buildArgs, " "))
 
 	logger.Infof("executing cmd: %s", buildString)
-	buildCmd := exec.Command(executor.executable, buildArgs...)
+	buildCmd := newCommand(ctx, executor.executable, buildArgs...)
 	buildCmd.Dir = workingDir
 	buildCmd.Stdin = nil
 	buildCmd.Stdout = executor.stdout
 	buildCmd.Stderr = executor.stderr
 	if err := buildCmd.Run(); err != nil {
 		logger.WithError(err).Errorf(`executing cmd %s`, buildString)
+		if err := contextError(ctx, func() []string { return []string{"ginkgo build in " + workingDir} }); err != nil {
+			return err
+		}
 		return fmt.Errorf("build tests: %w", err)
 	}
 	logger.Infof("ginkgo tests built sucessfully")
@@ -249,13 +413,17 @@ func (executor *ginkgoTestExecutor) runTests(ctx context.Context) error {
 	runString := fmt.Sprintf("%s %s", executor.executable, strings.Join(ginkgoFlags, " "))
 
 	logger.Infof("executing cmd: %s", runString)
-	runCmd := exec.Command(executor.executable, ginkgoFlags...)
+	runCmd := newCommand(ctx, executor.executable, ginkgoFlags...)
 	runCmd.Dir = workingDir
 	runCmd.Stdin = nil
 	runCmd.Stdout = executor.stdout
 	runCmd.Stderr = executor.stderr
 	if err := runCmd.Run(); err != nil {
 		logger.WithError(err).Errorf(`executing cmd %s`, runString)
+		// ginkgo output is not decoded, only the suites directory is known
+		if err := contextError(ctx, func() []string { return []string{"ginkgo tests in " + workingDir} }); err != nil {
+			return err
+		}
 		return fmt.Errorf("unit test failed: %w", err)
 	}
 	logger.Info("ginkgo tests run sucessfully")
@@ -274,9 +442,16 @@ func findCoverProfiles(dir string) ([]string, error) {
 	return files, nil
 }
 
+// glob walks the module of the root directory and returns the files that match the function,
+// the nested modules are skipped, they are tested on their own with --all-modules.
 func glob(root string, fn func(string) bool) ([]string, error) {
 	var files []string
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, e error) error {
+		if d != nil && d.IsDir() && path != root {
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
 		if fn(path) {
 			files = append(files, path)
 		}
@@ -285,16 +460,9 @@ func glob(root string, fn func(string) bool) ([]string, error) {
 	return files, err
 }
 
+// goCmd returns the go executable, which is the same one that resolves the packages of the cover profiles.
 func goCmd() string {
-	var exeSuffix string
-	if runtime.GOOS == "windows" {
-		exeSuffix = ".exe"
-	}
-	path := filepath.Join(runtime.GOROOT(), "bin", "go"+exeSuffix)
-	if _, err := os.Stat(path); err == nil {
-		return path
-	}
-	return "go"
+	return parser.GoCmd()
 }
 
 func ginkgoCmd() string {
@@ -309,42 +477,97 @@ func ginkgoCmd() string {
 	return "ginkgo"
 }
 
-func buildGoCover(
-	mode CoverageMode,
-	option *GoCoverTestOption,
-	coverProfiles []string,
-	logger logrus.FieldLogger,
-) (GoCover, error) {
+// testBuildFlags returns the flags of the tests that affect how the packages are resolved,
+// so the packages of the cover profiles are resolved as the tests are built.
+func testBuildFlags(option *GoCoverTestOption) []string {
+	flags := option.GoFlags
+	if option.ExecutorMode == GinkgoExecutor {
+		flags = option.GinkgoFlags
+	}
+
+	var result []string
+	for _, flag := range flags {
+		trimmed := strings.TrimSpace(flag)
+		// both go and ginkgo accept the flags with one or two dashes
+		name := strings.TrimPrefix(strings.TrimPrefix(trimmed, "-"), "-")
+		for _, prefix := range []string{"tags=", "mod=", "modfile="} {
+			if strings.HasPrefix(name, prefix) {
+				result = append(result, "-"+name)
+			}
+		}
+	}
+	return result
+}
+
+// testRun is the outcome of running the tests, which the coverage is calculated from.
+type testRun struct {
+	coverProfiles []string
+	// moduleErrors are the test failures of the modules keyed by module path, only for all modules
+	moduleErrors map[string]string
+	testResults  *report.TestResults
+	// testSelection and changes are set when the tests are selected by the changes of diff coverage
+	testSelection *report.TestSelection
+	changes       []*gittool.Change
+	// attribution is set when the coverage is attributed to the tests
+	attribution *AttributionIndex
+}
+
+func buildGoCover(mode CoverageMode, option *GoCoverTestOption, run *testRun, logger logrus.FieldLogger) (GoCover, error) {
 	switch mode {
 	case FullCoverage:
 		return NewFullCover(&FullOption{
-			CoverProfiles:    coverProfiles,
+			CoverProfiles:    run.coverProfiles,
 			RepositoryPath:   option.RepositoryPath,
 			ModuleDir:        option.ModuleDir,
+			StaleProfile:     option.StaleProfile,
+			Workspace:        option.AllModules,
+			BuildFlags:       testBuildFlags(option),
+			ModuleErrors:     run.moduleErrors,
+			TestResults:      run.testResults,
+			Attribution:      run.attribution,
 			CoverageBaseline: option.CoverageBaseline,
+			Thresholds:       option.Thresholds,
 			ReportFormat:     option.ReportFormat,
 			ReportName:       option.ReportName,
 			OutputDir:        option.OutputDir,
 			Excludes:         option.Excludes,
 			Style:            option.Style,
+			RatchetFile:      option.RatchetFile,
+			RatchetTolerance: option.RatchetTolerance,
+			UpdateRatchet:    option.UpdateRatchet,
 			DbOption:         option.DbOption,
 			Logger:           logger,
 		})
 	case DiffCoverage:
 		return NewDiffCover(&DiffOption{
-			CoverProfiles:    coverProfiles,
-			CompareBranch:    option.CompareBranch,
-			RepositoryPath:   option.RepositoryPath,
-			ModuleDir:        option.ModuleDir,
-			ModulePath:       option.ModuleDir,
-			CoverageBaseline: option.CoverageBaseline,
-			ReportFormat:     option.ReportFormat,
-			ReportName:       option.ReportName,
-			OutputDir:        option.OutputDir,
-			Excludes:         option.Excludes,
-			Style:            option.Style,
-			DbOption:         option.DbOption,
-			Logger:           logger,
+			CoverProfiles:        run.coverProfiles,
+			CompareCoverProfiles: option.CompareCoverProfiles,
+			CompareBranch:        option.CompareBranch,
+			IncludeUncommitted:   option.IncludeUncommitted,
+			DiffFile:             option.DiffFile,
+			From:                 option.From,
+			To:                   option.To,
+			FetchMissing:         option.FetchMissing,
+			RepositoryPath:       option.RepositoryPath,
+			ModuleDir:            option.ModuleDir,
+			ModulePath:           option.ModuleDir,
+			StaleProfile:         option.StaleProfile,
+			Workspace:            option.AllModules,
+			BuildFlags:           testBuildFlags(option),
+			ModuleErrors:         run.moduleErrors,
+			TestResults:          run.testResults,
+			Attribution:          run.attribution,
+			TestSelection:        run.testSelection,
+			Changes:              run.changes,
+			CoverageBaseline:     option.CoverageBaseline,
+			Thresholds:           option.Thresholds,
+			ReportFormat:         option.ReportFormat,
+			ReportName:           option.ReportName,
+			OutputDir:            option.OutputDir,
+			Excludes:             option.Excludes,
+			Style:                option.Style,
+			DbOption:             option.DbOption,
+			Logger:               logger,
 		})
 	default:
 		return nil, ErrUnknownCoverageMode
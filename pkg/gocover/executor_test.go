@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -88,6 +90,49 @@ func TestGoBuiltInTestExecutor_Run_CommandFails(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestGoBuiltInTestExecutor_Run_Timeout(t *testing.T) {
+	dir := t.TempDir()
+	writeWorkspaceFile(t, dir, "go.mod", "module example.com/root\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "a/a.go", "package a\n\nfunc A() int { return 1 }\n")
+	writeWorkspaceFile(t, dir, "a/a_test.go", "package a\n\nimport (\n\t\"testing\"\n\t\"time\"\n)\n\nfunc TestSleep(t *testing.T) { A(); time.Sleep(time.Hour) }\n")
+
+	// build the tests beforehand, so they are running when the timeout expires
+	warmUp := exec.Command(goCmd(), "test", "-run", "^$", "-coverprofile", filepath.Join(dir, "warmup.out"), "-coverpkg=./...", "./...")
+	warmUp.Dir = dir
+	if output, err := warmUp.CombinedOutput(); err != nil {
+		t.Fatalf("build tests: %s: %s", err, output)
+	}
+
+	var buf bytes.Buffer
+	executor := &goBuiltInTestExecutor{
+		repositoryPath: dir,
+		mode:           FullCoverage,
+		executable:     goCmd(),
+		outputDir:      t.TempDir(),
+		option:         &GoCoverTestOption{},
+		stdout:         &buf,
+		stderr:         &buf,
+		logger:         logrus.New(),
+	}
+
+	timeout := 5 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	err := executor.Run(ctx)
+
+	// the test binary is killed along with go, the output pipe is not held until the wait delay
+	assert.Less(t, time.Since(start), timeout+commandWaitDelay/2)
+	var e *GoCoverError
+	if assert.ErrorAs(t, err, &e) {
+		assert.Equal(t, TimeoutErrorExitCode, e.ExitCode)
+	}
+	var timeoutErr *TestTimeoutError
+	if assert.ErrorAs(t, err, &timeoutErr) {
+		assert.Equal(t, []string{"example.com/root/a (TestSleep)"}, timeoutErr.Running)
+	}
+}
+
 // TestHelperProcess is not a real test. It's used as a helper process for exec.Command patching.
 func TestHelperProcess(t *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
@@ -101,3 +146,35 @@ func TestHelperProcess(t *testing.T) {
 	}
 	os.Exit(code)
 }
+
+func TestTestBuildFlags(t *testing.T) {
+	option := &GoCoverTestOption{
+		ExecutorMode: GoExecutor,
+		GoFlags:      []string{"-count=1", " -tags=integration ", "-mod=vendor", "-race"},
+		GinkgoFlags:  []string{"-r", "--tags=e2e"},
+	}
+	assert.Equal(t, []string{"-tags=integration", "-mod=vendor"}, testBuildFlags(option))
+
+	option.ExecutorMode = GinkgoExecutor
+	assert.Equal(t, []string{"-tags=e2e"}, testBuildFlags(option))
+}
+
+func TestFindCoverProfiles(t *testing.T) {
+	dir := t.TempDir()
+	writeWorkspaceFile(t, dir, "go.mod", "module example.com/root\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "a/a.coverprofile", "mode: set\n")
+	writeWorkspaceFile(t, dir, "b/b.coverprofile.1", "mode: set\n")
+	writeWorkspaceFile(t, dir, "nested/go.mod", "module example.com/nested\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "nested/c/c.coverprofile", "mode: set\n")
+
+	files, err := findCoverProfiles(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a", "a.coverprofile"),
+		filepath.Join(dir, "b", "b.coverprofile.1"),
+	}, files, "the cover profiles of the nested module should be skipped")
+
+	files, err = findCoverProfiles(filepath.Join(dir, "nested"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "nested", "c", "c.coverprofile")}, files)
+}
@@ -2,8 +2,8 @@ package gocover
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"go/build"
 	"path/filepath"
 	"strings"
 
@@ -38,25 +38,59 @@ func NewFullCover(o *FullOption) (GoCover, error) {
 		return nil, fmt.Errorf("get absolute path of repo: %w", err)
 	}
 
-	modulePath, err := parseGoModulePath(filepath.Join(repositoryAbsPath, o.ModuleDir))
+	moduleAbsPath := filepath.Join(repositoryAbsPath, o.ModuleDir)
+	ws, err := loadWorkspace(moduleAbsPath, o.Workspace)
 	if err != nil {
-		return nil, fmt.Errorf("parse go module path: %w", err)
+		return nil, fmt.Errorf("load workspace: %w", err)
+	}
+	// a workspace has several modules, the report is rooted at the workspace and each module is a node of it
+	var modulePath string
+	if ws == nil {
+		modulePath, err = parseGoModulePath(moduleAbsPath)
+		if err != nil {
+			return nil, fmt.Errorf("parse go module path: %w", err)
+		}
 	}
 
 	logger.Debugf("repository path: %s, module path: %s, output dir: %s, exclude patterns: %s",
 		repositoryAbsPath, modulePath, o.OutputDir, o.Excludes)
 
+	if err := validateStaleProfilePolicy(o.StaleProfile); err != nil {
+		return nil, err
+	}
+
+	thresholdRules, err := ParseThresholdRules(o.Thresholds)
+	if err != nil {
+		return nil, fmt.Errorf("parse threshold rules: %w", err)
+	}
+
+	reportGenerator, err := report.NewReportGenerator(report.ReportFormat(o.ReportFormat), o.Style, o.OutputDir, o.ReportName, o.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("new report generator: %w", err)
+	}
+
 	return &fullCover{
-		coverFilenames:  o.CoverProfiles,
-		modulePath:      modulePath,
-		repositoryPath:  repositoryAbsPath,
-		excludeFiles:    make(excludeFileCache),
-		excludePatterns: o.Excludes,
-		moduleDir:       o.ModuleDir,
-		coverageTree:    report.NewCoverageTree(modulePath),
-		logger:          logger,
-		dbClient:        dbClient,
-		reportGenerator: report.NewReportGenerator(o.Style, o.OutputDir, o.ReportName, o.Logger),
+		coverFilenames:     o.CoverProfiles,
+		staleProfilePolicy: o.StaleProfile,
+		modulePath:         modulePath,
+		buildFlags:         o.BuildFlags,
+		moduleErrors:       o.ModuleErrors,
+		testResults:        o.TestResults,
+		attribution:        o.Attribution,
+		workspace:          ws,
+		repositoryPath:     repositoryAbsPath,
+		excludeFiles:       make(excludeFileCache),
+		excludePatterns:    o.Excludes,
+		moduleDir:          o.ModuleDir,
+		coverageBaseline:   o.CoverageBaseline,
+		thresholdRules:     thresholdRules,
+		ratchetFile:        o.RatchetFile,
+		ratchetTolerance:   o.RatchetTolerance,
+		updateRatchet:      o.UpdateRatchet,
+		coverageTree:       report.NewCoverageTree(modulePath),
+		logger:             logger,
+		dbClient:           dbClient,
+		reportGenerator:    reportGenerator,
 	}, nil
 
 }
@@ -65,27 +99,50 @@ var _ GoCover = (*fullCover)(nil)
 
 // diffCoverage implements the GoCover interface and generate the full coverage statistics.
 type fullCover struct {
-	coverFilenames  []string
-	moduleDir       string
-	modulePath      string
-	repositoryPath  string
-	excludePatterns []string
-	ignoreProfiles  []*annotation.IgnoreProfile
-	excludeFiles    excludeFileCache
-	coverageTree    report.CoverageTree
-	reportGenerator report.ReportGenerator
-	dbClient        dbclient.DbClient
+	coverFilenames     []string
+	staleProfilePolicy parser.StaleProfilePolicy
+	moduleDir          string
+	modulePath         string
+	workspace          *workspace // nil if the module dir is a single module
+	moduleErrors       map[string]string
+	testResults        *report.TestResults
+	attribution        *AttributionIndex
+	buildFlags         []string // build flags for resolving the packages, such as -tags and -mod=vendor
+	repositoryPath     string
+	excludePatterns    []string
+	coverageBaseline   float64
+	thresholdRules     []*ThresholdRule
+	ratchetFile        string
+	ratchetTolerance   float64
+	updateRatchet      bool
+	ignoreProfiles     []*annotation.IgnoreProfile
+	excludeFiles       excludeFileCache
+	coverageTree       report.CoverageTree
+	reportGenerator    report.ReportGenerator
+	dbClient           dbclient.DbClient
 
 	logger logrus.FieldLogger
 }
 
 func (full *fullCover) Run(ctx context.Context) error {
 
-	statistics, err := full.generateStatistics()
+	coverFilenames, clean, err := resolveCoverProfiles(ctx, full.coverFilenames)
+	if err != nil {
+		return fmt.Errorf("full: %w", err)
+	}
+	defer clean()
+	full.coverFilenames = coverFilenames
+
+	statistics, err := full.generateStatistics(ctx)
 	if err != nil {
 		return fmt.Errorf("full: %w", err)
 	}
 
+	statistics.ThresholdViolations = checkThresholds(full.coverageTree.All(), full.thresholdRules)
+	for _, v := range statistics.ThresholdViolations {
+		full.logger.Warnf("coverage of %s is %.2f%%, lower than %.2f%% required by rule %s", v.Path, v.Coverage, v.Threshold, v.Pattern)
+	}
+
 	if err := full.reportGenerator.GenerateReport(statistics); err != nil {
 		return fmt.Errorf("generate report: %w", err)
 	}
@@ -94,7 +151,28 @@ func (full *fullCover) Run(ctx context.Context) error {
 		return fmt.Errorf("%w", err)
 	}
 
-	return nil
+	return full.checkGates(statistics)
+}
+
+// checkGates runs the ratchet, threshold and coverage baseline gates, all of them run even if one fails,
+// so the ratchet snapshot is still created or updated for the module with low coverage.
+func (full *fullCover) checkGates(statistics *report.Statistics) error {
+	var ratchetErr error
+	if full.ratchetFile != "" {
+		if err := full.ratchet(); err != nil {
+			var e *GoCoverError
+			if !errors.As(err, &e) {
+				return fmt.Errorf("ratchet: %w", err)
+			}
+			ratchetErr = err
+		}
+	}
+
+	return combineGateErrors(
+		ratchetErr,
+		passThresholds(statistics.ThresholdViolations),
+		passCoverageBaseline(statistics, full.coverageBaseline),
+	)
 }
 
 func (full *fullCover) dump(ctx context.Context) error {
@@ -115,14 +193,16 @@ func (full *fullCover) dump(ctx context.Context) error {
 	return nil
 }
 
-func (full *fullCover) generateStatistics() (*report.Statistics, error) {
-	packages, err := parser.NewParser(full.coverFilenames, full.logger).Parse(nil)
+func (full *fullCover) generateStatistics(ctx context.Context) (*report.Statistics, error) {
+	packages, err := parser.NewParser(full.coverFilenames, full.logger, parserOptions(ctx, full.workspace, filepath.Join(full.repositoryPath, full.moduleDir), full.buildFlags, full.staleProfilePolicy)...).Parse(nil)
 	if err != nil {
 		return nil, err
 	}
 
 	statistics := &report.Statistics{
-		StatisticsType: report.FullStatisticsType,
+		StatisticsType:   report.FullStatisticsType,
+		CoverageBaseline: full.coverageBaseline,
+		RepositoryPath:   full.repositoryPath,
 	}
 	m := make(map[string]*report.CoverageProfile)
 	fileCache := make(fileContentsCache)
@@ -130,17 +210,12 @@ func (full *fullCover) generateStatistics() (*report.Statistics, error) {
 		full.logger.Debugf("package: %s", pkg.Name)
 		full.ignoreProfiles = append(full.ignoreProfiles, pkg.IgnoreProfiles...)
 
-		p, err := build.Import(pkg.Name, ".", build.FindOnly)
-		if err != nil {
-			return nil, fmt.Errorf("build import %w", err)
-		}
-
 		for _, fun := range pkg.Functions {
 
 			if ok := inExclueds(
 				full.excludeFiles,
 				full.excludePatterns,
-				formatFilePath(p.Root, fun.File, full.modulePath),
+				formatFilePath(pkg.ModuleDir, fun.File, pkg.ModulePath),
 				full.logger,
 			); ok {
 				continue
@@ -150,7 +225,8 @@ func (full *fullCover) generateStatistics() (*report.Statistics, error) {
 			coverProfile, ok := m[fun.File]
 			if !ok {
 				coverProfile = &report.CoverageProfile{
-					FileName: formatFilePath(p.Root, fun.File, full.modulePath),
+					FileName:   formatFilePath(pkg.ModuleDir, fun.File, pkg.ModulePath),
+					SourceFile: fun.File,
 				}
 				m[fun.File] = coverProfile
 				statistics.CoverageProfile = append(statistics.CoverageProfile, coverProfile)
@@ -170,13 +246,19 @@ func (full *fullCover) generateStatistics() (*report.Statistics, error) {
 				section.Contents = append(section.Contents, fileContents[i-1])
 			}
 
-			node := full.coverageTree.FindOrCreate(strings.TrimPrefix(fun.File, p.Root))
+			node := full.coverageTree.FindOrCreateInModule(pkg.ModulePath, strings.TrimPrefix(fun.File, pkg.ModuleDir))
+			functionProfile := &report.FunctionProfile{
+				Name:      fun.Name,
+				StartLine: fun.StartLine,
+				EndLine:   fun.EndLine,
+			}
 
 			var total, ignored, covered, coveredButIgnored int
 			violated := false
 			for _, st := range fun.Statements {
 				total += 1
 				node.TotalLines += 1
+				functionProfile.Statements = append(functionProfile.Statements, newStatementProfile(st))
 
 				if st.Mode == parser.Ignore && st.Reached > 0 {
 					coveredButIgnored++
@@ -205,6 +287,7 @@ func (full *fullCover) generateStatistics() (*report.Statistics, error) {
 			coverProfile.TotalEffectiveLines += (total - ignored)
 			coverProfile.TotalIgnoredLines += ignored
 			coverProfile.TotalViolationLines = append(coverProfile.TotalViolationLines, section.ViolationLines...)
+			coverProfile.Functions = append(coverProfile.Functions, functionProfile)
 			if violated {
 				coverProfile.ViolationSections = append(coverProfile.ViolationSections, section)
 			}
@@ -213,8 +296,12 @@ func (full *fullCover) generateStatistics() (*report.Statistics, error) {
 	}
 
 	full.coverageTree.CollectCoverageData()
+	statistics.Modules = moduleCoverages(full.workspace, full.coverageTree.All(), full.moduleErrors)
+	statistics.TestResults = full.testResults
 
 	reBuildStatistics(statistics, full.excludeFiles)
+	attachIgnoredSections(statistics, full.ignoreProfiles)
+	attachCoveringTests(statistics, full.attribution)
 
 	return statistics, nil
 }
@@ -8,11 +8,13 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/Azure/gocover/pkg/annotation"
 	"github.com/Azure/gocover/pkg/dbclient"
+	"github.com/Azure/gocover/pkg/parser"
 	"github.com/Azure/gocover/pkg/report"
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/sirupsen/logrus"
@@ -23,6 +25,7 @@ const (
 	DefaultReportFormat     = "html"
 	DefaultCompareBranch    = "origin/master"
 	DefaultCoverageBaseline = 80.0
+	DefaultParallel         = 1
 )
 
 // excludeFileCache cache contains exclude file
@@ -80,6 +83,71 @@ func reBuildStatistics(s *report.Statistics, cache excludeFileCache) {
 	}
 }
 
+// newStatementProfile converts the parsed statement into the statement profile of the report.
+func newStatementProfile(st *parser.Statement) *report.StatementProfile {
+	return &report.StatementProfile{
+		StartLine: st.StartLine,
+		EndLine:   st.EndLine,
+		Reached:   st.Reached,
+		Ignored:   st.Mode == parser.Ignore,
+	}
+}
+
+// attachIgnoredSections attaches the ignore annotations to the coverage profiles of the statistics.
+// For block ignore, only the blocks that contain statements of the coverage profile are attached,
+// so diff coverage only reports the ignore annotations that take effect on the changes.
+func attachIgnoredSections(statistics *report.Statistics, ignoreProfiles []*annotation.IgnoreProfile) {
+	m := make(map[string]*annotation.IgnoreProfile)
+	for _, p := range ignoreProfiles {
+		m[p.Filename] = p
+	}
+
+	for _, coverProfile := range statistics.CoverageProfile {
+		ignoreProfile, ok := m[coverProfile.SourceFile]
+		if !ok {
+			continue
+		}
+
+		if ignoreProfile.Type == annotation.FILE_IGNORE {
+			coverProfile.IgnoredSections = []*report.IgnoredSection{
+				{Annotation: ignoreProfile.Annotation, Comments: ignoreProfile.Comments},
+			}
+			continue
+		}
+
+		var sections []*report.IgnoredSection
+		for _, block := range ignoreProfile.IgnoreBlocks {
+			startLine, endLine := block.Lines[0], block.Lines[len(block.Lines)-1]
+			if !containsStatement(coverProfile, startLine, endLine) {
+				continue
+			}
+			sections = append(sections, &report.IgnoredSection{
+				Annotation:     block.Annotation,
+				Comments:       block.Comments,
+				AnnotationLine: block.AnnotationLineNumber,
+				StartLine:      startLine,
+				EndLine:        endLine,
+			})
+		}
+		sort.Slice(sections, func(i, j int) bool {
+			return sections[i].StartLine < sections[j].StartLine
+		})
+		coverProfile.IgnoredSections = sections
+	}
+}
+
+// containsStatement checks whether any statement of the coverage profile starts among [startLine, endLine].
+func containsStatement(coverProfile *report.CoverageProfile, startLine, endLine int) bool {
+	for _, fun := range coverProfile.Functions {
+		for _, st := range fun.Statements {
+			if st.StartLine >= startLine && st.StartLine <= endLine {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // formatFilePath format filename that strip root path and adds module path
 // fileNamePath is the absolute path of the file, modulePath is the module path of go module
 // for example:
@@ -161,6 +229,40 @@ func storeIgnoreProfileData(ctx context.Context, dbClient dbclient.DbClient, ign
 	return dbClient.StoreIgnoreProfileDataFromFile(ctx, data)
 }
 
+// passCoverageBaseline returns LowCoverageErrorExitCode error when the total coverage is lower than the coverage baseline.
+func passCoverageBaseline(statistics *report.Statistics, coverageBaseline float64) error {
+	if statistics.TotalCoveragePercent < coverageBaseline {
+		return WrapErrorWithCode(
+			fmt.Errorf("the coverage baseline pass rate is %.2f, currently is %.2f",
+				coverageBaseline,
+				statistics.TotalCoveragePercent,
+			),
+			LowCoverageErrorExitCode,
+			"",
+		)
+	}
+	return nil
+}
+
+// combineGateErrors returns the error of the failed gates with LowCoverageErrorExitCode, nil if all of them pass.
+// The error of the only failed gate is returned as it is.
+func combineGateErrors(errs ...error) error {
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	default:
+		return WrapErrorWithCode(errors.Join(failed...), LowCoverageErrorExitCode, "")
+	}
+}
+
 // dump outputs all coverage results
 func dump(all []*report.AllInformation, logger logrus.FieldLogger) {
 	logger.Debug("Summary of coverage:")
@@ -9,9 +9,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/Azure/gocover/pkg/annotation"
 	"github.com/Azure/gocover/pkg/dbclient"
 	"github.com/Azure/gocover/pkg/report"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/tools/cover"
 )
 
 func TestCalculateCoverage(t *testing.T) {
@@ -139,6 +141,79 @@ func TestReBuildStatistics(t *testing.T) {
 	})
 }
 
+func TestAttachIgnoredSections(t *testing.T) {
+	t.Run("attachIgnoredSections", func(t *testing.T) {
+		s := &report.Statistics{
+			CoverageProfile: []*report.CoverageProfile{
+				{
+					SourceFile: "/foo.go",
+					Functions: []*report.FunctionProfile{
+						{Statements: []*report.StatementProfile{{StartLine: 4, EndLine: 4}, {StartLine: 8, EndLine: 8}}},
+					},
+				},
+				{SourceFile: "/bar.go"},
+				{SourceFile: "/zoo.go"},
+			},
+		}
+		ignoreProfiles := []*annotation.IgnoreProfile{
+			{
+				Type:     annotation.BLOCK_IGNORE,
+				Filename: "/foo.go",
+				IgnoreBlocks: map[cover.ProfileBlock]*annotation.IgnoreBlock{
+					{StartLine: 7}:  {Annotation: "//+gocover:ignore:block", Comments: "second", AnnotationLineNumber: 7, Lines: []int{7, 8, 9}},
+					{StartLine: 3}:  {Annotation: "//+gocover:ignore:block", Comments: "first", AnnotationLineNumber: 3, Lines: []int{3, 4}},
+					{StartLine: 20}: {Annotation: "//+gocover:ignore:block", Comments: "not changed", AnnotationLineNumber: 20, Lines: []int{20, 21}},
+				},
+			},
+			{Type: annotation.FILE_IGNORE, Filename: "/bar.go", Annotation: "//+gocover:ignore:file", Comments: "generated"},
+		}
+
+		attachIgnoredSections(s, ignoreProfiles)
+
+		foo := s.CoverageProfile[0].IgnoredSections
+		if len(foo) != 2 {
+			t.Fatalf("should have 2 ignored sections, but get %d", len(foo))
+		}
+		if foo[0].Comments != "first" || foo[0].StartLine != 3 || foo[0].EndLine != 4 {
+			t.Errorf("unexpected first section: %+v", foo[0])
+		}
+		if foo[1].Comments != "second" || foo[1].AnnotationLine != 7 || foo[1].EndLine != 9 {
+			t.Errorf("unexpected second section: %+v", foo[1])
+		}
+
+		bar := s.CoverageProfile[1].IgnoredSections
+		if len(bar) != 1 || bar[0].Comments != "generated" || bar[0].StartLine != 0 {
+			t.Errorf("should have file ignored section, but get %+v", bar)
+		}
+
+		if len(s.CoverageProfile[2].IgnoredSections) != 0 {
+			t.Errorf("should have no ignored section, but get %d", len(s.CoverageProfile[2].IgnoredSections))
+		}
+	})
+}
+
+func TestPassCoverageBaseline(t *testing.T) {
+	t.Run("pass", func(t *testing.T) {
+		if err := passCoverageBaseline(&report.Statistics{TotalCoveragePercent: 80}, 80); err != nil {
+			t.Errorf("should pass, but get: %s", err)
+		}
+	})
+
+	t.Run("low coverage", func(t *testing.T) {
+		err := passCoverageBaseline(&report.Statistics{TotalCoveragePercent: 79.5}, 80)
+		var e *GoCoverError
+		if !errors.As(err, &e) {
+			t.Fatalf("should return GoCoverError, but get: %v", err)
+		}
+		if e.ExitCode != LowCoverageErrorExitCode {
+			t.Errorf("exit code should be %d, but get %d", LowCoverageErrorExitCode, e.ExitCode)
+		}
+		if e.Error() != "the coverage baseline pass rate is 80.00, currently is 79.50" {
+			t.Errorf("unexpected error message: %s", e.Error())
+		}
+	})
+}
+
 func TestFindFileContents(t *testing.T) {
 	t.Run("findFileContents", func(t *testing.T) {
 		dir := t.TempDir()
@@ -0,0 +1,199 @@
+package gocover
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Azure/gocover/pkg/gittool"
+	"github.com/Azure/gocover/pkg/parser"
+	"github.com/Azure/gocover/pkg/report"
+	"golang.org/x/tools/cover"
+)
+
+// blockPosition identifies a statement block of the cover profile in a file.
+type blockPosition struct {
+	startLine, startCol, endLine, endCol int
+}
+
+// indirectCoverageLoss finds the statements that are covered on the compared branch but not covered any more,
+// in the lines unchanged by the diff, for example, the statements only reached by a deleted test.
+func (diff *diffCover) indirectCoverageLoss(ctx context.Context, changes []*gittool.Change) ([]*report.IndirectCoverageLoss, error) {
+	compareProfiles, err := parser.ParseCoverProfiles(diff.compareCoverFilenames)
+	if err != nil {
+		return nil, fmt.Errorf("load compare cover profiles: %w", err)
+	}
+	profiles, err := parser.ParseCoverProfiles(diff.coverFilenames)
+	if err != nil {
+		return nil, fmt.Errorf("load cover profiles: %w", err)
+	}
+	files, err := diff.profileFiles(ctx, profiles, changes)
+	if err != nil {
+		return nil, fmt.Errorf("resolve files of cover profiles: %w", err)
+	}
+
+	var result []*report.IndirectCoverageLoss
+	// the files are only used for matching, don't add them into the excluded files of the report
+	excludeFiles := make(excludeFileCache)
+	for _, loss := range findIndirectCoverageLoss(compareProfiles, profiles, files) {
+		if inExclueds(excludeFiles, diff.excludePatterns, loss.FileName, diff.logger) {
+			continue
+		}
+		diff.logger.Warnf("%s: lines %v are covered on the compared branch but not covered any more", loss.FileName, loss.Lines)
+		result = append(result, loss)
+	}
+	return result, nil
+}
+
+// profileFile is the file of the cover profiles of HEAD.
+type profileFile struct {
+	// comparedFileName is the file name in the cover profiles of the compared branch, it differs for the renamed file.
+	comparedFileName string
+	// change is the change of the file, nil if the file is not changed.
+	change *gittool.Change
+}
+
+// profileFiles maps the files of the cover profiles, which are prefixed with the module path, to the changes,
+// which are relative to the repository, by the directories of the packages like the statistics of diff coverage.
+func (diff *diffCover) profileFiles(ctx context.Context, profiles []*cover.Profile, changes []*gittool.Change) (map[string]*profileFile, error) {
+	changedFiles := make(map[string]*gittool.Change)
+	for _, change := range changes {
+		changedFiles[filepath.ToSlash(change.FileName)] = change
+	}
+
+	var importPaths []string
+	seen := make(map[string]bool)
+	for _, profile := range profiles {
+		if importPath := path.Dir(profile.FileName); !seen[importPath] {
+			seen[importPath] = true
+			importPaths = append(importPaths, importPath)
+		}
+	}
+	if len(importPaths) == 0 {
+		return nil, nil
+	}
+	importer := packageImporter(ctx, diff.workspace, filepath.Join(diff.repositoryPath, diff.moduleDir), diff.buildFlags)
+	packages, err := importer(importPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*profileFile)
+	for _, profile := range profiles {
+		file := &profileFile{comparedFileName: profile.FileName}
+		result[profile.FileName] = file
+
+		pkg, ok := packages[path.Dir(profile.FileName)]
+		if !ok {
+			continue
+		}
+		rel, err := filepath.Rel(diff.repositoryPath, filepath.Join(pkg.Dir, path.Base(profile.FileName)))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		change, ok := changedFiles[filepath.ToSlash(rel)]
+		if !ok {
+			continue
+		}
+		file.change = change
+		if change.Mode == gittool.RenameMode {
+			// the file is named by its old path in the cover profiles of the compared branch
+			old, err := filepath.Rel(pkg.ModuleDir, filepath.Join(diff.repositoryPath, change.OldFileName))
+			if err != nil || strings.HasPrefix(old, "..") {
+				file.comparedFileName = ""
+				continue
+			}
+			file.comparedFileName = path.Join(pkg.ModulePath, filepath.ToSlash(old))
+		}
+	}
+	return result, nil
+}
+
+// findIndirectCoverageLoss compares the statement blocks of the lines that are not changed,
+// and returns the start lines of the blocks covered in compareProfiles but not covered in profiles.
+// The blocks of the changed files are moved to their lines in HEAD, the blocks that contain changed lines are skipped.
+func findIndirectCoverageLoss(compareProfiles, profiles []*cover.Profile, files map[string]*profileFile) []*report.IndirectCoverageLoss {
+	compared := coveredBlocks(compareProfiles)
+	current := coveredBlocks(profiles)
+
+	var result []*report.IndirectCoverageLoss
+	for fileName, blocks := range current {
+		comparedFileName, change := fileName, (*gittool.Change)(nil)
+		if file, ok := files[fileName]; ok {
+			comparedFileName, change = file.comparedFileName, file.change
+		}
+		comparedBlocks, ok := compared[comparedFileName]
+		if !ok {
+			continue
+		}
+
+		lines := make(map[int]bool)
+		for position, covered := range comparedBlocks {
+			if !covered {
+				continue
+			}
+			moved, ok := movedBlock(change, position)
+			if !ok {
+				continue
+			}
+			if covered, ok := blocks[moved]; ok && !covered {
+				lines[moved.startLine] = true
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		loss := &report.IndirectCoverageLoss{FileName: fileName}
+		for line := range lines {
+			loss.Lines = append(loss.Lines, line)
+		}
+		sort.Ints(loss.Lines)
+		result = append(result, loss)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FileName < result[j].FileName
+	})
+	return result
+}
+
+// movedBlock returns the position in HEAD of the block in compared branch,
+// false is returned if any line of the block is changed.
+func movedBlock(change *gittool.Change, position blockPosition) (blockPosition, bool) {
+	if change == nil {
+		return position, true
+	}
+	startLine, ok := change.MapLine(position.startLine)
+	if !ok {
+		return position, false
+	}
+	endLine, ok := change.MapLine(position.endLine)
+	// the lines deleted or added inside the block change the length of the block or add lines into it
+	if !ok || endLine-startLine != position.endLine-position.startLine || change.HasAddedLines(startLine, endLine) {
+		return position, false
+	}
+	position.startLine, position.endLine = startLine, endLine
+	return position, true
+}
+
+// coveredBlocks returns whether each statement block is covered, grouped by file name.
+// A block is covered if it's covered in any of the profiles.
+func coveredBlocks(profiles []*cover.Profile) map[string]map[blockPosition]bool {
+	result := make(map[string]map[blockPosition]bool)
+	for _, profile := range profiles {
+		blocks, ok := result[profile.FileName]
+		if !ok {
+			blocks = make(map[blockPosition]bool)
+			result[profile.FileName] = blocks
+		}
+		for _, b := range profile.Blocks {
+			position := blockPosition{startLine: b.StartLine, startCol: b.StartCol, endLine: b.EndLine, endCol: b.EndCol}
+			blocks[position] = blocks[position] || b.Count > 0
+		}
+	}
+	return result
+}
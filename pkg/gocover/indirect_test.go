@@ -0,0 +1,113 @@
+package gocover
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/gocover/pkg/gittool"
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/tools/cover"
+)
+
+func TestFindIndirectCoverageLoss(t *testing.T) {
+	block := func(line, count int) cover.ProfileBlock {
+		return cover.ProfileBlock{StartLine: line, StartCol: 2, EndLine: line + 1, EndCol: 10, NumStmt: 1, Count: count}
+	}
+
+	compareProfiles := []*cover.Profile{
+		{FileName: "github.com/Azure/gocover/pkg/foo/foo.go", Blocks: []cover.ProfileBlock{block(3, 1), block(6, 1), block(9, 0)}},
+		{FileName: "github.com/Azure/gocover/pkg/foo/changed.go", Blocks: []cover.ProfileBlock{block(3, 1), block(9, 1)}},
+		{FileName: "github.com/Azure/gocover/pkg/foo/bar.go", Blocks: []cover.ProfileBlock{block(3, 1)}},
+		{FileName: "github.com/Azure/gocover/pkg/foo/old.go", Blocks: []cover.ProfileBlock{block(3, 1)}},
+	}
+	profiles := []*cover.Profile{
+		// 3 is not covered any more, 6 is still covered, 9 is never covered
+		{FileName: "github.com/Azure/gocover/pkg/foo/foo.go", Blocks: []cover.ProfileBlock{block(3, 0), block(6, 0), block(9, 0)}},
+		// blocks of the same file from another profile are merged
+		{FileName: "github.com/Azure/gocover/pkg/foo/foo.go", Blocks: []cover.ProfileBlock{block(3, 0), block(6, 2), block(9, 0)}},
+		// the blocks of the changed file are moved by 2 added lines, the block of 9 is moved to 11 with a line added into it
+		{FileName: "github.com/Azure/gocover/pkg/foo/changed.go", Blocks: []cover.ProfileBlock{block(3, 0), block(5, 0),
+			{StartLine: 11, StartCol: 2, EndLine: 13, EndCol: 10, NumStmt: 2, Count: 0}}},
+		// still covered
+		{FileName: "github.com/Azure/gocover/pkg/foo/bar.go", Blocks: []cover.ProfileBlock{block(3, 1)}},
+		// renamed from old.go
+		{FileName: "github.com/Azure/gocover/pkg/foo/renamed.go", Blocks: []cover.ProfileBlock{block(3, 0)}},
+		// new file
+		{FileName: "github.com/Azure/gocover/pkg/foo/new.go", Blocks: []cover.ProfileBlock{block(3, 0)}},
+	}
+	files := map[string]*profileFile{
+		"github.com/Azure/gocover/pkg/foo/changed.go": {
+			comparedFileName: "github.com/Azure/gocover/pkg/foo/changed.go",
+			change: &gittool.Change{FileName: "pkg/foo/changed.go", Mode: gittool.ModifyMode, Sections: []*gittool.Section{
+				{Operation: gittool.Add, StartLine: 1, EndLine: 2, Count: 2},
+				{Operation: gittool.Add, StartLine: 12, EndLine: 12, Count: 1},
+			}},
+		},
+		"github.com/Azure/gocover/pkg/foo/renamed.go": {
+			comparedFileName: "github.com/Azure/gocover/pkg/foo/old.go",
+			change:           &gittool.Change{FileName: "pkg/foo/renamed.go", Mode: gittool.RenameMode, OldFileName: "pkg/foo/old.go"},
+		},
+		"github.com/Azure/gocover/pkg/foo/new.go": {
+			comparedFileName: "github.com/Azure/gocover/pkg/foo/new.go",
+			change:           &gittool.Change{FileName: "pkg/foo/new.go", Mode: gittool.NewMode},
+		},
+	}
+
+	expected := []*report.IndirectCoverageLoss{
+		{FileName: "github.com/Azure/gocover/pkg/foo/changed.go", Lines: []int{5}},
+		{FileName: "github.com/Azure/gocover/pkg/foo/foo.go", Lines: []int{3}},
+		{FileName: "github.com/Azure/gocover/pkg/foo/renamed.go", Lines: []int{3}},
+	}
+	actual := findIndirectCoverageLoss(compareProfiles, profiles, files)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expect %+v, but get %+v", expected, actual)
+	}
+}
+
+func TestIndirectCoverageLoss(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile := func(name, contents string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	base := writeProfile("base.out", "mode: set\n"+
+		"example.com/m/pkg/foo.go:3.2,4.10 1 1\n"+
+		"example.com/m/pkg/zz_generated.go:3.2,4.10 1 1\n")
+	head := writeProfile("coverage.out", "mode: set\n"+
+		"example.com/m/pkg/foo.go:3.2,4.10 1 0\n"+
+		"example.com/m/pkg/foo.go:5.2,6.10 1 0\n"+
+		"example.com/m/pkg/zz_generated.go:3.2,4.10 1 0\n")
+
+	// the module is in the sub directory of the repository, the changed file is relative to the repository
+	diff := &diffCover{
+		repositoryPath:        dir,
+		workspace:             newWorkspace([]*goModule{{Path: "example.com/m", Dir: filepath.Join(dir, "sub")}}),
+		coverFilenames:        []string{head},
+		compareCoverFilenames: []string{base},
+		excludePatterns:       []string{"**/zz_generated.go"},
+		logger:                logrus.New(),
+	}
+	changes := []*gittool.Change{{FileName: "sub/pkg/foo.go", Mode: gittool.ModifyMode, Sections: []*gittool.Section{
+		{Operation: gittool.Add, StartLine: 1, EndLine: 2, Count: 2},
+	}}}
+	loss, err := diff.indirectCoverageLoss(context.Background(), changes)
+	if err != nil {
+		t.Fatalf("should not error, but get: %s", err)
+	}
+	if len(loss) != 1 || loss[0].FileName != "example.com/m/pkg/foo.go" || !reflect.DeepEqual(loss[0].Lines, []int{5}) {
+		t.Errorf("unexpected indirect coverage loss: %+v", loss)
+	}
+
+	diff.compareCoverFilenames = []string{filepath.Join(dir, "nonexist.out")}
+	if _, err := diff.indirectCoverageLoss(context.Background(), changes); err == nil {
+		t.Error("should return error when compare cover profile is not found")
+	}
+}
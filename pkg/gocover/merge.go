@@ -0,0 +1,28 @@
+package gocover
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Azure/gocover/pkg/parser"
+)
+
+// MergeCoverProfiles merges the cover profiles, as well as the GOCOVERDIR directories of binary coverage data,
+// and writes the result to w in the standard cover profile format.
+func MergeCoverProfiles(ctx context.Context, coverProfiles []string, w io.Writer) error {
+	coverFilenames, clean, err := resolveCoverProfiles(ctx, coverProfiles)
+	if err != nil {
+		return err
+	}
+	defer clean()
+
+	profiles, err := parser.ParseCoverProfiles(coverFilenames)
+	if err != nil {
+		return fmt.Errorf("merge cover profiles: %w", err)
+	}
+	if err := parser.WriteProfiles(w, profiles); err != nil {
+		return fmt.Errorf("write cover profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package gocover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/sirupsen/logrus"
+)
+
+var _ GoCoverTestExecutor = (*allModulesTestExecutor)(nil)
+
+// allModulesTestExecutor runs the tests of all the modules under the module directory,
+// and aggregates the cover profiles of the modules into one report and one gating decision.
+// A module whose tests fail doesn't stop the others, it's reported with its failure instead.
+type allModulesTestExecutor struct {
+	repositoryPath string
+	moduleDir      string
+	parallel       int
+	option         *GoCoverTestOption
+	logger         logrus.FieldLogger
+}
+
+// moduleTestResult is the result of running the tests of a module.
+type moduleTestResult struct {
+	module        *goModule
+	coverProfiles []string
+	testResults   *report.TestResults
+	err           error
+}
+
+func (e *allModulesTestExecutor) Run(ctx context.Context) error {
+	modules, err := discoverModules(filepath.Join(e.repositoryPath, e.moduleDir))
+	if err != nil {
+		return fmt.Errorf("discover modules: %w", err)
+	}
+	e.logger.Infof("found %d modules", len(modules))
+
+	var coverProfiles, failedModules, running []string
+	var testResults *report.TestResults
+	moduleErrors := make(map[string]string)
+	for _, result := range e.runModules(ctx, modules) {
+		if result.testResults != nil {
+			if testResults == nil {
+				testResults = &report.TestResults{}
+			}
+			testResults.Packages = append(testResults.Packages, result.testResults.Packages...)
+		}
+		if result.err != nil {
+			var timeoutErr *TestTimeoutError
+			if errors.As(result.err, &timeoutErr) {
+				running = append(running, timeoutErr.Running...)
+			}
+			e.logger.WithError(result.err).Errorf("run unit tests of module %s", result.module.Path)
+			moduleErrors[result.module.Path] = result.err.Error()
+			failedModules = append(failedModules, result.module.Path)
+			continue
+		}
+		coverProfiles = append(coverProfiles, result.coverProfiles...)
+	}
+
+	if err := writeTestResults(e.option.JUnitFile, testResults, e.logger); err != nil {
+		return err
+	}
+	if err := contextError(ctx, func() []string { return running }); err != nil {
+		return err
+	}
+
+	gocover, err := buildGoCover(e.option.CoverageMode, e.option, &testRun{
+		coverProfiles: coverProfiles,
+		moduleErrors:  moduleErrors,
+		testResults:   testResults,
+	}, e.logger)
+	if err != nil {
+		return err
+	}
+
+	runErr := gocover.Run(ctx)
+	if runErr != nil {
+		runErr = fmt.Errorf("run gocover: %w", runErr)
+		e.logger.WithError(runErr).Error()
+	}
+
+	if len(failedModules) != 0 {
+		sort.Strings(failedModules)
+		return WrapErrorWithCode(
+			fmt.Errorf("unit test failed in modules: %s", strings.Join(failedModules, ", ")),
+			UnitTestFailedErrorExitCode,
+			"",
+		)
+	}
+	return runErr
+}
+
+// runModules runs the tests of the modules, at most parallel modules at the same time.
+// The results are in the same order as the modules.
+func (e *allModulesTestExecutor) runModules(ctx context.Context, modules []*goModule) []*moduleTestResult {
+	parallel := e.parallel
+	if parallel < 1 {
+		parallel = DefaultParallel
+	}
+
+	var (
+		wg       sync.WaitGroup
+		outputMu sync.Mutex
+		results  = make([]*moduleTestResult, len(modules))
+		sem      = make(chan struct{}, parallel)
+	)
+	for i, m := range modules {
+		wg.Add(1)
+		go func(i int, m *goModule) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if parallel == 1 {
+				results[i] = e.runModule(ctx, m, e.option.StdOut, e.option.StdErr)
+				return
+			}
+
+			// buffer the output of the module, so the outputs of the modules running at the same time don't interleave
+			var stdout, stderr bytes.Buffer
+			results[i] = e.runModule(ctx, m, &stdout, &stderr)
+
+			outputMu.Lock()
+			defer outputMu.Unlock()
+			if e.option.StdOut != nil {
+				_, _ = io.Copy(e.option.StdOut, &stdout)
+			}
+			if e.option.StdErr != nil {
+				_, _ = io.Copy(e.option.StdErr, &stderr)
+			}
+		}(i, m)
+	}
+	wg.Wait()
+	return results
+}
+
+// runModule runs the tests of the module, the cover profiles are written into the sub directory of the output directory,
+// that is the module directory relative to the repository.
+func (e *allModulesTestExecutor) runModule(ctx context.Context, m *goModule, stdout, stderr io.Writer) *moduleTestResult {
+	result := &moduleTestResult{module: m}
+
+	moduleDir, err := filepath.Rel(e.repositoryPath, m.Dir)
+	if err != nil {
+		result.err = fmt.Errorf("get relative path of module: %w", err)
+		return result
+	}
+
+	o := *e.option
+	o.ModuleDir = moduleDir
+	o.OutputDir = filepath.Join(e.option.OutputDir, "modules", moduleDir)
+	o.StdOut = stdout
+	o.StdErr = stderr
+	o.Logger = e.logger.WithField("module", m.Path)
+	if err := os.MkdirAll(o.OutputDir, 0755); err != nil {
+		result.err = fmt.Errorf("create output directory: %w", err)
+		return result
+	}
+
+	executor, err := newModuleTestExecutor(&o, e.repositoryPath)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	o.Logger.Infof("run unit tests of module %s", m.Path)
+	result.coverProfiles, result.err = executor.coverProfiles(ctx)
+	result.testResults = executor.testResults()
+	return result
+}
@@ -0,0 +1,123 @@
+package gocover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Azure/gocover/pkg/dbclient"
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAllModulesTestExecutor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip running go test of modules in short mode")
+	}
+
+	dir := t.TempDir()
+	source := "package foo\n\nfunc Foo(a int) int {\n\tif a > 0 {\n\t\treturn a\n\t}\n\treturn 0\n}\n"
+	writeWorkspaceFile(t, dir, "go.mod", "module example.com/root\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "foo/foo.go", source)
+	writeWorkspaceFile(t, dir, "foo/foo_test.go", "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tFoo(1)\n}\n")
+	writeWorkspaceFile(t, dir, "failing/go.mod", "module example.com/root/failing\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "failing/foo.go", source)
+	writeWorkspaceFile(t, dir, "failing/foo_test.go", "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n\tt.Fatal(\"fail\")\n}\n")
+
+	var output bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&output)
+
+	o := NewGoCoverTestOption()
+	o.RepositoryPath = dir
+	o.ModuleDir = "./"
+	o.OutputDir = t.TempDir()
+	o.CoverageMode = FullCoverage
+	o.ExecutorMode = GoExecutor
+	o.ReportFormat = "json"
+	o.ReportName = "coverage"
+	o.CoverageBaseline = 0
+	o.AllModules = true
+	o.Parallel = 2
+	o.DbOption = &dbclient.DBOption{}
+	o.StdOut = &output
+	o.StdErr = &output
+	o.Logger = logger
+
+	executor, err := NewGoCoverTestExecutor(o)
+	if err != nil {
+		t.Fatalf("should not error, but get: %s", err)
+	}
+
+	err = executor.Run(context.Background())
+	var goCoverError *GoCoverError
+	if !errors.As(err, &goCoverError) || goCoverError.ExitCode != UnitTestFailedErrorExitCode {
+		t.Fatalf("should return unit test failed error, but get: %v\n%s", err, output.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(o.OutputDir, "coverage.json"))
+	if err != nil {
+		t.Fatalf("the report should be generated for the passed modules: %s", err)
+	}
+	var result report.JSONReport
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Modules) != 2 {
+		t.Fatalf("expect 2 modules, but get %d", len(result.Modules))
+	}
+	root, failing := result.Modules[0], result.Modules[1]
+	if root.Path != "example.com/root" || root.Error != "" || root.CoveredLines != 2 || root.EffectiveLines != 3 {
+		t.Errorf("unexpected coverage of passed module: %+v", root)
+	}
+	if failing.Path != "example.com/root/failing" || failing.Error == "" || failing.EffectiveLines != 0 {
+		t.Errorf("unexpected coverage of failed module: %+v", failing)
+	}
+	if result.TestResults == nil || result.TestResults.Passed != 1 || len(result.TestResults.FailedTests) != 1 ||
+		result.TestResults.FailedTests[0].Package != "example.com/root/failing" {
+		t.Errorf("the test results of all modules should be reported, but get: %+v", result.TestResults)
+	}
+	if len(result.Files) != 1 || result.Files[0].FileName != "example.com/root/foo/foo.go" {
+		t.Errorf("only the files of passed module should be reported, but get %d files", len(result.Files))
+	}
+}
+
+func TestAllModulesTestExecutor_RunModules_Output(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is written for sh")
+	}
+
+	dir := t.TempDir()
+	writeWorkspaceFile(t, dir, "a/go.mod", "module example.com/a\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "b/go.mod", "module example.com/b\n\ngo 1.20\n")
+
+	var stdout, stderr bytes.Buffer
+	o := NewGoCoverTestOption()
+	o.RepositoryPath = dir
+	o.OutputDir = t.TempDir()
+	o.ExecutorMode = CustomExecutor
+	o.CustomCommand = "echo stdout; echo stderr >&2"
+	o.CustomCoverProfiles = []string{"*.out"}
+	o.StdOut = &stdout
+	o.StdErr = &stderr
+	o.Logger = logrus.New()
+
+	executor := &allModulesTestExecutor{repositoryPath: dir, parallel: 2, option: o, logger: o.Logger}
+	executor.runModules(context.Background(), []*goModule{
+		{Path: "example.com/a", Dir: filepath.Join(dir, "a")},
+		{Path: "example.com/b", Dir: filepath.Join(dir, "b")},
+	})
+
+	if stdout.String() != "stdout\nstdout\n" {
+		t.Errorf("the stdout of the modules should be written to stdout, but get %q", stdout.String())
+	}
+	if stderr.String() != "stderr\nstderr\n" {
+		t.Errorf("the stderr of the modules should be written to stderr, but get %q", stderr.String())
+	}
+}
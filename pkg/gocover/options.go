@@ -2,9 +2,13 @@ package gocover
 
 import (
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/Azure/gocover/pkg/dbclient"
+	"github.com/Azure/gocover/pkg/gittool"
+	"github.com/Azure/gocover/pkg/parser"
+	"github.com/Azure/gocover/pkg/report"
 	"github.com/sirupsen/logrus"
 )
 
@@ -13,24 +17,42 @@ type FullOption struct {
 	CoverProfiles  []string
 	RepositoryPath string
 	ModuleDir      string
+	StaleProfile   parser.StaleProfilePolicy
+
+	// Workspace reports all the modules under ModuleDir as a workspace, even though ModuleDir is a module.
+	Workspace bool
+	// ModuleErrors are the test failures of the modules in the workspace, keyed by module path.
+	ModuleErrors map[string]string
+	// BuildFlags are the flags used to resolve the packages of cover profiles, such as -tags and -mod=vendor.
+	BuildFlags []string
+	// TestResults are the results of the tests that produce the cover profiles, reported along with the coverage.
+	TestResults *report.TestResults
+	// Attribution is the index from the statements to the tests that cover them, reported along with the coverage.
+	Attribution *AttributionIndex
 
 	CoverageBaseline float64
+	Thresholds       []string
 	ReportFormat     string
 	ReportName       string
 	OutputDir        string
 	Excludes         []string
 	Style            string
 
+	RatchetFile      string
+	RatchetTolerance float64
+	UpdateRatchet    bool
+
 	DbOption *dbclient.DBOption
 
 	Logger logrus.FieldLogger
 }
 
 // NewDiffOption returns a Full Option with default values.
+// The coverage baseline is not set, so full coverage is only gated when the baseline is given explicitly.
 func NewFullOption() *FullOption {
 	return &FullOption{
-		CoverageBaseline: DefaultCoverageBaseline,
-		ReportFormat:     DefaultReportFormat,
+		ReportFormat: DefaultReportFormat,
+		StaleProfile: parser.StaleProfileFail,
 	}
 }
 
@@ -40,13 +62,36 @@ func (o *FullOption) Validate() error {
 
 // DiffOption contains the input to the gocover diff command.
 type DiffOption struct {
-	CoverProfiles  []string
-	CompareBranch  string
-	RepositoryPath string
-	ModuleDir      string
-	ModulePath     string
+	CoverProfiles        []string
+	CompareCoverProfiles []string
+	CompareBranch        string
+	IncludeUncommitted   bool
+	DiffFile             string
+	From                 string
+	To                   string
+	FetchMissing         bool
+	RepositoryPath       string
+	ModuleDir            string
+	ModulePath           string
+	StaleProfile         parser.StaleProfilePolicy
+
+	// Workspace reports all the modules under ModuleDir as a workspace, even though ModuleDir is a module.
+	Workspace bool
+	// ModuleErrors are the test failures of the modules in the workspace, keyed by module path.
+	ModuleErrors map[string]string
+	// BuildFlags are the flags used to resolve the packages of cover profiles, such as -tags and -mod=vendor.
+	BuildFlags []string
+	// TestResults are the results of the tests that produce the cover profiles, reported along with the coverage.
+	TestResults *report.TestResults
+	// TestSelection is how the tests are selected by the changes, reported along with the coverage.
+	TestSelection *report.TestSelection
+	// Attribution is the index from the statements to the tests that cover them, reported along with the coverage.
+	Attribution *AttributionIndex
+	// Changes are the changes used instead of git diff if not nil, they are computed by gocover test for selecting tests.
+	Changes []*gittool.Change
 
 	CoverageBaseline float64
+	Thresholds       []string
 	ReportFormat     string
 	ReportName       string
 	OutputDir        string
@@ -64,6 +109,7 @@ func NewDiffOption() *DiffOption {
 		CompareBranch:    DefaultCompareBranch,
 		CoverageBaseline: DefaultCoverageBaseline,
 		ReportFormat:     DefaultReportFormat,
+		StaleProfile:     parser.StaleProfileFail,
 	}
 }
 
@@ -80,30 +126,72 @@ const (
 
 	GoExecutor     ExecutorMode = "go"
 	GinkgoExecutor ExecutorMode = "ginkgo"
+	CustomExecutor ExecutorMode = "custom"
 )
 
 var ErrUnknownCoverageMode = errors.New("unknown coverage mode")
 var ErrUnknownExecutorMode = errors.New("unknown executor mode")
+var ErrUnknownStaleProfilePolicy = errors.New("unknown stale profile policy")
+var ErrTestSelectionNotSupported = errors.New("test selection is only supported by the go executor in diff coverage mode of a single module")
+
+// validateStaleProfilePolicy checks the policy, empty policy means the default policy of parser.
+func validateStaleProfilePolicy(policy parser.StaleProfilePolicy) error {
+	switch policy {
+	case "", parser.StaleProfileFail, parser.StaleProfileWarn:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownStaleProfilePolicy, policy)
+	}
+}
 
 // GoCoverTestOption contains the input to the gocover govtest command.
 type GoCoverTestOption struct {
-	CoverProfiles  []string
-	CompareBranch  string
-	RepositoryPath string
-	ModuleDir      string
-	ModulePath     string
-	CoverageMode   CoverageMode
-	ExecutorMode   ExecutorMode
-	GinkgoFlags    []string
-	GoFlags        []string
+	CoverProfiles        []string
+	CompareCoverProfiles []string
+	CompareBranch        string
+	IncludeUncommitted   bool
+	DiffFile             string
+	From                 string
+	To                   string
+	FetchMissing         bool
+	RepositoryPath       string
+	ModuleDir            string
+	ModulePath           string
+	StaleProfile         parser.StaleProfilePolicy
+	CoverageMode         CoverageMode
+	ExecutorMode         ExecutorMode
+	GinkgoFlags          []string
+	GoFlags              []string
+	// CustomCommand is the command line of the custom executor, which runs in the module directory with the shell,
+	// {{.OutputDir}} and {{.ModuleDir}} in it are replaced with the absolute paths of the directories.
+	CustomCommand string
+	// CustomCoverProfiles are the doublestar patterns of the cover profiles written by the custom command,
+	// relative to the module directory if not absolute, and the same placeholders are replaced.
+	CustomCoverProfiles []string
+	// AllModules runs the tests of all the modules under ModuleDir, and aggregates them into one report.
+	AllModules bool
+	// Parallel is the number of modules whose tests run at the same time when AllModules is set.
+	Parallel int
+	// SelectTests only runs the tests that reach the changed packages in diff coverage mode.
+	SelectTests bool
+	// Attribution runs each test, or the tests of each package, with its own cover profile,
+	// and attributes the coverage to the tests, empty means no attribution.
+	Attribution AttributionGranularity
+	// JUnitFile is the JUnit xml file the test results are written into, only the go executor supports it.
+	JUnitFile string
 
 	CoverageBaseline float64
+	Thresholds       []string
 	ReportFormat     string
 	ReportName       string
 	OutputDir        string
 	Excludes         []string
 	Style            string
 
+	RatchetFile      string
+	RatchetTolerance float64
+	UpdateRatchet    bool
+
 	DbOption *dbclient.DBOption
 
 	StdOut io.Writer
@@ -117,5 +205,7 @@ func NewGoCoverTestOption() *GoCoverTestOption {
 		CompareBranch:    DefaultCompareBranch,
 		CoverageBaseline: DefaultCoverageBaseline,
 		ReportFormat:     DefaultReportFormat,
+		StaleProfile:     parser.StaleProfileFail,
+		Parallel:         DefaultParallel,
 	}
 }
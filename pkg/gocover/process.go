@@ -0,0 +1,54 @@
+package gocover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandWaitDelay is how long to wait for the output of a killed command, the pipes may be held by its orphaned children.
+const commandWaitDelay = 10 * time.Second
+
+// TestTimeoutError indicates the tests are killed because they don't finish before the deadline.
+type TestTimeoutError struct {
+	// Running are the packages that are running when the tests time out, and the running tests of them if known.
+	Running []string
+}
+
+func (e *TestTimeoutError) Error() string {
+	running := "unknown"
+	if len(e.Running) != 0 {
+		running = strings.Join(e.Running, ", ")
+	}
+	return fmt.Sprintf("tests timed out, running: %s", running)
+}
+
+// newTestTimeoutError returns the timeout error with the timeout exit code.
+func newTestTimeoutError(running []string) *GoCoverError {
+	return WrapErrorWithCode(&TestTimeoutError{Running: running}, TimeoutErrorExitCode, "")
+}
+
+// contextError returns the error of the command killed by the context, nil if the context is not done.
+// running returns what is running when the context is done.
+func contextError(ctx context.Context, running func() []string) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return newTestTimeoutError(running())
+	case ctx.Err() != nil:
+		return fmt.Errorf("run unit tests: %w", ctx.Err())
+	default:
+		return nil
+	}
+}
+
+// newCommand returns the command that is killed along with its child processes when the context is done,
+// such as the test binaries started by `go test`.
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	killProcessGroup(cmd)
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package gocover
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// killProcessGroup kills the process tree of the command with taskkill when the context is done, as windows
+// has no process group to signal, the command is killed alone if taskkill fails.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package gocover
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Azure/gocover/pkg/report"
+)
+
+// RatchetSnapshot is the per package coverage of the module, it's stored as json so it can be committed
+// to the repository, and later runs compare against it to prevent the coverage from dropping.
+type RatchetSnapshot struct {
+	// ModulePath is the path of the go module.
+	ModulePath string `json:"modulePath"`
+	// Packages maps the package path prefixed with module path to its coverage (with ignorance) percent.
+	Packages map[string]float64 `json:"packages"`
+}
+
+// ratchetRegression represents a package whose coverage drops more than the tolerance.
+type ratchetRegression struct {
+	Path     string
+	Baseline float64
+	Coverage float64
+}
+
+// buildRatchetSnapshot builds the snapshot from the directory nodes of the coverage tree,
+// the coverage is rounded to 2 decimals to avoid meaningless changes of the snapshot file.
+func buildRatchetSnapshot(modulePath string, all []*report.AllInformation) *RatchetSnapshot {
+	snapshot := &RatchetSnapshot{
+		ModulePath: modulePath,
+		Packages:   make(map[string]float64),
+	}
+	for _, info := range all {
+		if info.IsLeaf || info.TotalEffectiveLines == 0 {
+			continue
+		}
+		coverage := calculateCoverage(info.TotalCoveredLines-info.TotalCoveredButIgnoreLines, info.TotalEffectiveLines)
+		snapshot.Packages[info.Path] = math.Round(coverage*100) / 100
+	}
+	return snapshot
+}
+
+// loadRatchetSnapshot reads the snapshot file, it returns os.ErrNotExist when the file does not exist.
+func loadRatchetSnapshot(filename string) (*RatchetSnapshot, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &RatchetSnapshot{}
+	if err := json.Unmarshal(bs, snapshot); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %w", filename, err)
+	}
+	return snapshot, nil
+}
+
+// writeRatchetSnapshot writes the snapshot file, json encodes the map with sorted keys,
+// so the file is stable between runs.
+func writeRatchetSnapshot(filename string, snapshot *RatchetSnapshot) error {
+	bs, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(bs, '\n'), 0644)
+}
+
+// compareRatchetSnapshot returns the packages in the baseline whose coverage drops more than the tolerance.
+// Packages that are removed or newly added are not compared.
+func compareRatchetSnapshot(baseline, current *RatchetSnapshot, tolerance float64) []*ratchetRegression {
+	var regressions []*ratchetRegression
+	for path, expected := range baseline.Packages {
+		coverage, ok := current.Packages[path]
+		if !ok {
+			continue
+		}
+		if expected-coverage > tolerance {
+			regressions = append(regressions, &ratchetRegression{Path: path, Baseline: expected, Coverage: coverage})
+		}
+	}
+
+	sort.Slice(regressions, func(i, j int) bool {
+		return regressions[i].Path < regressions[j].Path
+	})
+	return regressions
+}
+
+// ratchet compares the coverage with the snapshot file, and returns LowCoverageErrorExitCode error when
+// any package drops more than the tolerance. The snapshot file is written instead when it does not exist
+// or update is true.
+func (full *fullCover) ratchet() error {
+	current := buildRatchetSnapshot(full.modulePath, full.coverageTree.All())
+
+	if !full.updateRatchet {
+		baseline, err := loadRatchetSnapshot(full.ratchetFile)
+		if err == nil {
+			return passRatchet(compareRatchetSnapshot(baseline, current, full.ratchetTolerance), full.ratchetTolerance)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("load ratchet snapshot: %w", err)
+		}
+		full.logger.Infof("ratchet snapshot %s does not exist, create it", full.ratchetFile)
+	}
+
+	if err := writeRatchetSnapshot(full.ratchetFile, current); err != nil {
+		return fmt.Errorf("write ratchet snapshot: %w", err)
+	}
+	full.logger.Infof("write ratchet snapshot: %s", full.ratchetFile)
+	return nil
+}
+
+func passRatchet(regressions []*ratchetRegression, tolerance float64) error {
+	if len(regressions) == 0 {
+		return nil
+	}
+
+	var details []string
+	for _, r := range regressions {
+		details = append(details, fmt.Sprintf("%s drops from %.2f to %.2f", r.Path, r.Baseline, r.Coverage))
+	}
+	return WrapErrorWithCode(
+		fmt.Errorf("%d packages drop coverage more than %.2f: %s", len(regressions), tolerance, strings.Join(details, "; ")),
+		LowCoverageErrorExitCode,
+		"",
+	)
+}
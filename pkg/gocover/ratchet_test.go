@@ -0,0 +1,179 @@
+package gocover
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/sirupsen/logrus"
+)
+
+func TestBuildRatchetSnapshot(t *testing.T) {
+	t.Run("buildRatchetSnapshot", func(t *testing.T) {
+		snapshot := buildRatchetSnapshot("github.com/Azure/gocover", []*report.AllInformation{
+			{Path: "github.com/Azure/gocover", TotalEffectiveLines: 3, TotalCoveredLines: 2},
+			{Path: "github.com/Azure/gocover/pkg/foo", TotalEffectiveLines: 3, TotalCoveredLines: 3, TotalCoveredButIgnoreLines: 1},
+			{Path: "github.com/Azure/gocover/pkg/foo/foo.go", TotalEffectiveLines: 3, TotalCoveredLines: 2, IsLeaf: true},
+			{Path: "github.com/Azure/gocover/pkg/empty"},
+		})
+
+		if snapshot.ModulePath != "github.com/Azure/gocover" {
+			t.Errorf("unexpected module path: %s", snapshot.ModulePath)
+		}
+		expected := map[string]float64{
+			"github.com/Azure/gocover":         66.67,
+			"github.com/Azure/gocover/pkg/foo": 66.67,
+		}
+		if len(snapshot.Packages) != len(expected) {
+			t.Fatalf("should have %d packages, but get %v", len(expected), snapshot.Packages)
+		}
+		for k, v := range expected {
+			if snapshot.Packages[k] != v {
+				t.Errorf("coverage of %s should be %.2f, but get %.2f", k, v, snapshot.Packages[k])
+			}
+		}
+	})
+}
+
+func TestCompareRatchetSnapshot(t *testing.T) {
+	baseline := &RatchetSnapshot{Packages: map[string]float64{
+		"github.com/Azure/gocover/pkg/a":       80,
+		"github.com/Azure/gocover/pkg/b":       80,
+		"github.com/Azure/gocover/pkg/c":       80,
+		"github.com/Azure/gocover/pkg/removed": 80,
+	}}
+	current := &RatchetSnapshot{Packages: map[string]float64{
+		"github.com/Azure/gocover/pkg/a":   90,
+		"github.com/Azure/gocover/pkg/b":   79.5,
+		"github.com/Azure/gocover/pkg/c":   70,
+		"github.com/Azure/gocover/pkg/new": 10,
+	}}
+
+	t.Run("no tolerance", func(t *testing.T) {
+		regressions := compareRatchetSnapshot(baseline, current, 0)
+		if len(regressions) != 2 {
+			t.Fatalf("should have 2 regressions, but get %d", len(regressions))
+		}
+		if regressions[0].Path != "github.com/Azure/gocover/pkg/b" || regressions[1].Path != "github.com/Azure/gocover/pkg/c" {
+			t.Errorf("unexpected regressions: %+v, %+v", regressions[0], regressions[1])
+		}
+	})
+
+	t.Run("with tolerance", func(t *testing.T) {
+		regressions := compareRatchetSnapshot(baseline, current, 1)
+		if len(regressions) != 1 || regressions[0].Path != "github.com/Azure/gocover/pkg/c" {
+			t.Fatalf("should only have regression of pkg/c, but get %v", regressions)
+		}
+
+		err := passRatchet(regressions, 1)
+		var e *GoCoverError
+		if !errors.As(err, &e) {
+			t.Fatalf("should return GoCoverError, but get: %v", err)
+		}
+		if e.ExitCode != LowCoverageErrorExitCode {
+			t.Errorf("exit code should be %d, but get %d", LowCoverageErrorExitCode, e.ExitCode)
+		}
+	})
+}
+
+func TestRatchet(t *testing.T) {
+	newFullCover := func(filename string, coveredLines int64, update bool) *fullCover {
+		tree := report.NewCoverageTree("github.com/Azure/gocover")
+		node := tree.FindOrCreate("github.com/Azure/gocover/pkg/foo/foo.go")
+		node.TotalLines, node.TotalEffectiveLines, node.TotalCoveredLines = 10, 10, coveredLines
+		tree.CollectCoverageData()
+		return &fullCover{
+			modulePath:    "github.com/Azure/gocover",
+			coverageTree:  tree,
+			ratchetFile:   filename,
+			updateRatchet: update,
+			logger:        logrus.New(),
+		}
+	}
+
+	t.Run("create, compare and update snapshot", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "ratchet.json")
+
+		if err := newFullCover(filename, 8, false).ratchet(); err != nil {
+			t.Fatalf("should create snapshot, but get: %s", err)
+		}
+		snapshot, err := loadRatchetSnapshot(filename)
+		if err != nil {
+			t.Fatalf("should load snapshot, but get: %s", err)
+		}
+		if snapshot.Packages["github.com/Azure/gocover/pkg/foo"] != 80 {
+			t.Errorf("unexpected snapshot: %v", snapshot.Packages)
+		}
+
+		if err := newFullCover(filename, 9, false).ratchet(); err != nil {
+			t.Errorf("should pass when coverage increases, but get: %s", err)
+		}
+
+		var e *GoCoverError
+		if err := newFullCover(filename, 7, false).ratchet(); !errors.As(err, &e) {
+			t.Errorf("should fail when coverage drops, but get: %v", err)
+		}
+
+		if err := newFullCover(filename, 7, true).ratchet(); err != nil {
+			t.Fatalf("should update snapshot, but get: %s", err)
+		}
+		snapshot, _ = loadRatchetSnapshot(filename)
+		if snapshot.Packages["github.com/Azure/gocover/pkg/foo"] != 70 {
+			t.Errorf("snapshot should be updated, but get: %v", snapshot.Packages)
+		}
+	})
+
+	t.Run("create snapshot when coverage is lower than baseline", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "ratchet.json")
+		full := newFullCover(filename, 5, false)
+		full.coverageBaseline = 80
+
+		err := full.checkGates(&report.Statistics{TotalCoveragePercent: 50})
+		var e *GoCoverError
+		if !errors.As(err, &e) || e.ExitCode != LowCoverageErrorExitCode {
+			t.Errorf("should fail by coverage baseline, but get: %v", err)
+		}
+		snapshot, err := loadRatchetSnapshot(filename)
+		if err != nil {
+			t.Fatalf("should create snapshot, but get: %s", err)
+		}
+		if snapshot.Packages["github.com/Azure/gocover/pkg/foo"] != 50 {
+			t.Errorf("unexpected snapshot: %v", snapshot.Packages)
+		}
+	})
+
+	t.Run("combine failed gates", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "ratchet.json")
+		if err := newFullCover(filename, 8, false).ratchet(); err != nil {
+			t.Fatalf("should create snapshot, but get: %s", err)
+		}
+
+		full := newFullCover(filename, 5, false)
+		full.coverageBaseline = 80
+		err := full.checkGates(&report.Statistics{
+			TotalCoveragePercent: 50,
+			ThresholdViolations:  []*report.ThresholdViolation{{Path: "github.com/Azure/gocover/pkg/foo", Coverage: 50, Threshold: 90}},
+		})
+		var e *GoCoverError
+		if !errors.As(err, &e) || e.ExitCode != LowCoverageErrorExitCode {
+			t.Fatalf("should fail with low coverage, but get: %v", err)
+		}
+		for _, s := range []string{"drops from 80.00 to 50.00", "threshold violations", "coverage baseline"} {
+			if !strings.Contains(err.Error(), s) {
+				t.Errorf("error should contain %q, but get: %s", s, err)
+			}
+		}
+	})
+
+	t.Run("bad snapshot", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "ratchet.json")
+		os.WriteFile(filename, []byte("{"), 0644)
+
+		if err := newFullCover(filename, 8, false).ratchet(); err == nil {
+			t.Errorf("should return error")
+		}
+	})
+}
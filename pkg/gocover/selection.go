@@ -0,0 +1,203 @@
+package gocover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Azure/gocover/pkg/gittool"
+	"github.com/Azure/gocover/pkg/report"
+)
+
+// moduleFiles are the files of the module that affect all the packages, all the tests are selected when they change.
+var moduleFiles = map[string]bool{"go.mod": true, "go.sum": true}
+
+// packageGraph is the graph of the packages of a module and the packages their tests depend on.
+type packageGraph struct {
+	// packages are the import paths of the packages keyed by the package directory
+	packages map[string]string
+	// tests are the dependencies of the test binaries keyed by the import path of the tested package,
+	// the dependencies include the packages imported by the tests and all their dependencies
+	tests map[string]map[string]bool
+}
+
+// goListTestPackage is the subset of the `go list -test -json` output used for building the package graph.
+type goListTestPackage struct {
+	ImportPath string
+	Dir        string
+	ForTest    string
+	Deps       []string
+}
+
+// loadPackageGraph loads the package graph of the module in dir with `go list -test`.
+func loadPackageGraph(ctx context.Context, goCmd string, dir string, buildFlags []string) (*packageGraph, error) {
+	args := []string{"list", "-e", "-test", "-json=ImportPath,Dir,ForTest,Deps"}
+	args = append(args, buildFlags...)
+	args = append(args, "./...")
+
+	var stdout, stderr bytes.Buffer
+	cmd := newCommand(ctx, goCmd, args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list -test: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return decodePackageGraph(&stdout)
+}
+
+// decodePackageGraph decodes the stream of json objects written by `go list -test -json`.
+// The test binary of package p is listed as "p.test", the packages recompiled for the test are suffixed
+// with " [p.test]", the suffix is trimmed as they are the same packages for the changes.
+func decodePackageGraph(r io.Reader) (*packageGraph, error) {
+	graph := &packageGraph{
+		packages: make(map[string]string),
+		tests:    make(map[string]map[string]bool),
+	}
+	decoder := json.NewDecoder(r)
+	for {
+		var pkg goListTestPackage
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decode go list output: %w", err)
+		}
+
+		switch {
+		case pkg.ForTest != "" || strings.Contains(pkg.ImportPath, " ["):
+			// the package recompiled for its tests, the test binary has all its dependencies
+		case strings.HasSuffix(pkg.ImportPath, ".test"):
+			deps := make(map[string]bool, len(pkg.Deps))
+			for _, dep := range pkg.Deps {
+				deps[trimTestVariant(dep)] = true
+			}
+			graph.tests[strings.TrimSuffix(pkg.ImportPath, ".test")] = deps
+		case pkg.Dir != "":
+			graph.packages[pkg.Dir] = pkg.ImportPath
+		}
+	}
+	return graph, nil
+}
+
+// trimTestVariant trims the " [p.test]" suffix of the package recompiled for the tests of p.
+func trimTestVariant(importPath string) string {
+	if i := strings.Index(importPath, " ["); i >= 0 {
+		return importPath[:i]
+	}
+	return importPath
+}
+
+// changedPackages returns the import paths of the packages that contain the changed files, the file in a sub directory
+// that is not a package, such as testdata, belongs to the nearest parent package.
+// It returns true if a module file is changed, which may affect all the packages.
+func (g *packageGraph) changedPackages(moduleDir string, files []string) ([]string, bool) {
+	changed := make(map[string]bool)
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if !isSubPath(moduleDir, dir) {
+			continue
+		}
+		if dir == moduleDir && moduleFiles[filepath.Base(file)] {
+			return nil, true
+		}
+
+		for ; isSubPath(moduleDir, dir); dir = filepath.Dir(dir) {
+			if importPath, ok := g.packages[dir]; ok {
+				changed[importPath] = true
+				break
+			}
+			// the files of the nested module don't belong to this module
+			if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && dir != moduleDir {
+				break
+			}
+		}
+	}
+	return sortedKeys(changed), false
+}
+
+// selectTests returns the import paths of the packages whose tests reach any of the changed packages,
+// which includes the changed packages themselves if they have tests.
+func (g *packageGraph) selectTests(changedPackages []string) []string {
+	var result []string
+	for pkg, deps := range g.tests {
+		for _, changed := range changedPackages {
+			if pkg == changed || deps[changed] {
+				result = append(result, pkg)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// allTests returns the import paths of all the packages that have tests.
+func (g *packageGraph) allTests() []string {
+	result := make([]string, 0, len(g.tests))
+	for pkg := range g.tests {
+		result = append(result, pkg)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// selectTestsByChanges selects the test packages of the module in moduleDir by the changes of the repository.
+func selectTestsByChanges(graph *packageGraph, repositoryPath, moduleDir string, changes []*gittool.Change) *report.TestSelection {
+	var files []string
+	for _, change := range changes {
+		files = append(files, filepath.Join(repositoryPath, change.FileName))
+		if change.Mode == gittool.RenameMode {
+			files = append(files, filepath.Join(repositoryPath, change.OldFileName))
+		}
+	}
+
+	selection := &report.TestSelection{TotalPackages: len(graph.tests)}
+	changedPackages, all := graph.changedPackages(moduleDir, files)
+	if all {
+		for _, pkg := range graph.packages {
+			changedPackages = append(changedPackages, pkg)
+		}
+		sort.Strings(changedPackages)
+		selection.ChangedPackages = changedPackages
+		selection.SelectedPackages = graph.allTests()
+		return selection
+	}
+	selection.ChangedPackages = changedPackages
+	selection.SelectedPackages = graph.selectTests(changedPackages)
+	return selection
+}
+
+// testPackages returns the packages that `go test` runs with for the selection, which are the selected packages and
+// the changed packages. A changed package is always run, so its statements are in the cover profile as uncovered
+// even if no selected test reaches them, as `go test` reports the packages without tests with -coverpkg.
+func testPackages(selection *report.TestSelection) []string {
+	packages := make(map[string]bool)
+	for _, pkg := range selection.SelectedPackages {
+		packages[pkg] = true
+	}
+	for _, pkg := range selection.ChangedPackages {
+		packages[pkg] = true
+	}
+	return sortedKeys(packages)
+}
+
+// isSubPath returns whether path is dir or under dir.
+func isSubPath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func sortedKeys(m map[string]bool) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
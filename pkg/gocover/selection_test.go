@@ -0,0 +1,154 @@
+package gocover
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/gocover/pkg/gittool"
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePackageGraph(t *testing.T) {
+	output := `{"Dir": "/repo/foo", "ImportPath": "example.com/foo", "Deps": ["fmt"]}
+{"Dir": "/repo/bar", "ImportPath": "example.com/bar", "Deps": ["example.com/foo"]}
+{"Dir": "/repo/bar", "ImportPath": "example.com/bar.test", "Deps": ["example.com/bar [example.com/bar.test]", "example.com/bar_test [example.com/bar.test]", "example.com/foo", "testing"]}
+{"Dir": "/repo/bar", "ImportPath": "example.com/bar [example.com/bar.test]", "ForTest": "example.com/bar", "Deps": ["example.com/foo"]}
+{"Dir": "/repo/bar", "ImportPath": "example.com/bar_test [example.com/bar.test]", "ForTest": "example.com/bar", "Deps": ["example.com/bar [example.com/bar.test]"]}
+`
+	graph, err := decodePackageGraph(strings.NewReader(output))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/repo/foo": "example.com/foo", "/repo/bar": "example.com/bar"}, graph.packages)
+	if assert.Contains(t, graph.tests, "example.com/bar") {
+		assert.True(t, graph.tests["example.com/bar"]["example.com/foo"])
+		assert.True(t, graph.tests["example.com/bar"]["example.com/bar_test"])
+	}
+	assert.NotContains(t, graph.tests, "example.com/foo", "package without tests has no test binary")
+}
+
+func TestSelectTestsByChanges(t *testing.T) {
+	// go list reports the directories with symbolic links resolved
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeWorkspaceFile(t, dir, "go.mod", "module example.com/root\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "a/a.go", "package a\n\nfunc A() int { return 1 }\n")
+	writeWorkspaceFile(t, dir, "a/testdata/input.txt", "input")
+	writeWorkspaceFile(t, dir, "b/b.go", "package b\n\nimport \"example.com/root/a\"\n\nfunc B() int { return a.A() }\n")
+	writeWorkspaceFile(t, dir, "b/b_test.go", "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) { B() }\n")
+	writeWorkspaceFile(t, dir, "c/c.go", "package c\n\nfunc C() int { return 1 }\n")
+	writeWorkspaceFile(t, dir, "c/c_test.go", "package c_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/root/a\"\n)\n\nfunc TestC(t *testing.T) { a.A() }\n")
+	writeWorkspaceFile(t, dir, "d/d.go", "package d\n\nfunc D() int { return 1 }\n")
+	writeWorkspaceFile(t, dir, "d/d_test.go", "package d\n\nimport \"testing\"\n\nfunc TestD(t *testing.T) { D() }\n")
+	writeWorkspaceFile(t, dir, "nested/go.mod", "module example.com/root/nested\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "nested/n.go", "package nested\n")
+
+	graph, err := loadPackageGraph(context.Background(), goCmd(), dir, nil)
+	if err != nil {
+		t.Fatalf("load package graph: %s", err)
+	}
+
+	t.Run("reverse dependencies", func(t *testing.T) {
+		selection := selectTestsByChanges(graph, dir, dir, []*gittool.Change{
+			{FileName: "a/a.go", Mode: gittool.ModifyMode},
+			{FileName: "README.md", Mode: gittool.ModifyMode},
+		})
+		assert.Equal(t, []string{"example.com/root/a"}, selection.ChangedPackages)
+		assert.Equal(t, []string{"example.com/root/b", "example.com/root/c"}, selection.SelectedPackages)
+		assert.Equal(t, 3, selection.TotalPackages)
+	})
+
+	t.Run("testdata belongs to the parent package", func(t *testing.T) {
+		selection := selectTestsByChanges(graph, dir, dir, []*gittool.Change{
+			{FileName: "d/testdata/input.txt", Mode: gittool.NewMode},
+		})
+		assert.Equal(t, []string{"example.com/root/d"}, selection.ChangedPackages)
+		assert.Equal(t, []string{"example.com/root/d"}, selection.SelectedPackages)
+	})
+
+	t.Run("renamed file changes the old package", func(t *testing.T) {
+		selection := selectTestsByChanges(graph, dir, dir, []*gittool.Change{
+			{FileName: "d/moved.go", OldFileName: "a/moved.go", Mode: gittool.RenameMode},
+		})
+		assert.Equal(t, []string{"example.com/root/a", "example.com/root/d"}, selection.ChangedPackages)
+		assert.Equal(t, []string{"example.com/root/b", "example.com/root/c", "example.com/root/d"}, selection.SelectedPackages)
+	})
+
+	t.Run("nested module is not in the module", func(t *testing.T) {
+		selection := selectTestsByChanges(graph, dir, dir, []*gittool.Change{
+			{FileName: "nested/n.go", Mode: gittool.ModifyMode},
+		})
+		assert.Empty(t, selection.ChangedPackages)
+		assert.Empty(t, selection.SelectedPackages)
+	})
+
+	t.Run("module file selects all tests", func(t *testing.T) {
+		selection := selectTestsByChanges(graph, dir, dir, []*gittool.Change{
+			{FileName: "go.mod", Mode: gittool.ModifyMode},
+		})
+		assert.Len(t, selection.ChangedPackages, 4)
+		assert.Equal(t, []string{"example.com/root/b", "example.com/root/c", "example.com/root/d"}, selection.SelectedPackages)
+	})
+
+	t.Run("module in sub directory", func(t *testing.T) {
+		selection := selectTestsByChanges(graph, filepath.Dir(dir), dir, []*gittool.Change{
+			{FileName: filepath.Join(filepath.Base(dir), "c", "c.go"), Mode: gittool.ModifyMode},
+		})
+		assert.Equal(t, []string{"example.com/root/c"}, selection.SelectedPackages)
+	})
+}
+
+func TestTestPackages(t *testing.T) {
+	assert.Equal(t, []string{"example.com/root/a", "example.com/root/b"}, testPackages(&report.TestSelection{
+		ChangedPackages:  []string{"example.com/root/a", "example.com/root/b"},
+		SelectedPackages: []string{"example.com/root/b"},
+	}))
+	assert.Empty(t, testPackages(&report.TestSelection{}))
+}
+
+func TestGoBuiltInTestExecutor_SelectTests_ChangedPackageNotReached(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeWorkspaceFile(t, dir, "go.mod", "module example.com/root\n\ngo 1.20\n")
+	writeWorkspaceFile(t, dir, "a/a.go", "package a\n\nfunc A() int { return 1 }\n")
+	writeWorkspaceFile(t, dir, "b/b.go", "package b\n\nfunc B() int { return 2 }\n")
+	writeWorkspaceFile(t, dir, "b/b_test.go", "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) { B() }\n")
+	writeWorkspaceFile(t, dir, "change.diff", "diff --git a/a/a.go b/a/a.go\n--- a/a/a.go\n+++ b/a/a.go\n"+
+		"@@ -1,3 +1,3 @@\n package a\n \n-func A() int { return 0 }\n+func A() int { return 1 }\n")
+
+	var buf bytes.Buffer
+	executor := &goBuiltInTestExecutor{
+		repositoryPath: dir,
+		mode:           DiffCoverage,
+		executable:     goCmd(),
+		outputDir:      t.TempDir(),
+		option: &GoCoverTestOption{
+			CoverageMode: DiffCoverage,
+			SelectTests:  true,
+			DiffFile:     filepath.Join(dir, "change.diff"),
+		},
+		stdout: &buf,
+		stderr: &buf,
+		logger: logrus.New(),
+	}
+
+	profiles, err := executor.coverProfiles(context.Background())
+	if !assert.NoError(t, err, buf.String()) {
+		return
+	}
+	assert.Equal(t, []string{"example.com/root/a"}, executor.selection.ChangedPackages)
+	assert.Empty(t, executor.selection.SelectedPackages)
+
+	// the changed package is in the cover profile as uncovered
+	content, err := os.ReadFile(profiles[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "example.com/root/a/a.go:3.16,3.26 1 0")
+}
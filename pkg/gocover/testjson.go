@@ -0,0 +1,229 @@
+package gocover
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/sirupsen/logrus"
+)
+
+// testEvent is the event written by `go test -json`, see `go doc test2json`.
+type testEvent struct {
+	Time    time.Time
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+
+	// ImportPath is the package being built of the build-output and build-fail events.
+	ImportPath string
+	// FailedBuild is the import path of the package that fails to build, which causes the package to fail.
+	FailedBuild string
+}
+
+// testEventWriter decodes the event stream of `go test -json` written into it, writes the test output
+// to the underlying writer, so the output is the same as `go test -v`, and records the results of the tests.
+// The lines that are not json events, such as the output of a build failure, are written as they are.
+type testEventWriter struct {
+	w       io.Writer
+	pending []byte
+
+	// packages are the results of the packages, keyed by package import path
+	packages map[string]*report.PackageTestResult
+	// tests are the results of the running tests, keyed by package import path and test name
+	tests map[string]map[string]*report.TestResult
+	// buildOutputs are the outputs of the build-output events, keyed by the import path of the built package
+	buildOutputs map[string]string
+	results      *report.TestResults
+}
+
+func newTestEventWriter(w io.Writer) *testEventWriter {
+	return &testEventWriter{
+		w:            w,
+		packages:     make(map[string]*report.PackageTestResult),
+		tests:        make(map[string]map[string]*report.TestResult),
+		buildOutputs: make(map[string]string),
+		results:      &report.TestResults{},
+	}
+}
+
+func (t *testEventWriter) Write(p []byte) (int, error) {
+	t.pending = append(t.pending, p...)
+	for {
+		i := bytes.IndexByte(t.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := t.pending[:i+1]
+		t.pending = t.pending[i+1:]
+		if err := t.handleLine(line); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Close handles the last line that doesn't end with a newline.
+func (t *testEventWriter) Close() error {
+	if len(t.pending) == 0 {
+		return nil
+	}
+	line := t.pending
+	t.pending = nil
+	return t.handleLine(line)
+}
+
+func (t *testEventWriter) handleLine(line []byte) error {
+	var event testEvent
+	if err := json.Unmarshal(line, &event); err != nil || event.Action == "" {
+		return t.write(string(line))
+	}
+	t.record(&event)
+	return t.write(event.Output)
+}
+
+func (t *testEventWriter) write(s string) error {
+	if t.w == nil || s == "" {
+		return nil
+	}
+	_, err := io.WriteString(t.w, s)
+	return err
+}
+
+// record records the output and the result of the event.
+func (t *testEventWriter) record(event *testEvent) {
+	if event.Action == "build-output" {
+		t.buildOutputs[event.ImportPath] += event.Output
+		return
+	}
+	if event.Package == "" {
+		return
+	}
+	pkg, ok := t.packages[event.Package]
+	if !ok {
+		pkg = &report.PackageTestResult{Package: event.Package}
+		t.packages[event.Package] = pkg
+		t.tests[event.Package] = make(map[string]*report.TestResult)
+	}
+
+	if event.Test == "" {
+		switch event.Action {
+		case "output":
+			pkg.Output += event.Output
+		case "pass", "fail", "skip":
+			pkg.Result = report.TestResultAction(event.Action)
+			pkg.Elapsed = event.Elapsed
+			if pkg.Result != report.TestFail {
+				pkg.Output = ""
+			}
+			if event.FailedBuild != "" {
+				pkg.Output = t.buildOutputs[event.FailedBuild] + pkg.Output
+			}
+			t.results.Packages = append(t.results.Packages, pkg)
+		}
+		return
+	}
+
+	test, ok := t.tests[event.Package][event.Test]
+	if !ok {
+		test = &report.TestResult{Name: event.Test}
+		t.tests[event.Package][event.Test] = test
+	}
+	switch event.Action {
+	case "output":
+		test.Output += event.Output
+	case "pass", "fail", "skip":
+		test.Result = report.TestResultAction(event.Action)
+		test.Elapsed = event.Elapsed
+		// only keep the output of failed and skipped tests, which tells why
+		if test.Result == report.TestPass {
+			test.Output = ""
+		}
+		pkg.Tests = append(pkg.Tests, test)
+		delete(t.tests[event.Package], event.Test)
+	}
+}
+
+// running returns the packages that have started but not finished, along with their running tests,
+// such as "example.com/foo (TestFoo, TestFoo/bar)".
+func (t *testEventWriter) running() []string {
+	var result []string
+	for name, pkg := range t.packages {
+		if pkg.Result != "" {
+			continue
+		}
+		tests := make([]string, 0, len(t.tests[name]))
+		for test := range t.tests[name] {
+			tests = append(tests, test)
+		}
+		if len(tests) == 0 {
+			result = append(result, name)
+			continue
+		}
+		sort.Strings(tests)
+		result = append(result, fmt.Sprintf("%s (%s)", name, strings.Join(tests, ", ")))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// testsRun returns whether any test has run, it's false when the tests fail to build or set up.
+func testsRun(results *report.TestResults) bool {
+	if results == nil {
+		return false
+	}
+	passed, failed, skipped := results.Counts()
+	return passed+failed+skipped != 0
+}
+
+// failedTestsMessage returns a message about the failed tests, at most limit tests are listed.
+func failedTestsMessage(results *report.TestResults, limit int) string {
+	failedTests := results.FailedTests()
+	var names []string
+	for i, test := range failedTests {
+		if i == limit {
+			names = append(names, fmt.Sprintf("and %d more", len(failedTests)-limit))
+			break
+		}
+		if test.Name == "" {
+			names = append(names, test.Package)
+			continue
+		}
+		names = append(names, test.Package+"."+test.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// writeJUnitFile writes the test results into the JUnit xml file.
+func writeJUnitFile(filename string, results *report.TestResults) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return report.WriteJUnit(f, results)
+}
+
+// writeTestResults writes the test results into the JUnit xml file if the file is specified.
+func writeTestResults(junitFile string, results *report.TestResults, logger logrus.FieldLogger) error {
+	if junitFile == "" || results == nil {
+		return nil
+	}
+	if err := writeJUnitFile(junitFile, results); err != nil {
+		return fmt.Errorf("write junit file: %w", err)
+	}
+	logger.Infof("junit file: %s", junitFile)
+	return nil
+}
@@ -0,0 +1,88 @@
+package gocover
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestEventWriter(t *testing.T) {
+	events := `{"Action":"start","Package":"example.com/foo"}
+{"Action":"run","Package":"example.com/foo","Test":"TestFoo"}
+{"Action":"output","Package":"example.com/foo","Test":"TestFoo","Output":"=== RUN   TestFoo\n"}
+{"Action":"output","Package":"example.com/foo","Test":"TestFoo","Output":"--- PASS: TestFoo (0.00s)\n"}
+{"Action":"pass","Package":"example.com/foo","Test":"TestFoo","Elapsed":0.01}
+{"Action":"run","Package":"example.com/foo","Test":"TestBar"}
+{"Action":"output","Package":"example.com/foo","Test":"TestBar","Output":"=== RUN   TestBar\n"}
+{"Action":"output","Package":"example.com/foo","Test":"TestBar","Output":"    foo_test.go:10: fail\n"}
+{"Action":"output","Package":"example.com/foo","Test":"TestBar","Output":"--- FAIL: TestBar (0.02s)\n"}
+{"Action":"fail","Package":"example.com/foo","Test":"TestBar","Elapsed":0.02}
+{"Action":"output","Package":"example.com/foo","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/foo","Elapsed":0.5}
+# example.com/baz
+baz.go:3:1: syntax error
+{"ImportPath":"example.com/bar [example.com/bar.test]","Action":"build-output","Output":"bar.go:3:1: undefined: x\n"}
+{"ImportPath":"example.com/bar [example.com/bar.test]","Action":"build-fail"}
+{"Action":"output","Package":"example.com/bar","Output":"FAIL\texample.com/bar [build failed]\n"}
+{"Action":"fail","Package":"example.com/bar","Elapsed":0,"FailedBuild":"example.com/bar [example.com/bar.test]"}
+{"Action":"output","Package":"example.com/zoo","Output":"?   \texample.com/zoo\t[no test files]\n"}
+{"Action":"skip","Package":"example.com/zoo","Elapsed":0}`
+
+	var stdout bytes.Buffer
+	w := newTestEventWriter(&stdout)
+	// write in small chunks, as the events are split by the pipe
+	for _, chunk := range splitEvery(events, 7) {
+		_, err := w.Write([]byte(chunk))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+
+	assert.Contains(t, stdout.String(), "=== RUN   TestFoo\n--- PASS: TestFoo (0.00s)\n")
+	assert.Contains(t, stdout.String(), "# example.com/baz\nbaz.go:3:1: syntax error\n")
+	assert.Contains(t, stdout.String(), "bar.go:3:1: undefined: x\n")
+	assert.NotContains(t, stdout.String(), `"Action"`)
+
+	results := w.results
+	if assert.Len(t, results.Packages, 3) {
+		foo := results.Packages[0]
+		assert.Equal(t, report.TestFail, foo.Result)
+		assert.Equal(t, 0.5, foo.Elapsed)
+		if assert.Len(t, foo.Tests, 2) {
+			assert.Equal(t, &report.TestResult{Name: "TestFoo", Result: report.TestPass, Elapsed: 0.01}, foo.Tests[0])
+			assert.Equal(t, "=== RUN   TestBar\n    foo_test.go:10: fail\n--- FAIL: TestBar (0.02s)\n", foo.Tests[1].Output)
+		}
+		assert.Equal(t, "bar.go:3:1: undefined: x\nFAIL\texample.com/bar [build failed]\n", results.Packages[1].Output)
+		assert.Equal(t, report.TestSkip, results.Packages[2].Result)
+		assert.Empty(t, results.Packages[2].Output)
+	}
+
+	assert.Equal(t, "example.com/foo.TestBar, example.com/bar", failedTestsMessage(results, 10))
+	assert.Equal(t, "example.com/foo.TestBar, and 1 more", failedTestsMessage(results, 1))
+}
+
+func TestTestEventWriterRunning(t *testing.T) {
+	events := `{"Action":"start","Package":"example.com/foo"}
+{"Action":"start","Package":"example.com/bar"}
+{"Action":"run","Package":"example.com/foo","Test":"TestFoo"}
+{"Action":"run","Package":"example.com/foo","Test":"TestFoo/sub"}
+{"Action":"run","Package":"example.com/bar","Test":"TestBar"}
+{"Action":"pass","Package":"example.com/bar","Test":"TestBar","Elapsed":0.01}
+{"Action":"start","Package":"example.com/baz"}
+{"Action":"pass","Package":"example.com/baz","Elapsed":0.1}
+`
+	w := newTestEventWriter(nil)
+	_, err := w.Write([]byte(events))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com/bar", "example.com/foo (TestFoo, TestFoo/sub)"}, w.running())
+}
+
+func splitEvery(s string, n int) []string {
+	var result []string
+	for len(s) > n {
+		result = append(result, s[:n])
+		s = s[n:]
+	}
+	return append(result, s)
+}
@@ -0,0 +1,97 @@
+package gocover
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/gocover/pkg/report"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+var ErrInvalidThresholdRule = errors.New("invalid threshold rule")
+
+// ThresholdRule requires the coverage (with ignorance) of the coverage tree nodes
+// whose path matches the pattern to be no less than the threshold.
+type ThresholdRule struct {
+	Pattern   string
+	Threshold float64
+}
+
+// ParseThresholdRules parses the rules in the format of `pattern=threshold`, for example,
+// `github.com/Azure/gocover/pkg/core/**=90`. The pattern is a doublestar pattern that
+// matches the package directories and the go files prefixed with module path.
+func ParseThresholdRules(rules []string) ([]*ThresholdRule, error) {
+	var result []*ThresholdRule
+	for _, rule := range rules {
+		i := strings.LastIndex(rule, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%w: %s, expect pattern=threshold", ErrInvalidThresholdRule, rule)
+		}
+
+		pattern := strings.TrimSpace(rule[:i])
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("%w: %s, bad pattern", ErrInvalidThresholdRule, rule)
+		}
+
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(rule[i+1:]), 64)
+		if err != nil || threshold < 0 || threshold > 100 {
+			return nil, fmt.Errorf("%w: %s, threshold should be a number between 0 and 100", ErrInvalidThresholdRule, rule)
+		}
+
+		result = append(result, &ThresholdRule{Pattern: pattern, Threshold: threshold})
+	}
+	return result, nil
+}
+
+// checkThresholds evaluates the rules against each node of the coverage tree, and returns the nodes
+// that violate their rules sorted by path. When several rules match a node, the last one wins,
+// so general rules should be put before specific ones. Nodes that no rule matches are not checked.
+func checkThresholds(all []*report.AllInformation, rules []*ThresholdRule) []*report.ThresholdViolation {
+	var violations []*report.ThresholdViolation
+	for _, info := range all {
+		var matched *ThresholdRule
+		for _, rule := range rules {
+			if ok, _ := doublestar.Match(rule.Pattern, info.Path); ok {
+				matched = rule
+			}
+		}
+		if matched == nil {
+			continue
+		}
+
+		coverage := calculateCoverage(info.TotalCoveredLines-info.TotalCoveredButIgnoreLines, info.TotalEffectiveLines)
+		if coverage < matched.Threshold {
+			violations = append(violations, &report.ThresholdViolation{
+				Path:      info.Path,
+				Pattern:   matched.Pattern,
+				Threshold: matched.Threshold,
+				Coverage:  coverage,
+			})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Path < violations[j].Path
+	})
+	return violations
+}
+
+// passThresholds returns LowCoverageErrorExitCode error when any node violates its threshold rule.
+func passThresholds(violations []*report.ThresholdViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	var details []string
+	for _, v := range violations {
+		details = append(details, fmt.Sprintf("%s is %.2f, expect %.2f", v.Path, v.Coverage, v.Threshold))
+	}
+	return WrapErrorWithCode(
+		fmt.Errorf("%d coverage threshold violations: %s", len(violations), strings.Join(details, "; ")),
+		LowCoverageErrorExitCode,
+		"",
+	)
+}
@@ -0,0 +1,104 @@
+package gocover
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Azure/gocover/pkg/report"
+)
+
+func TestParseThresholdRules(t *testing.T) {
+	t.Run("valid rules", func(t *testing.T) {
+		rules, err := ParseThresholdRules([]string{
+			"github.com/Azure/gocover/**=60",
+			" github.com/Azure/gocover/pkg/core/** = 90.5 ",
+		})
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if len(rules) != 2 {
+			t.Fatalf("should have 2 rules, but get %d", len(rules))
+		}
+		if rules[1].Pattern != "github.com/Azure/gocover/pkg/core/**" || rules[1].Threshold != 90.5 {
+			t.Errorf("unexpected rule: %+v", rules[1])
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, rule := range []string{
+			"github.com/Azure/gocover/**",
+			"=80",
+			"github.com/Azure/gocover/**=abc",
+			"github.com/Azure/gocover/**=101",
+			"github.com/Azure/gocover/[=80",
+		} {
+			_, err := ParseThresholdRules([]string{rule})
+			if !errors.Is(err, ErrInvalidThresholdRule) {
+				t.Errorf("rule %s should return ErrInvalidThresholdRule, but get: %v", rule, err)
+			}
+		}
+	})
+}
+
+func TestCheckThresholds(t *testing.T) {
+	all := []*report.AllInformation{
+		{Path: "github.com/Azure/gocover", TotalEffectiveLines: 100, TotalCoveredLines: 70},
+		{Path: "github.com/Azure/gocover/pkg/core", TotalEffectiveLines: 50, TotalCoveredLines: 44},
+		{Path: "github.com/Azure/gocover/pkg/core/core.go", TotalEffectiveLines: 50, TotalCoveredLines: 44},
+		{Path: "github.com/Azure/gocover/pkg/client", TotalEffectiveLines: 50, TotalCoveredLines: 27, TotalCoveredButIgnoreLines: 3},
+		{Path: "github.com/Azure/gocover/pkg/client/zz_generated.go", TotalEffectiveLines: 10, TotalCoveredLines: 0},
+	}
+
+	t.Run("no rules", func(t *testing.T) {
+		if violations := checkThresholds(all, nil); len(violations) != 0 {
+			t.Errorf("should have no violations, but get %d", len(violations))
+		}
+	})
+
+	t.Run("last matching rule wins", func(t *testing.T) {
+		rules, _ := ParseThresholdRules([]string{
+			"github.com/Azure/gocover/pkg/**=50",
+			"github.com/Azure/gocover/pkg/core/**=90",
+			"github.com/Azure/gocover/pkg/core=90",
+			"github.com/Azure/gocover/pkg/client/zz_*.go=0",
+		})
+
+		violations := checkThresholds(all, rules)
+		if len(violations) != 3 {
+			t.Fatalf("should have 3 violations, but get %d", len(violations))
+		}
+
+		expected := []*report.ThresholdViolation{
+			{Path: "github.com/Azure/gocover/pkg/client", Pattern: "github.com/Azure/gocover/pkg/**", Threshold: 50, Coverage: 48},
+			{Path: "github.com/Azure/gocover/pkg/core", Pattern: "github.com/Azure/gocover/pkg/core", Threshold: 90, Coverage: 88},
+			{Path: "github.com/Azure/gocover/pkg/core/core.go", Pattern: "github.com/Azure/gocover/pkg/core/**", Threshold: 90, Coverage: 88},
+		}
+		for i, v := range violations {
+			if *v != *expected[i] {
+				t.Errorf("expect violation %+v, but get %+v", expected[i], v)
+			}
+		}
+	})
+}
+
+func TestPassThresholds(t *testing.T) {
+	t.Run("no violations", func(t *testing.T) {
+		if err := passThresholds(nil); err != nil {
+			t.Errorf("should pass, but get: %s", err)
+		}
+	})
+
+	t.Run("violations", func(t *testing.T) {
+		err := passThresholds([]*report.ThresholdViolation{{Path: "github.com/Azure/gocover/pkg/core", Threshold: 90, Coverage: 88}})
+		var e *GoCoverError
+		if !errors.As(err, &e) {
+			t.Fatalf("should return GoCoverError, but get: %v", err)
+		}
+		if e.ExitCode != LowCoverageErrorExitCode {
+			t.Errorf("exit code should be %d, but get %d", LowCoverageErrorExitCode, e.ExitCode)
+		}
+		if e.Error() != "1 coverage threshold violations: github.com/Azure/gocover/pkg/core is 88.00, expect 90.00" {
+			t.Errorf("unexpected error message: %s", e.Error())
+		}
+	})
+}
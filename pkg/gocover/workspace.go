@@ -0,0 +1,206 @@
+package gocover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Azure/gocover/pkg/parser"
+	"github.com/Azure/gocover/pkg/report"
+	"golang.org/x/mod/modfile"
+)
+
+var (
+	// ErrNoGoModule indicates there is no go module found in the workspace.
+	ErrNoGoModule = errors.New("no go module found")
+	// ErrPackageNotInWorkspace indicates the package of the cover profile doesn't belong to any module of the workspace.
+	ErrPackageNotInWorkspace = errors.New("package not in any module of workspace")
+)
+
+// goModule is a go module of the workspace.
+type goModule struct {
+	// Path is the module path declared in go.mod, such as github.com/Azure/gocover.
+	Path string
+	// Dir is the absolute directory that contains go.mod.
+	Dir string
+}
+
+// workspace is a set of go modules, such as the modules used by go.work,
+// or the modules of a repository that contains several go.mod files.
+type workspace struct {
+	// modules are sorted by the length of module path in descending order,
+	// so the nested module is matched before its parent module.
+	modules []*goModule
+}
+
+// loadWorkspace loads the workspace of the directory.
+// It returns nil if the directory is a single module, that is, it has go.mod but doesn't have go.work.
+// all takes the directory as a workspace even though it's a single module, so the nested modules are included.
+func loadWorkspace(dir string, all bool) (*workspace, error) {
+	_, workErr := os.Stat(filepath.Join(dir, "go.work"))
+	_, modErr := os.Stat(filepath.Join(dir, "go.mod"))
+	if !all && workErr != nil && modErr == nil {
+		return nil, nil
+	}
+
+	modules, err := discoverModules(dir)
+	if err != nil {
+		return nil, err
+	}
+	return newWorkspace(modules), nil
+}
+
+func newWorkspace(modules []*goModule) *workspace {
+	sorted := make([]*goModule, len(modules))
+	copy(sorted, modules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Path) > len(sorted[j].Path)
+	})
+	return &workspace{modules: sorted}
+}
+
+// moduleCoverages returns the coverage of each module in the workspace sorted by module path,
+// with the test failures of the modules.
+func moduleCoverages(ws *workspace, all []*report.AllInformation, moduleErrors map[string]string) []*report.ModuleCoverage {
+	if ws == nil {
+		return nil
+	}
+
+	infos := make(map[string]*report.AllInformation)
+	for _, info := range all {
+		infos[info.Path] = info
+	}
+
+	var result []*report.ModuleCoverage
+	for _, m := range ws.modules {
+		coverage := &report.ModuleCoverage{Path: m.Path, Error: moduleErrors[m.Path]}
+		if info, ok := infos[m.Path]; ok {
+			coverage.TotalEffectiveLines = info.TotalEffectiveLines
+			coverage.TotalCoveredLines = info.TotalCoveredLines - info.TotalCoveredButIgnoreLines
+		}
+		coverage.CoveragePercent = calculateCoverage(coverage.TotalCoveredLines, coverage.TotalEffectiveLines)
+		result = append(result, coverage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// discoverModules finds the modules in the directory.
+// The modules are the ones used by go.work if it exists, otherwise they are found by walking for go.mod files,
+// the vendor and testdata directories, and the directories start with "." or "_" are skipped like go command does.
+func discoverModules(dir string) ([]*goModule, error) {
+	dirs, err := moduleDirs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoGoModule, dir)
+	}
+
+	var modules []*goModule
+	for _, d := range dirs {
+		modulePath, err := parseGoModulePath(d)
+		if err != nil {
+			return nil, fmt.Errorf("parse go module path: %w", err)
+		}
+		modules = append(modules, &goModule{Path: modulePath, Dir: d})
+	}
+	return modules, nil
+}
+
+func moduleDirs(dir string) ([]string, error) {
+	goWorkFilename := filepath.Join(dir, "go.work")
+	bs, err := os.ReadFile(goWorkFilename)
+	if err == nil {
+		workFile, err := modfile.ParseWork(goWorkFilename, bs, nil)
+		if err != nil {
+			return nil, fmt.Errorf("parse go.work: %w", err)
+		}
+
+		var dirs []string
+		for _, use := range workFile.Use {
+			d := filepath.FromSlash(use.Path)
+			if !filepath.IsAbs(d) {
+				d = filepath.Join(dir, d)
+			}
+			dirs = append(dirs, d)
+		}
+		return dirs, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var dirs []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "go.mod" {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// importPackage finds the package of the import path in the modules of the workspace.
+func (w *workspace) importPackage(importPath string) (*parser.ResolvedPackage, error) {
+	for _, m := range w.modules {
+		if importPath != m.Path && !strings.HasPrefix(importPath, m.Path+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(importPath, m.Path), "/")
+		return &parser.ResolvedPackage{
+			ImportPath: importPath,
+			Dir:        filepath.Join(m.Dir, filepath.FromSlash(rel)),
+			ModulePath: m.Path,
+			ModuleDir:  m.Dir,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPackageNotInWorkspace, importPath)
+}
+
+// importer adapts the workspace to the importer of parser.
+func (w *workspace) importer(importPaths []string) (map[string]*parser.ResolvedPackage, error) {
+	result := make(map[string]*parser.ResolvedPackage)
+	for _, importPath := range importPaths {
+		pkg, err := w.importPackage(importPath)
+		if err != nil {
+			return nil, err
+		}
+		result[importPath] = pkg
+	}
+	return result, nil
+}
+
+// parserOptions returns the options of the parser, the packages are resolved by packageImporter.
+func parserOptions(ctx context.Context, ws *workspace, moduleDir string, buildFlags []string, policy parser.StaleProfilePolicy) []parser.Option {
+	return []parser.Option{
+		parser.WithStaleProfilePolicy(policy),
+		parser.WithImporter(packageImporter(ctx, ws, moduleDir, buildFlags)),
+	}
+}
+
+// packageImporter returns the importer that finds the packages in the modules of the workspace,
+// or resolves them by `go list` in the module directory with the build flags.
+func packageImporter(ctx context.Context, ws *workspace, moduleDir string, buildFlags []string) parser.Importer {
+	if ws != nil {
+		return ws.importer
+	}
+	return parser.GoListImporter(ctx, goCmd(), moduleDir, buildFlags)
+}
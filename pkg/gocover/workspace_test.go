@@ -0,0 +1,201 @@
+package gocover
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/gocover/pkg/dbclient"
+)
+
+// writeWorkspaceFile writes the file relative to dir, and creates its parent directories.
+func writeWorkspaceFile(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	filename := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func modulePaths(modules []*goModule) string {
+	var paths []string
+	for _, m := range modules {
+		paths = append(paths, m.Path)
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestDiscoverModules(t *testing.T) {
+	t.Run("go.work", func(t *testing.T) {
+		dir := t.TempDir()
+		writeWorkspaceFile(t, dir, "go.work", "go 1.20\n\nuse (\n\t./foo\n\t./bar\n)\n")
+		writeWorkspaceFile(t, dir, "foo/go.mod", "module example.com/foo\n")
+		writeWorkspaceFile(t, dir, "bar/go.mod", "module example.com/bar\n")
+		// not used by go.work
+		writeWorkspaceFile(t, dir, "baz/go.mod", "module example.com/baz\n")
+
+		modules, err := discoverModules(dir)
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if modulePaths(modules) != "example.com/foo,example.com/bar" {
+			t.Errorf("unexpected modules: %s", modulePaths(modules))
+		}
+		if modules[0].Dir != filepath.Join(dir, "foo") {
+			t.Errorf("unexpected module dir: %s", modules[0].Dir)
+		}
+	})
+
+	t.Run("walk for go.mod", func(t *testing.T) {
+		dir := t.TempDir()
+		writeWorkspaceFile(t, dir, "go.mod", "module example.com/root\n")
+		writeWorkspaceFile(t, dir, "nested/go.mod", "module example.com/root/nested\n")
+		writeWorkspaceFile(t, dir, "vendor/example.com/dep/go.mod", "module example.com/dep\n")
+		writeWorkspaceFile(t, dir, "testdata/go.mod", "module example.com/testdata\n")
+		writeWorkspaceFile(t, dir, ".hidden/go.mod", "module example.com/hidden\n")
+
+		modules, err := discoverModules(dir)
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if modulePaths(modules) != "example.com/root,example.com/root/nested" {
+			t.Errorf("unexpected modules: %s", modulePaths(modules))
+		}
+	})
+
+	t.Run("no module", func(t *testing.T) {
+		_, err := discoverModules(t.TempDir())
+		if !errors.Is(err, ErrNoGoModule) {
+			t.Errorf("should return ErrNoGoModule, but get: %v", err)
+		}
+	})
+}
+
+func TestLoadWorkspace(t *testing.T) {
+	t.Run("single module", func(t *testing.T) {
+		dir := t.TempDir()
+		writeWorkspaceFile(t, dir, "go.mod", "module example.com/foo\n")
+		writeWorkspaceFile(t, dir, "bar/go.mod", "module example.com/foo/bar\n")
+
+		ws, err := loadWorkspace(dir, false)
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if ws != nil {
+			t.Errorf("single module should not be a workspace")
+		}
+	})
+
+	t.Run("repository without root module", func(t *testing.T) {
+		dir := t.TempDir()
+		writeWorkspaceFile(t, dir, "foo/go.mod", "module example.com/foo\n")
+
+		ws, err := loadWorkspace(dir, false)
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		if ws == nil || modulePaths(ws.modules) != "example.com/foo" {
+			t.Errorf("unexpected workspace: %v", ws)
+		}
+	})
+}
+
+func TestWorkspaceImportPackage(t *testing.T) {
+	ws := newWorkspace([]*goModule{
+		{Path: "example.com/foo", Dir: "/repo"},
+		{Path: "example.com/foo/bar", Dir: "/repo/bar"},
+	})
+
+	testCases := []struct {
+		importPath string
+		modulePath string
+		dir        string
+	}{
+		{importPath: "example.com/foo", modulePath: "example.com/foo", dir: "/repo"},
+		{importPath: "example.com/foo/pkg/util", modulePath: "example.com/foo", dir: "/repo/pkg/util"},
+		{importPath: "example.com/foo/bar/pkg", modulePath: "example.com/foo/bar", dir: "/repo/bar/pkg"},
+		{importPath: "example.com/foo/barbaz", modulePath: "example.com/foo", dir: "/repo/barbaz"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.importPath, func(t *testing.T) {
+			pkg, err := ws.importPackage(testCase.importPath)
+			if err != nil {
+				t.Fatalf("should not error, but get: %s", err)
+			}
+			if pkg.ModulePath != testCase.modulePath {
+				t.Errorf("expect module %s, but get %s", testCase.modulePath, pkg.ModulePath)
+			}
+			if pkg.Dir != filepath.FromSlash(testCase.dir) {
+				t.Errorf("expect dir %s, but get %s", testCase.dir, pkg.Dir)
+			}
+		})
+	}
+
+	t.Run("not in workspace", func(t *testing.T) {
+		_, err := ws.importPackage("example.com/baz")
+		if !errors.Is(err, ErrPackageNotInWorkspace) {
+			t.Errorf("should return ErrPackageNotInWorkspace, but get: %v", err)
+		}
+	})
+}
+
+func TestFullCoverWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	source := "package %s\n\nfunc Foo(a int) int {\n\tif a > 0 {\n\t\treturn a\n\t}\n\treturn 0\n}\n"
+	writeWorkspaceFile(t, dir, "go.work", "go 1.20\n\nuse (\n\t./foo\n\t./bar\n)\n")
+	writeWorkspaceFile(t, dir, "foo/go.mod", "module example.com/foo\n")
+	writeWorkspaceFile(t, dir, "foo/util/util.go", strings.ReplaceAll(source, "%s", "util"))
+	writeWorkspaceFile(t, dir, "bar/go.mod", "module example.com/bar\n")
+	writeWorkspaceFile(t, dir, "bar/bar.go", strings.ReplaceAll(source, "%s", "bar"))
+	writeWorkspaceFile(t, dir, "cover.out", strings.Join([]string{
+		"mode: set",
+		"example.com/foo/util/util.go:3.22,4.11 1 1",
+		"example.com/foo/util/util.go:4.11,6.3 1 1",
+		"example.com/foo/util/util.go:7.2,7.10 1 0",
+		"example.com/bar/bar.go:3.22,4.11 1 1",
+		"example.com/bar/bar.go:4.11,6.3 1 0",
+		"example.com/bar/bar.go:7.2,7.10 1 0",
+		"",
+	}, "\n"))
+
+	o := NewFullOption()
+	o.RepositoryPath = dir
+	o.CoverProfiles = []string{filepath.Join(dir, "cover.out")}
+	o.OutputDir = t.TempDir()
+	o.DbOption = &dbclient.DBOption{}
+	gc, err := NewFullCover(o)
+	if err != nil {
+		t.Fatalf("should not error, but get: %s", err)
+	}
+	full := gc.(*fullCover)
+
+	statistics, err := full.generateStatistics(context.Background())
+	if err != nil {
+		t.Fatalf("should not error, but get: %s", err)
+	}
+
+	var files []string
+	for _, p := range statistics.CoverageProfile {
+		files = append(files, p.FileName)
+	}
+	if strings.Join(files, ",") != "example.com/bar/bar.go,example.com/foo/util/util.go" &&
+		strings.Join(files, ",") != "example.com/foo/util/util.go,example.com/bar/bar.go" {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	modules := make(map[string]int64)
+	for _, info := range full.coverageTree.All() {
+		if info.Path == "example.com/foo" || info.Path == "example.com/bar" {
+			modules[info.Path] = info.TotalCoveredLines
+		}
+	}
+	if modules["example.com/foo"] != 2 || modules["example.com/bar"] != 1 {
+		t.Errorf("unexpected covered lines of modules: %v", modules)
+	}
+}
@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// goListWaitDelay is how long to wait for the output of the killed `go list`, the pipes may be held by its children.
+const goListWaitDelay = 10 * time.Second
+
+// ErrPackageNotFound indicates the package of the cover profile can't be resolved to the source directory.
+var ErrPackageNotFound = errors.New("package not found")
+
+// ResolvedPackage is a package of the cover profiles resolved to its source directory and module.
+type ResolvedPackage struct {
+	// ImportPath is the import path of the package.
+	ImportPath string
+	// Dir is the directory that contains the source files of the package.
+	Dir string
+	// ModulePath is the path of the module that the package belongs to.
+	ModulePath string
+	// ModuleDir is the root directory of the module that the package belongs to.
+	ModuleDir string
+}
+
+// Importer resolves the import paths of the cover profiles to the packages.
+type Importer func(importPaths []string) (map[string]*ResolvedPackage, error)
+
+// WithImporter sets how to resolve the packages of the cover profiles, default is `go list` in the working directory.
+func WithImporter(importer Importer) Option {
+	return func(parser *Parser) {
+		parser.importer = importer
+	}
+}
+
+// goListPackage is the subset of the `go list -json` output used for resolving the package.
+type goListPackage struct {
+	ImportPath string
+	Dir        string
+	Module     *struct {
+		Path string
+		Dir  string
+	}
+	Error *struct {
+		Err string
+	}
+}
+
+// GoCmd returns the go executable of the toolchain that gocover is built with if it exists, otherwise the one in PATH.
+func GoCmd() string {
+	var exeSuffix string
+	if runtime.GOOS == "windows" {
+		exeSuffix = ".exe"
+	}
+	path := filepath.Join(runtime.GOROOT(), "bin", "go"+exeSuffix)
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return "go"
+}
+
+// GoListImporter returns an importer that resolves the packages with `go list` of the go executable in dir.
+// The build flags, such as -tags and -mod=vendor, are passed to `go list`, and GOFLAGS is honored by the go command,
+// so the packages of tagged or vendored builds and module replacements are resolved as `go test` does.
+// `go list` is killed when the context is done, as it may block on downloading the modules.
+func GoListImporter(ctx context.Context, goCmd string, dir string, buildFlags []string) Importer {
+	return func(importPaths []string) (map[string]*ResolvedPackage, error) {
+		args := []string{"list", "-e", "-find", "-json=ImportPath,Dir,Module,Error"}
+		args = append(args, buildFlags...)
+		args = append(args, "--")
+		args = append(args, importPaths...)
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, goCmd, args...)
+		cmd.Dir = dir
+		cmd.WaitDelay = goListWaitDelay
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("go list %s: %w: %s", strings.Join(buildFlags, " "), err, strings.TrimSpace(stderr.String()))
+		}
+		return decodeGoListPackages(&stdout)
+	}
+}
+
+// decodeGoListPackages decodes the stream of json objects written by `go list -json`.
+func decodeGoListPackages(r io.Reader) (map[string]*ResolvedPackage, error) {
+	result := make(map[string]*ResolvedPackage)
+	decoder := json.NewDecoder(r)
+	for {
+		var pkg goListPackage
+		if err := decoder.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decode go list output: %w", err)
+		}
+
+		if pkg.Error != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrPackageNotFound, pkg.ImportPath, pkg.Error.Err)
+		}
+		if pkg.Dir == "" {
+			return nil, fmt.Errorf("%w: %s", ErrPackageNotFound, pkg.ImportPath)
+		}
+
+		resolved := &ResolvedPackage{
+			ImportPath: pkg.ImportPath,
+			Dir:        pkg.Dir,
+			// a package without module, such as in GOPATH mode, is taken as the root of itself
+			ModulePath: pkg.ImportPath,
+			ModuleDir:  pkg.Dir,
+		}
+		if pkg.Module != nil {
+			resolved.ModulePath = pkg.Module.Path
+			resolved.ModuleDir = pkg.Module.Dir
+			if resolved.ModuleDir == "" {
+				// vendored module has no module directory, it's the package directory without the sub path in module
+				rel := strings.TrimPrefix(pkg.ImportPath, pkg.Module.Path)
+				resolved.ModuleDir = strings.TrimSuffix(pkg.Dir, filepath.FromSlash(rel))
+			}
+		}
+		result[pkg.ImportPath] = resolved
+	}
+	return result, nil
+}
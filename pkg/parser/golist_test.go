@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoListImporter(t *testing.T) {
+	t.Run("package of main module", func(t *testing.T) {
+		pkgs, err := GoListImporter(context.Background(), GoCmd(), "", nil)([]string{"github.com/Azure/gocover/pkg/parser"})
+		assert.NoError(t, err)
+
+		wd, err := os.Getwd()
+		assert.NoError(t, err)
+		pkg := pkgs["github.com/Azure/gocover/pkg/parser"]
+		if assert.NotNil(t, pkg) {
+			assert.Equal(t, wd, pkg.Dir)
+			assert.Equal(t, "github.com/Azure/gocover", pkg.ModulePath)
+			assert.Equal(t, filepath.Dir(filepath.Dir(wd)), pkg.ModuleDir)
+		}
+	})
+
+	t.Run("honor build flags", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile := func(name, contents string) {
+			filename := filepath.Join(dir, filepath.FromSlash(name))
+			assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+			assert.NoError(t, os.WriteFile(filename, []byte(contents), 0644))
+		}
+		writeFile("go.mod", "module example.com/foo\n\ngo 1.20\n")
+		writeFile("tagged/tagged.go", "//go:build integration\n\npackage tagged\n")
+
+		_, err := GoListImporter(context.Background(), GoCmd(), dir, []string{"-tags=bad tag"})([]string{"example.com/foo/tagged"})
+		assert.Error(t, err, "the build flags should be passed to go list")
+
+		pkgs, err := GoListImporter(context.Background(), GoCmd(), dir, []string{"-tags=integration"})([]string{"example.com/foo/tagged"})
+		assert.NoError(t, err)
+		if assert.Contains(t, pkgs, "example.com/foo/tagged") {
+			assert.Equal(t, filepath.Join(dir, "tagged"), pkgs["example.com/foo/tagged"].Dir)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := GoListImporter(ctx, GoCmd(), "", nil)([]string{"github.com/Azure/gocover/pkg/parser"})
+		assert.Error(t, err)
+	})
+}
+
+func TestDecodeGoListPackages(t *testing.T) {
+	t.Run("vendored module", func(t *testing.T) {
+		output := `{
+	"Dir": "/repo/vendor/example.com/dep/sub",
+	"ImportPath": "example.com/dep/sub",
+	"Module": {"Path": "example.com/dep", "Version": "v1.0.0"}
+}
+{
+	"Dir": "/repo/pkg",
+	"ImportPath": "example.com/foo/pkg",
+	"Module": {"Path": "example.com/foo", "Dir": "/repo", "Main": true}
+}
+`
+		pkgs, err := decodeGoListPackages(strings.NewReader(output))
+		assert.NoError(t, err)
+		assert.Equal(t, &ResolvedPackage{
+			ImportPath: "example.com/dep/sub",
+			Dir:        "/repo/vendor/example.com/dep/sub",
+			ModulePath: "example.com/dep",
+			ModuleDir:  "/repo/vendor/example.com/dep",
+		}, pkgs["example.com/dep/sub"])
+		assert.Equal(t, "/repo", pkgs["example.com/foo/pkg"].ModuleDir)
+	})
+
+	t.Run("package error", func(t *testing.T) {
+		output := `{"ImportPath": "example.com/bar", "Error": {"Err": "cannot find module providing package example.com/bar"}}`
+		_, err := decodeGoListPackages(strings.NewReader(output))
+		assert.True(t, errors.Is(err, ErrPackageNotFound))
+	})
+}
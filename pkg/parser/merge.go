@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/tools/cover"
+)
+
+const (
+	setMode    = "set"
+	countMode  = "count"
+	atomicMode = "atomic"
+)
+
+var (
+	// ErrCoverModeMismatch indicates the cover profiles are generated with incompatible cover modes.
+	ErrCoverModeMismatch = errors.New("cover mode mismatch")
+	// ErrConflictingBlocks indicates the blocks of a file overlap but have different boundaries,
+	// which means the cover profiles are generated from different versions of the source code.
+	ErrConflictingBlocks = errors.New("conflicting cover profile blocks")
+)
+
+// ParseCoverProfiles parses the cover profile files and merges them into one profile for each file.
+func ParseCoverProfiles(coverProfileFiles []string) ([]*cover.Profile, error) {
+	var profiles []*cover.Profile
+	for _, file := range coverProfileFiles {
+		p, err := cover.ParseProfiles(file)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", file, err)
+		}
+		profiles = append(profiles, p...)
+	}
+	return MergeProfiles(profiles)
+}
+
+// MergeProfiles merges the profiles of the same files, such as the profiles of the overlapping `-coverpkg` runs.
+//
+// count and atomic modes are compatible and merged into atomic mode, set mode can't be merged with the others.
+// Identical blocks are deduplicated, their counts are summed for count and atomic modes, and OR-ed for set mode.
+// Blocks that overlap with different boundaries are rejected.
+// The result is sorted by file name, and the blocks are sorted by position.
+func MergeProfiles(profiles []*cover.Profile) ([]*cover.Profile, error) {
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+
+	mode, err := mergeMode(profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*cover.Profile)
+	for _, p := range profiles {
+		merged, ok := files[p.FileName]
+		if !ok {
+			merged = &cover.Profile{FileName: p.FileName, Mode: mode}
+			files[p.FileName] = merged
+		}
+		merged.Blocks = append(merged.Blocks, p.Blocks...)
+	}
+
+	result := make([]*cover.Profile, 0, len(files))
+	for _, p := range files {
+		blocks, err := mergeBlocks(p.Blocks, mode)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.FileName, err)
+		}
+		p.Blocks = blocks
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FileName < result[j].FileName
+	})
+	return result, nil
+}
+
+func mergeMode(profiles []*cover.Profile) (string, error) {
+	mode := profiles[0].Mode
+	for _, p := range profiles[1:] {
+		switch {
+		case p.Mode == mode:
+		case (p.Mode == countMode || p.Mode == atomicMode) && (mode == countMode || mode == atomicMode):
+			mode = atomicMode
+		default:
+			return "", fmt.Errorf("%w: %s and %s", ErrCoverModeMismatch, mode, p.Mode)
+		}
+	}
+	return mode, nil
+}
+
+func mergeBlocks(blocks []cover.ProfileBlock, mode string) ([]cover.ProfileBlock, error) {
+	sort.SliceStable(blocks, func(i, j int) bool {
+		if blocks[i].StartLine != blocks[j].StartLine {
+			return blocks[i].StartLine < blocks[j].StartLine
+		}
+		return blocks[i].StartCol < blocks[j].StartCol
+	})
+
+	var result []cover.ProfileBlock
+	for _, b := range blocks {
+		if len(result) == 0 {
+			result = append(result, b)
+			continue
+		}
+
+		last := &result[len(result)-1]
+		if samePosition(*last, b) {
+			if last.NumStmt != b.NumStmt {
+				return nil, fmt.Errorf("%w: %s has %d and %d statements", ErrConflictingBlocks, blockString(b), last.NumStmt, b.NumStmt)
+			}
+			if mode == setMode {
+				if b.Count > 0 {
+					last.Count = 1
+				}
+			} else {
+				last.Count += b.Count
+			}
+			continue
+		}
+
+		if b.StartLine < last.EndLine || (b.StartLine == last.EndLine && b.StartCol < last.EndCol) {
+			return nil, fmt.Errorf("%w: %s overlaps with %s", ErrConflictingBlocks, blockString(b), blockString(*last))
+		}
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+func samePosition(a, b cover.ProfileBlock) bool {
+	return a.StartLine == b.StartLine && a.StartCol == b.StartCol && a.EndLine == b.EndLine && a.EndCol == b.EndCol
+}
+
+func blockString(b cover.ProfileBlock) string {
+	return fmt.Sprintf("%d.%d,%d.%d", b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+}
+
+// WriteProfiles writes the profiles in the standard cover profile format that `go tool cover` accepts.
+// All the profiles should have the same mode, such as the result of MergeProfiles.
+func WriteProfiles(w io.Writer, profiles []*cover.Profile) error {
+	mode := setMode
+	if len(profiles) != 0 {
+		mode = profiles[0].Mode
+	}
+	if _, err := fmt.Fprintf(w, "mode: %s\n", mode); err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			if _, err := fmt.Fprintf(w, "%s:%s %d %d\n", p.FileName, blockString(b), b.NumStmt, b.Count); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
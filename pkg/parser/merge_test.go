@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/cover"
+)
+
+func TestMergeProfiles(t *testing.T) {
+	block := func(startLine, endLine, numStmt, count int) cover.ProfileBlock {
+		return cover.ProfileBlock{StartLine: startLine, StartCol: 2, EndLine: endLine, EndCol: 10, NumStmt: numStmt, Count: count}
+	}
+
+	t.Run("sum counts for count and atomic modes", func(t *testing.T) {
+		merged, err := MergeProfiles([]*cover.Profile{
+			{FileName: "foo.go", Mode: "count", Blocks: []cover.ProfileBlock{block(3, 4, 1, 1), block(6, 7, 2, 0)}},
+			{FileName: "bar.go", Mode: "count", Blocks: []cover.ProfileBlock{block(3, 4, 1, 1)}},
+			{FileName: "foo.go", Mode: "atomic", Blocks: []cover.ProfileBlock{block(6, 7, 2, 3), block(3, 4, 1, 2), block(9, 9, 1, 0)}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []*cover.Profile{
+			{FileName: "bar.go", Mode: "atomic", Blocks: []cover.ProfileBlock{block(3, 4, 1, 1)}},
+			{FileName: "foo.go", Mode: "atomic", Blocks: []cover.ProfileBlock{block(3, 4, 1, 3), block(6, 7, 2, 3), block(9, 9, 1, 0)}},
+		}, merged)
+	})
+
+	t.Run("or counts for set mode", func(t *testing.T) {
+		merged, err := MergeProfiles([]*cover.Profile{
+			{FileName: "foo.go", Mode: "set", Blocks: []cover.ProfileBlock{block(3, 4, 1, 1), block(6, 7, 1, 0)}},
+			{FileName: "foo.go", Mode: "set", Blocks: []cover.ProfileBlock{block(3, 4, 1, 1), block(6, 7, 1, 0)}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []cover.ProfileBlock{block(3, 4, 1, 1), block(6, 7, 1, 0)}, merged[0].Blocks)
+	})
+
+	t.Run("set mode can't be merged with count mode", func(t *testing.T) {
+		_, err := MergeProfiles([]*cover.Profile{
+			{FileName: "foo.go", Mode: "set"},
+			{FileName: "foo.go", Mode: "count"},
+		})
+		assert.ErrorIs(t, err, ErrCoverModeMismatch)
+	})
+
+	t.Run("reject conflicting blocks", func(t *testing.T) {
+		_, err := MergeProfiles([]*cover.Profile{
+			{FileName: "foo.go", Mode: "set", Blocks: []cover.ProfileBlock{block(3, 6, 2, 1)}},
+			{FileName: "foo.go", Mode: "set", Blocks: []cover.ProfileBlock{block(5, 8, 2, 1)}},
+		})
+		assert.ErrorIs(t, err, ErrConflictingBlocks)
+
+		_, err = MergeProfiles([]*cover.Profile{
+			{FileName: "foo.go", Mode: "set", Blocks: []cover.ProfileBlock{block(3, 6, 2, 1)}},
+			{FileName: "foo.go", Mode: "set", Blocks: []cover.ProfileBlock{block(3, 6, 3, 1)}},
+		})
+		assert.ErrorIs(t, err, ErrConflictingBlocks)
+	})
+
+	t.Run("no profiles", func(t *testing.T) {
+		merged, err := MergeProfiles(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, merged)
+	})
+}
+
+func TestParseCoverProfiles(t *testing.T) {
+	dir := t.TempDir()
+	unit := filepath.Join(dir, "unit.out")
+	e2e := filepath.Join(dir, "e2e.out")
+	assert.NoError(t, os.WriteFile(unit, []byte("mode: count\nfoo.go:3.2,4.10 1 1\nfoo.go:6.2,7.10 2 0\n"), 0644))
+	assert.NoError(t, os.WriteFile(e2e, []byte("mode: count\nfoo.go:3.2,4.10 1 2\nfoo.go:6.2,7.10 2 1\nbar.go:1.1,2.2 1 0\n"), 0644))
+
+	profiles, err := ParseCoverProfiles([]string{unit, e2e})
+	assert.NoError(t, err)
+
+	var b bytes.Buffer
+	assert.NoError(t, WriteProfiles(&b, profiles))
+	assert.Equal(t, "mode: count\nbar.go:1.1,2.2 1 0\nfoo.go:3.2,4.10 1 3\nfoo.go:6.2,7.10 2 1\n", b.String())
+
+	// the written profile can be parsed again
+	merged := filepath.Join(dir, "merged.out")
+	assert.NoError(t, os.WriteFile(merged, b.Bytes(), 0644))
+	reparsed, err := cover.ParseProfiles(merged)
+	assert.NoError(t, err)
+	assert.Equal(t, profiles, reparsed)
+
+	_, err = ParseCoverProfiles([]string{filepath.Join(dir, "nonexist.out")})
+	assert.Error(t, err)
+}
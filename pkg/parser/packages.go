@@ -14,6 +14,13 @@ type Package struct {
 	// Name is the canonical path of the package.
 	Name string
 
+	// Dir is the directory of the package source files.
+	Dir string
+	// ModulePath is the path of the module that the package belongs to.
+	ModulePath string
+	// ModuleDir is the root directory of the module that the package belongs to.
+	ModuleDir string
+
 	// Functions is a list of functions registered with this package.
 	Functions []*Function
 
@@ -1,9 +1,9 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
-	"go/build"
 	"go/parser"
 	"go/token"
 	"path/filepath"
@@ -16,19 +16,26 @@ import (
 	"golang.org/x/tools/cover"
 )
 
-type packagesCache map[string]*build.Package
+type packagesCache map[string]*ResolvedPackage
 
 func NewParser(
 	coverProfileFiles []string,
 	logger logrus.FieldLogger,
+	opts ...Option,
 ) *Parser {
-	return &Parser{
-		coverProfileFiles: coverProfileFiles,
-		coverProfiles:     make([]*cover.Profile, 0),
-		packages:          make(map[string]*Package),
-		packagesCache:     make(packagesCache),
-		logger:            logger.WithField("source", "Parser"),
+	parser := &Parser{
+		coverProfileFiles:  coverProfileFiles,
+		coverProfiles:      make([]*cover.Profile, 0),
+		packages:           make(map[string]*Package),
+		packagesCache:      make(packagesCache),
+		staleProfilePolicy: StaleProfileFail,
+		importer:           GoListImporter(context.Background(), GoCmd(), "", nil),
+		logger:             logger.WithField("source", "Parser"),
 	}
+	for _, opt := range opts {
+		opt(parser)
+	}
+	return parser
 }
 
 // Parser wrapper for parsing
@@ -38,6 +45,12 @@ type Parser struct {
 	coverProfileFiles []string
 	coverProfiles     []*cover.Profile
 
+	staleProfilePolicy StaleProfilePolicy
+	// staleFiles are the files whose cover profile doesn't match the source, with the reasons
+	staleFiles []string
+
+	importer Importer
+
 	logger logrus.FieldLogger
 }
 
@@ -61,6 +74,11 @@ func (parser *Parser) Parse(changes []*gittool.Change) (Packages, error) {
 		}
 	}
 
+	if len(parser.staleFiles) != 0 && parser.staleProfilePolicy != StaleProfileWarn {
+		return nil, fmt.Errorf("%w, regenerate the cover profile from the checked-out source: %s",
+			ErrStaleProfile, strings.Join(parser.staleFiles, ", "))
+	}
+
 	for _, pkg := range parser.packages {
 		result.AddPackage(pkg)
 	}
@@ -71,23 +89,22 @@ func (parser *Parser) Parse(changes []*gittool.Change) (Packages, error) {
 // filterCoverProfiles filters cover profiles based on git changes.
 // If changes is nil, all cover profiles will be kept.
 // If changes is not nil, only cover profiles that are changed will be kept.
+// The cover profile files are merged, so a file covered by several profiles is only parsed once.
 func (parser *Parser) filterCoverProfiles(changes []*gittool.Change) error {
 
-	for _, coverProfile := range parser.coverProfileFiles {
-		profiles, err := cover.ParseProfiles(coverProfile)
-		if err != nil {
-			return err
-		}
+	profiles, err := ParseCoverProfiles(parser.coverProfileFiles)
+	if err != nil {
+		return err
+	}
 
-		if changes == nil {
-			parser.coverProfiles = append(parser.coverProfiles, profiles...)
-			continue
-		}
+	if changes == nil {
+		parser.coverProfiles = append(parser.coverProfiles, profiles...)
+		return nil
+	}
 
-		for _, p := range profiles {
-			if findChange(p, changes) != nil {
-				parser.coverProfiles = append(parser.coverProfiles, p)
-			}
+	for _, p := range profiles {
+		if findChange(p, changes) != nil {
+			parser.coverProfiles = append(parser.coverProfiles, p)
 		}
 	}
 
@@ -96,20 +113,44 @@ func (parser *Parser) filterCoverProfiles(changes []*gittool.Change) error {
 
 // buildPackageCache builds a cache of packages for all cover profiles.
 func (parser *Parser) buildPackageCache() error {
+	importer := parser.importer
+	if importer == nil {
+		importer = GoListImporter(context.Background(), GoCmd(), "", nil)
+	}
 
+	// resolve all the packages with a single call of importer
+	var importPaths []string
+	seen := make(map[string]bool)
 	for _, profile := range parser.coverProfiles {
 		dir, _ := filepath.Split(profile.FileName)
 		if dir != "" {
 			dir = strings.TrimSuffix(dir, "/")
 		}
-		_, ok := parser.packagesCache[dir]
+		if _, ok := parser.packagesCache[dir]; ok || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		importPaths = append(importPaths, dir)
+	}
+	if len(importPaths) == 0 {
+		return nil
+	}
+
+	pkgs, err := importer(importPaths)
+	if err != nil {
+		return err
+	}
+	for _, importPath := range importPaths {
+		pkg, ok := pkgs[importPath]
 		if !ok {
-			pkg, err := build.Import(dir, ".", build.FindOnly)
-			if err != nil {
-				return err
-			}
-			parser.packagesCache[dir] = pkg
-			parser.packages[pkg.ImportPath] = &Package{Name: pkg.ImportPath}
+			return fmt.Errorf("%w: %s", ErrPackageNotFound, importPath)
+		}
+		parser.packagesCache[importPath] = pkg
+		parser.packages[pkg.ImportPath] = &Package{
+			Name:       pkg.ImportPath,
+			Dir:        pkg.Dir,
+			ModulePath: pkg.ModulePath,
+			ModuleDir:  pkg.ModuleDir,
 		}
 	}
 
@@ -160,6 +201,15 @@ func (parser *Parser) convertProfile(p *cover.Profile, change *gittool.Change) e
 		parser.logger.WithError(err).Error("find Functions")
 		return err
 	}
+	reason, err := checkStaleProfile(file, extents, p.Blocks)
+	if err != nil {
+		parser.logger.WithError(err).Error("check stale profile")
+		return err
+	}
+	if reason != "" {
+		parser.logger.Warnf("cover profile of %s doesn't match the source: %s", file, reason)
+		parser.staleFiles = append(parser.staleFiles, fmt.Sprintf("%s (%s)", file, reason))
+	}
 	var stmts []*statement
 	for _, fe := range extents {
 		f := &Function{
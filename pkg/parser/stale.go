@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/tools/cover"
+)
+
+// StaleProfilePolicy defines how to handle the stale cover profile, whose blocks don't match the source file,
+// for example, the cover profile is generated from another revision than the checked-out source.
+type StaleProfilePolicy string
+
+const (
+	// StaleProfileFail fails the parsing with the list of files whose cover profile is stale.
+	StaleProfileFail StaleProfilePolicy = "fail"
+	// StaleProfileWarn logs the files whose cover profile is stale and goes on.
+	StaleProfileWarn StaleProfilePolicy = "warn"
+)
+
+// ErrStaleProfile indicates the cover profile doesn't match the source file.
+var ErrStaleProfile = errors.New("cover profile doesn't match the source")
+
+// Option configures the Parser.
+type Option func(*Parser)
+
+// WithStaleProfilePolicy sets how to handle the stale cover profile, default is StaleProfileFail.
+func WithStaleProfilePolicy(policy StaleProfilePolicy) Option {
+	return func(parser *Parser) {
+		parser.staleProfilePolicy = policy
+	}
+}
+
+// checkStaleProfile validates the blocks of the cover profile against the source file and its function extents.
+// Each block should be in the range of the file, and inside a function, as only the function bodies are instrumented.
+// It returns the reason when the cover profile is stale, or empty if the cover profile matches the source.
+func checkStaleProfile(filename string, funcs []*FuncExtent, blocks []cover.ProfileBlock) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	lines := bytes.Split(data, []byte("\n"))
+
+	for _, b := range blocks {
+		if b.StartLine < 1 || b.EndLine > len(lines) || b.StartLine > b.EndLine {
+			return fmt.Sprintf("block %s is out of the file with %d lines", blockString(b), len(lines)), nil
+		}
+		// columns are 1-based byte offsets, and the end column is right after the last character
+		if b.StartCol < 1 || b.StartCol > len(lines[b.StartLine-1])+1 || b.EndCol > len(lines[b.EndLine-1])+1 {
+			return fmt.Sprintf("block %s is out of the line length", blockString(b)), nil
+		}
+		if !insideFunction(b, funcs) {
+			return fmt.Sprintf("block %s is not inside any function", blockString(b)), nil
+		}
+	}
+	return "", nil
+}
+
+func insideFunction(b cover.ProfileBlock, funcs []*FuncExtent) bool {
+	for _, fe := range funcs {
+		afterStart := b.StartLine > fe.startLine || (b.StartLine == fe.startLine && b.StartCol >= fe.startCol)
+		beforeEnd := b.EndLine < fe.endLine || (b.EndLine == fe.endLine && b.EndCol <= fe.endCol)
+		if afterStart && beforeEnd {
+			return true
+		}
+	}
+	return false
+}
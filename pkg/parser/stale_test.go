@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/cover"
+)
+
+func TestCheckStaleProfile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "foo.go")
+	source := "package foo\n\nvar a = 1\n\nfunc foo() {\n\tif a > 0 {\n\t\ta++\n\t}\n}\n"
+	assert.NoError(t, os.WriteFile(filename, []byte(source), 0644))
+
+	funcs, err := findFuncs(filename)
+	assert.NoError(t, err)
+
+	testSuites := []struct {
+		name   string
+		blocks []cover.ProfileBlock
+		stale  bool
+	}{
+		{
+			name: "match the source",
+			blocks: []cover.ProfileBlock{
+				{StartLine: 5, StartCol: 12, EndLine: 6, EndCol: 11, NumStmt: 1},
+				{StartLine: 6, StartCol: 11, EndLine: 8, EndCol: 3, NumStmt: 1},
+			},
+		},
+		{
+			name:   "out of the file",
+			blocks: []cover.ProfileBlock{{StartLine: 12, StartCol: 2, EndLine: 14, EndCol: 3, NumStmt: 1}},
+			stale:  true,
+		},
+		{
+			name:   "out of the line length",
+			blocks: []cover.ProfileBlock{{StartLine: 5, StartCol: 12, EndLine: 6, EndCol: 30, NumStmt: 1}},
+			stale:  true,
+		},
+		{
+			name:   "not inside any function",
+			blocks: []cover.ProfileBlock{{StartLine: 1, StartCol: 1, EndLine: 3, EndCol: 5, NumStmt: 1}},
+			stale:  true,
+		},
+	}
+	for _, testSuite := range testSuites {
+		t.Run(testSuite.name, func(t *testing.T) {
+			reason, err := checkStaleProfile(filename, funcs, testSuite.blocks)
+			assert.NoError(t, err)
+			assert.Equal(t, testSuite.stale, reason != "", reason)
+		})
+	}
+
+	t.Run("file not found", func(t *testing.T) {
+		_, err := checkStaleProfile(filepath.Join(t.TempDir(), "nonexist.go"), funcs, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestWithStaleProfilePolicy(t *testing.T) {
+	assert.Equal(t, StaleProfileFail, NewParser(nil, logrus.New()).staleProfilePolicy)
+	assert.Equal(t, StaleProfileWarn, NewParser(nil, logrus.New(), WithStaleProfilePolicy(StaleProfileWarn)).staleProfilePolicy)
+}
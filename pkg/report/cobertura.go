@@ -0,0 +1,229 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	coberturaDocType = `<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">`
+	coberturaVersion = "gocover"
+)
+
+// coberturaCoverage is the root element of the cobertura xml report,
+// its definition can be found at http://cobertura.sourceforge.net/xml/coverage-04.dtd.
+type coberturaCoverage struct {
+	XMLName         xml.Name            `xml:"coverage"`
+	LineRate        float64             `xml:"line-rate,attr"`
+	BranchRate      float64             `xml:"branch-rate,attr"`
+	LinesCovered    int                 `xml:"lines-covered,attr"`
+	LinesValid      int                 `xml:"lines-valid,attr"`
+	BranchesCovered int                 `xml:"branches-covered,attr"`
+	BranchesValid   int                 `xml:"branches-valid,attr"`
+	Complexity      float64             `xml:"complexity,attr"`
+	Version         string              `xml:"version,attr"`
+	Timestamp       int64               `xml:"timestamp,attr"`
+	Sources         []string            `xml:"sources>source"`
+	Packages        []*coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string            `xml:"name,attr"`
+	LineRate   float64           `xml:"line-rate,attr"`
+	BranchRate float64           `xml:"branch-rate,attr"`
+	Complexity float64           `xml:"complexity,attr"`
+	Classes    []*coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string             `xml:"name,attr"`
+	Filename   string             `xml:"filename,attr"`
+	LineRate   float64            `xml:"line-rate,attr"`
+	BranchRate float64            `xml:"branch-rate,attr"`
+	Complexity float64            `xml:"complexity,attr"`
+	Methods    []*coberturaMethod `xml:"methods>method"`
+	Lines      []*coberturaLine   `xml:"lines>line"`
+}
+
+type coberturaMethod struct {
+	Name       string           `xml:"name,attr"`
+	Signature  string           `xml:"signature,attr"`
+	LineRate   float64          `xml:"line-rate,attr"`
+	BranchRate float64          `xml:"branch-rate,attr"`
+	Complexity float64          `xml:"complexity,attr"`
+	Lines      []*coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int   `xml:"number,attr"`
+	Hits   int64 `xml:"hits,attr"`
+}
+
+// coberturaReportGenerator implements a cobertura xml report generator,
+// which can be consumed by CI systems such as Azure Pipelines and Jenkins.
+type coberturaReportGenerator struct {
+	// outputPath report path
+	outputPath string
+	// reportName report name
+	reportName string
+	// logger
+	logger logrus.FieldLogger
+}
+
+var _ ReportGenerator = (*coberturaReportGenerator)(nil)
+
+func newCoberturaReportGenerator(outputPath string, reportName string, logger logrus.FieldLogger) ReportGenerator {
+	return &coberturaReportGenerator{
+		outputPath: outputPath,
+		reportName: reportName,
+		logger:     logger,
+	}
+}
+
+// GenerateReport converts the coverage statistics to cobertura format and writes it to the xml report.
+func (g *coberturaReportGenerator) GenerateReport(statistics *Statistics) error {
+	reportFile := filepath.Join(g.outputPath, fmt.Sprintf("%s.xml", g.reportName))
+	f, err := os.Create(reportFile)
+	if err != nil {
+		return fmt.Errorf("create report file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s%s\n", xml.Header, coberturaDocType); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	encoder := xml.NewEncoder(f)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(buildCoberturaCoverage(statistics, time.Now())); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	g.logger.Infof("generate cobertura coverage report: %s", reportFile)
+	return nil
+}
+
+// buildCoberturaCoverage maps the packages to cobertura packages, files to classes,
+// functions to methods and statements to lines. Ignored statements don't count for coverage,
+// so they are not reported as lines.
+func buildCoberturaCoverage(statistics *Statistics, now time.Time) *coberturaCoverage {
+	coverage := &coberturaCoverage{
+		Version:   coberturaVersion,
+		Timestamp: now.UnixMilli(),
+		Sources:   []string{statistics.RepositoryPath},
+	}
+
+	packages := make(map[string]*coberturaPackage)
+	var packageNames []string
+	packageLines := make(map[string][2]int)
+
+	for _, profile := range statistics.CoverageProfile {
+		// the file out of the repository, such as a dependency in the module cache, keeps its absolute path
+		filename := profile.SourceFile
+		if rel, err := filepath.Rel(statistics.RepositoryPath, profile.SourceFile); err == nil && !strings.HasPrefix(rel, "..") {
+			filename = filepath.ToSlash(rel)
+		}
+
+		class := &coberturaClass{
+			Name:     path.Base(profile.FileName),
+			Filename: filename,
+		}
+
+		classLines := make(map[int]int64)
+		for _, fun := range profile.Functions {
+			method := &coberturaMethod{Name: fun.Name}
+			methodLines := make(map[int]int64)
+			for _, st := range fun.Statements {
+				if st.Ignored {
+					continue
+				}
+				addLineHits(methodLines, st)
+				addLineHits(classLines, st)
+			}
+			method.Lines = sortedCoberturaLines(methodLines)
+			method.LineRate = coberturaLineRate(method.Lines)
+			class.Methods = append(class.Methods, method)
+		}
+		class.Lines = sortedCoberturaLines(classLines)
+		class.LineRate = coberturaLineRate(class.Lines)
+
+		pkgName := path.Dir(profile.FileName)
+		pkg, ok := packages[pkgName]
+		if !ok {
+			pkg = &coberturaPackage{Name: pkgName}
+			packages[pkgName] = pkg
+			packageNames = append(packageNames, pkgName)
+		}
+		pkg.Classes = append(pkg.Classes, class)
+
+		covered, valid := countCoveredLines(class.Lines)
+		counts := packageLines[pkgName]
+		packageLines[pkgName] = [2]int{counts[0] + covered, counts[1] + valid}
+		coverage.LinesCovered += covered
+		coverage.LinesValid += valid
+	}
+
+	sort.Strings(packageNames)
+	for _, name := range packageNames {
+		pkg := packages[name]
+		pkg.LineRate = lineRate(packageLines[name][0], packageLines[name][1])
+		coverage.Packages = append(coverage.Packages, pkg)
+	}
+	coverage.LineRate = lineRate(coverage.LinesCovered, coverage.LinesValid)
+
+	return coverage
+}
+
+// addLineHits records the statement as a line. When several statements start on the same line,
+// the line takes the least hits, so that the line is covered only if all of its statements are covered.
+func addLineHits(lines map[int]int64, st *StatementProfile) {
+	if hits, ok := lines[st.StartLine]; !ok || st.Reached < hits {
+		lines[st.StartLine] = st.Reached
+	}
+}
+
+func sortedCoberturaLines(lines map[int]int64) []*coberturaLine {
+	result := make([]*coberturaLine, 0, len(lines))
+	for _, number := range sortedLineNumbers(lines) {
+		result = append(result, &coberturaLine{Number: number, Hits: lines[number]})
+	}
+	return result
+}
+
+func sortedLineNumbers(lines map[int]int64) []int {
+	numbers := make([]int, 0, len(lines))
+	for number := range lines {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+func countCoveredLines(lines []*coberturaLine) (covered int, valid int) {
+	for _, line := range lines {
+		if line.Hits > 0 {
+			covered++
+		}
+	}
+	return covered, len(lines)
+}
+
+func coberturaLineRate(lines []*coberturaLine) float64 {
+	return lineRate(countCoveredLines(lines))
+}
+
+// lineRate returns the proportion of covered lines between 0 and 1.
+func lineRate(covered int, valid int) float64 {
+	if valid == 0 {
+		return 1
+	}
+	return float64(covered) / float64(valid)
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func coberturaStatistics() *Statistics {
+	return &Statistics{
+		StatisticsType: FullStatisticsType,
+		RepositoryPath: "/home/user/gocover",
+		CoverageProfile: []*CoverageProfile{
+			{
+				FileName:   "github.com/Azure/gocover/pkg/foo/foo.go",
+				SourceFile: "/home/user/gocover/pkg/foo/foo.go",
+				Functions: []*FunctionProfile{
+					{
+						Name: "Foo", StartLine: 3, EndLine: 8,
+						Statements: []*StatementProfile{
+							{StartLine: 4, EndLine: 4, Reached: 2},
+							{StartLine: 5, EndLine: 5, Reached: 0},
+							{StartLine: 6, EndLine: 6, Reached: 0, Ignored: true},
+						},
+					},
+					{
+						Name: "Bar.Zoo", StartLine: 10, EndLine: 12,
+						Statements: []*StatementProfile{
+							{StartLine: 11, EndLine: 11, Reached: 1},
+							{StartLine: 11, EndLine: 11, Reached: 0},
+						},
+					},
+				},
+			},
+			{
+				FileName:   "github.com/Azure/gocover/pkg/bar/bar.go",
+				SourceFile: "/home/user/gocover/pkg/bar/bar.go",
+				Functions: []*FunctionProfile{
+					{
+						Name: "Bar", StartLine: 3, EndLine: 5,
+						Statements: []*StatementProfile{
+							{StartLine: 4, EndLine: 4, Reached: 1},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestBuildCoberturaCoverage(t *testing.T) {
+	t.Run("buildCoberturaCoverage", func(t *testing.T) {
+		now := time.Now()
+		coverage := buildCoberturaCoverage(coberturaStatistics(), now)
+
+		assert.Equal(t, now.UnixMilli(), coverage.Timestamp)
+		assert.Equal(t, []string{"/home/user/gocover"}, coverage.Sources)
+		assert.Equal(t, 2, coverage.LinesCovered)
+		assert.Equal(t, 4, coverage.LinesValid)
+		assert.Equal(t, 0.5, coverage.LineRate)
+
+		assert.Len(t, coverage.Packages, 2)
+		bar, foo := coverage.Packages[0], coverage.Packages[1]
+		assert.Equal(t, "github.com/Azure/gocover/pkg/bar", bar.Name)
+		assert.Equal(t, 1.0, bar.LineRate)
+		assert.Equal(t, "github.com/Azure/gocover/pkg/foo", foo.Name)
+		assert.InDelta(t, 1.0/3, foo.LineRate, 0.0001)
+
+		assert.Len(t, foo.Classes, 1)
+		class := foo.Classes[0]
+		assert.Equal(t, "foo.go", class.Name)
+		assert.Equal(t, "pkg/foo/foo.go", class.Filename)
+		assert.Len(t, class.Methods, 2)
+		assert.Equal(t, "Foo", class.Methods[0].Name)
+		assert.Equal(t, "Bar.Zoo", class.Methods[1].Name)
+		assert.Equal(t, []*coberturaLine{{Number: 4, Hits: 2}, {Number: 5, Hits: 0}}, class.Methods[0].Lines, "ignored statement should not be reported")
+		assert.Equal(t, []*coberturaLine{{Number: 11, Hits: 0}}, class.Methods[1].Lines, "line with uncovered statement should not be covered")
+		assert.Equal(t, []*coberturaLine{{Number: 4, Hits: 2}, {Number: 5, Hits: 0}, {Number: 11, Hits: 0}}, class.Lines)
+	})
+
+	t.Run("file out of repository", func(t *testing.T) {
+		statistics := coberturaStatistics()
+		statistics.CoverageProfile[1].SourceFile = "/home/user/go/pkg/mod/example.com/bar/bar.go"
+		coverage := buildCoberturaCoverage(statistics, time.Now())
+
+		bar := coverage.Packages[0]
+		if assert.Len(t, bar.Classes, 1) {
+			assert.Equal(t, "/home/user/go/pkg/mod/example.com/bar/bar.go", bar.Classes[0].Filename)
+		}
+	})
+
+	t.Run("no coverage profiles", func(t *testing.T) {
+		coverage := buildCoberturaCoverage(&Statistics{}, time.Now())
+		assert.Equal(t, 1.0, coverage.LineRate)
+		assert.Empty(t, coverage.Packages)
+	})
+}
+
+func TestCoberturaReportGenerator(t *testing.T) {
+	t.Run("generate cobertura report", func(t *testing.T) {
+		path := t.TempDir()
+		g := newCoberturaReportGenerator(path, "coverage", logrus.New())
+
+		err := g.GenerateReport(coberturaStatistics())
+		assert.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(path, "coverage.xml"))
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(data), xml.Header+coberturaDocType))
+
+		result := &coberturaCoverage{}
+		assert.NoError(t, xml.Unmarshal(data, result))
+		assert.Len(t, result.Packages, 2)
+		assert.Contains(t, string(data), `<class name="foo.go" filename="pkg/foo/foo.go"`)
+		assert.Contains(t, string(data), `<line number="4" hits="2"></line>`)
+	})
+
+	t.Run("create report file fail", func(t *testing.T) {
+		g := newCoberturaReportGenerator(filepath.Join(t.TempDir(), "nonexist"), "coverage", logrus.New())
+		err := g.GenerateReport(coberturaStatistics())
+		assert.Error(t, err)
+	})
+}
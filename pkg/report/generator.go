@@ -2,6 +2,7 @@ package report
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
 	"os"
@@ -21,6 +22,20 @@ type ReportGenerator interface {
 	GenerateReport(statistics *Statistics) error
 }
 
+// ReportFormat represents the format of the coverage report.
+type ReportFormat string
+
+const (
+	HTMLReportFormat      ReportFormat = "html"
+	JSONReportFormat      ReportFormat = "json"
+	MarkdownReportFormat  ReportFormat = "markdown"
+	CoberturaReportFormat ReportFormat = "cobertura"
+	LCOVReportFormat      ReportFormat = "lcov"
+	SARIFReportFormat     ReportFormat = "sarif"
+)
+
+var ErrUnsupportedReportFormat = errors.New("unsupported report format")
+
 // htmlReportGenerator implements a html style report generator.
 type htmlReportGenerator struct {
 	// lexer for parsing go code
@@ -44,10 +59,37 @@ const (
 	codeHighlightColor = "bg:#ffcccc"
 )
 
-// NewReportGenerator creates a html report generator to generate html coverage report.
+// NewReportGenerator creates a report generator according to the report format.
+// codeStyle only takes effect on the html report.
+func NewReportGenerator(
+	format ReportFormat,
+	codeStyle string,
+	outputPath string,
+	reportName string,
+	logger logrus.FieldLogger,
+) (ReportGenerator, error) {
+	switch format {
+	case HTMLReportFormat:
+		return newHTMLReportGenerator(codeStyle, outputPath, reportName, logger), nil
+	case JSONReportFormat:
+		return newJSONReportGenerator(outputPath, reportName, logger), nil
+	case MarkdownReportFormat:
+		return newMarkdownReportGenerator(outputPath, reportName, logger), nil
+	case CoberturaReportFormat:
+		return newCoberturaReportGenerator(outputPath, reportName, logger), nil
+	case LCOVReportFormat:
+		return newLCOVReportGenerator(outputPath, reportName, logger), nil
+	case SARIFReportFormat:
+		return newSARIFReportGenerator(outputPath, reportName, logger), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedReportFormat, format)
+	}
+}
+
+// newHTMLReportGenerator creates a html report generator to generate html coverage report.
 // We will use https://pygments.org/docs/styles to style the output,
 // and use // https://github.com/alecthomas/chroma to help to generate code snippets.
-func NewReportGenerator(
+func newHTMLReportGenerator(
 	codeStyle string,
 	outputPath string,
 	reportName string,
@@ -1,6 +1,7 @@
 package report
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,7 +14,50 @@ import (
 
 func TestNewReportGenerator(t *testing.T) {
 	t.Run("NewReportGenerator", func(t *testing.T) {
-		NewReportGenerator("colorful", "", "", logrus.New())
+		g, err := NewReportGenerator(HTMLReportFormat, "colorful", "", "", logrus.New())
+		if err != nil {
+			t.Errorf("should not error, but get: %s", err)
+		}
+		if _, ok := g.(*htmlReportGenerator); !ok {
+			t.Errorf("should be html report generator, but get %T", g)
+		}
+
+		g, err = NewReportGenerator(JSONReportFormat, "colorful", "", "", logrus.New())
+		if err != nil {
+			t.Errorf("should not error, but get: %s", err)
+		}
+		if _, ok := g.(*jsonReportGenerator); !ok {
+			t.Errorf("should be json report generator, but get %T", g)
+		}
+
+		g, err = NewReportGenerator(MarkdownReportFormat, "colorful", "", "", logrus.New())
+		if err != nil {
+			t.Errorf("should not error, but get: %s", err)
+		}
+		if _, ok := g.(*markdownReportGenerator); !ok {
+			t.Errorf("should be markdown report generator, but get %T", g)
+		}
+
+		g, err = NewReportGenerator(CoberturaReportFormat, "colorful", "", "", logrus.New())
+		if err != nil {
+			t.Errorf("should not error, but get: %s", err)
+		}
+		if _, ok := g.(*coberturaReportGenerator); !ok {
+			t.Errorf("should be cobertura report generator, but get %T", g)
+		}
+
+		g, err = NewReportGenerator(LCOVReportFormat, "colorful", "", "", logrus.New())
+		if err != nil {
+			t.Errorf("should not error, but get: %s", err)
+		}
+		if _, ok := g.(*lcovReportGenerator); !ok {
+			t.Errorf("should be lcov report generator, but get %T", g)
+		}
+
+		_, err = NewReportGenerator("unknown", "colorful", "", "", logrus.New())
+		if !errors.Is(err, ErrUnsupportedReportFormat) {
+			t.Errorf("should return ErrUnsupportedReportFormat, but get: %v", err)
+		}
 	})
 }
 
@@ -135,6 +179,95 @@ func TestGenerateReport(t *testing.T) {
 		}
 	})
 
+	t.Run("covering tests", func(t *testing.T) {
+		path, clean := temporalDir()
+		defer clean()
+
+		g := &htmlReportGenerator{
+			lexer:      lexers.Get(CodeLanguage),
+			style:      styles.Get("colorful"),
+			outputPath: path,
+			reportName: "corverage.html",
+			logger:     logrus.New(),
+		}
+
+		err := g.GenerateReport(&Statistics{
+			StatisticsType:      DiffStatisticsType,
+			TotalLines:          2,
+			TotalEffectiveLines: 2,
+			TotalCoveredLines:   2,
+			CoverageProfile: []*CoverageProfile{
+				{
+					FileName:            "foo.go",
+					TotalLines:          2,
+					TotalEffectiveLines: 2,
+					CoveredLines:        2,
+					CoveringTests: []*CoveringTests{
+						{StartLine: 3, EndLine: 3, Tests: []string{"example.com/foo.TestFoo"}},
+						{StartLine: 5, EndLine: 7, Tests: []string{"example.com/foo.TestBar", "example.com/foo.TestFoo"}},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Errorf("should not error, but get: %s", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(g.outputPath, finalName(g.reportName)))
+		checkError(err)
+
+		reportString := string(data)
+		for _, v := range []string{
+			`id="foo.go"`,
+			"<td>3</td>",
+			"<td>5-7</td>",
+			"example.com/foo.TestBar<br />example.com/foo.TestFoo",
+		} {
+			if !strings.Contains(reportString, v) {
+				t.Errorf("report should contain %s", v)
+			}
+		}
+	})
+
+	t.Run("threshold violations", func(t *testing.T) {
+		path, clean := temporalDir()
+		defer clean()
+
+		g := &htmlReportGenerator{
+			lexer:      lexers.Get(CodeLanguage),
+			style:      styles.Get("colorful"),
+			outputPath: path,
+			reportName: "corverage.html",
+			logger:     logrus.New(),
+		}
+
+		err := g.GenerateReport(&Statistics{
+			StatisticsType: FullStatisticsType,
+			ThresholdViolations: []*ThresholdViolation{
+				{Path: "github.com/Azure/gocover/pkg/foo", Pattern: "**/pkg/foo", Threshold: 90, Coverage: 50},
+			},
+		})
+		if err != nil {
+			t.Errorf("should not error, but get: %s", err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(g.outputPath, finalName(g.reportName)))
+		checkError(err)
+
+		reportString := string(data)
+		for _, v := range []string{
+			"Threshold Violations",
+			"<td>github.com/Azure/gocover/pkg/foo</td>",
+			"<td>**/pkg/foo</td>",
+			"<td>50.00</td>",
+			"<td>90.00</td>",
+		} {
+			if !strings.Contains(reportString, v) {
+				t.Errorf("report should contain %s", v)
+			}
+		}
+	})
+
 	t.Run("have full coverage profiles", func(t *testing.T) {
 		path, clean := temporalDir()
 		defer clean()
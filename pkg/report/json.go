@@ -0,0 +1,277 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JSONReportSchemaVersion is the version of the json coverage report schema.
+// The major version only changes when a field is removed, renamed or changes its meaning,
+// adding new fields increases the minor version, so consumers can safely ignore unknown fields.
+const JSONReportSchemaVersion = "1.6"
+
+// JSONReport is the root object of the json coverage report.
+type JSONReport struct {
+	// SchemaVersion is the version of the schema, see JSONReportSchemaVersion.
+	SchemaVersion string `json:"schemaVersion"`
+	// Type is the type of the coverage, "full" or "diff".
+	Type StatisticsType `json:"type"`
+	// ComparedBranch is the branch that diff compared with, only available for diff coverage.
+	ComparedBranch string `json:"comparedBranch,omitempty"`
+	// HeadRevision is the revision that contains the changes, only available for diff coverage of a revision range, since 1.1.
+	HeadRevision string `json:"headRevision,omitempty"`
+	// Summary is the total coverage of all the files.
+	Summary *JSONSummary `json:"summary"`
+	// Files contains the coverage of each file.
+	Files []*JSONFileProfile `json:"files"`
+	// ExcludeFiles are the files that don't take participate in coverage calculation.
+	ExcludeFiles []string `json:"excludeFiles"`
+	// ThresholdViolations are the packages and files that violate their threshold rules, since 1.1.
+	ThresholdViolations []*JSONThresholdViolation `json:"thresholdViolations"`
+	// RenamedFiles are the go files renamed or moved in the diff, since 1.2.
+	RenamedFiles []*JSONRenamedFile `json:"renamedFiles"`
+	// IndirectCoverageLoss are the files that some unchanged lines are not covered any more, since 1.3.
+	IndirectCoverageLoss []*JSONIndirectCoverageLoss `json:"indirectCoverageLoss"`
+	// Modules are the coverage of each module when the report covers several modules of a workspace, since 1.4.
+	Modules []*JSONModuleCoverage `json:"modules"`
+	// TestResults is the summary of the tests run by gocover test, only available for gocover test, since 1.5.
+	TestResults *JSONTestResults `json:"testResults,omitempty"`
+	// TestSelection is how the tests are selected by the changes, only available when the selection is applied, since 1.6.
+	TestSelection *JSONTestSelection `json:"testSelection,omitempty"`
+}
+
+// JSONSummary represents the total coverage information.
+type JSONSummary struct {
+	TotalLines             int     `json:"totalLines"`             // total lines that count for coverage
+	EffectiveLines         int     `json:"effectiveLines"`         // total lines - ignored lines
+	IgnoredLines           int     `json:"ignoredLines"`           // the lines ignored
+	CoveredLines           int     `json:"coveredLines"`           // the lines covered by test
+	CoveredButIgnoredLines int     `json:"coveredButIgnoredLines"` // the lines covered but ignored
+	ViolationLines         int     `json:"violationLines"`         // the lines that miss test coverage
+	Coverage               float64 `json:"coverage"`               // CoveredLines / TotalLines
+	CoverageWithIgnorance  float64 `json:"coverageWithIgnorance"`  // (CoveredLines - CoveredButIgnoredLines) / EffectiveLines
+}
+
+// JSONFileProfile represents the coverage information of a file.
+type JSONFileProfile struct {
+	FileName               string                  `json:"fileName"`               // file name that prefixed with module path
+	TotalLines             int                     `json:"totalLines"`             // total lines that count for coverage
+	EffectiveLines         int                     `json:"effectiveLines"`         // total lines - ignored lines
+	IgnoredLines           int                     `json:"ignoredLines"`           // the lines ignored
+	CoveredLines           int                     `json:"coveredLines"`           // the lines covered by test
+	CoveredButIgnoredLines int                     `json:"coveredButIgnoredLines"` // the lines covered but ignored
+	Coverage               float64                 `json:"coverage"`               // CoveredLines / TotalLines
+	CoverageWithIgnorance  float64                 `json:"coverageWithIgnorance"`  // (CoveredLines - CoveredButIgnoredLines) / EffectiveLines
+	ViolationLines         []int                   `json:"violationLines"`         // line numbers that miss test coverage
+	ViolationSections      []*JSONViolationSection `json:"violationSections"`      // sections that contain violation lines
+}
+
+// JSONViolationSection represents a portion of the source file that misses test coverage.
+type JSONViolationSection struct {
+	StartLine      int      `json:"startLine"`      // start line of the section
+	EndLine        int      `json:"endLine"`        // end line of the section
+	ViolationLines []int    `json:"violationLines"` // line numbers that miss test coverage
+	Contents       []string `json:"contents"`       // [StartLine..EndLine] lines from the source file
+}
+
+// JSONThresholdViolation represents a package or file whose coverage is lower than the threshold of its rule.
+type JSONThresholdViolation struct {
+	Path      string  `json:"path"`      // package or file path prefixed with module path
+	Pattern   string  `json:"pattern"`   // pattern of the rule that matches the path
+	Threshold float64 `json:"threshold"` // expected coverage percent
+	Coverage  float64 `json:"coverage"`  // coverage percent (with ignorance) of the path
+}
+
+// JSONRenamedFile represents a file that is renamed or moved.
+type JSONRenamedFile struct {
+	From string `json:"from"` // file path relative to the repository before renaming
+	To   string `json:"to"`   // file path relative to the repository after renaming
+}
+
+// JSONIndirectCoverageLoss represents the unchanged lines of a file that are covered on the compared branch but not covered any more.
+type JSONIndirectCoverageLoss struct {
+	FileName string `json:"fileName"` // file name that prefixed with module path
+	Lines    []int  `json:"lines"`    // start lines of the statements that are not covered any more
+}
+
+// JSONModuleCoverage represents the coverage of a module in the workspace.
+type JSONModuleCoverage struct {
+	Path                  string  `json:"path"`                  // module path
+	EffectiveLines        int64   `json:"effectiveLines"`        // effective lines of the module
+	CoveredLines          int64   `json:"coveredLines"`          // covered lines of the module
+	CoverageWithIgnorance float64 `json:"coverageWithIgnorance"` // coverage percent (with ignorance) of the module
+	Error                 string  `json:"error,omitempty"`       // failure of running the tests of the module
+}
+
+// JSONTestResults represents the summary of the tests run by gocover test.
+type JSONTestResults struct {
+	Passed      int               `json:"passed"`      // number of passed tests
+	Failed      int               `json:"failed"`      // number of failed tests
+	Skipped     int               `json:"skipped"`     // number of skipped tests
+	FailedTests []*JSONFailedTest `json:"failedTests"` // the failed tests, and the failed packages that no test fails
+}
+
+// JSONFailedTest represents a failed test, or a failed package that no test fails, such as a build failure.
+type JSONFailedTest struct {
+	Package string  `json:"package"`        // import path of the package
+	Name    string  `json:"name,omitempty"` // name of the test, empty for a failed package
+	Elapsed float64 `json:"elapsed"`        // seconds the test takes
+	Output  string  `json:"output"`         // output of the test
+}
+
+// JSONTestSelection represents the test packages selected by the changes of diff coverage.
+type JSONTestSelection struct {
+	ChangedPackages  []string `json:"changedPackages"`  // import paths of the packages that contain changed files
+	SelectedPackages []string `json:"selectedPackages"` // import paths of the packages whose tests are run
+	TotalPackages    int      `json:"totalPackages"`    // number of the packages that have tests
+}
+
+// jsonReportGenerator implements a json style report generator.
+type jsonReportGenerator struct {
+	// outputPath report path
+	outputPath string
+	// reportName report name
+	reportName string
+	// logger
+	logger logrus.FieldLogger
+}
+
+var _ ReportGenerator = (*jsonReportGenerator)(nil)
+
+func newJSONReportGenerator(outputPath string, reportName string, logger logrus.FieldLogger) ReportGenerator {
+	return &jsonReportGenerator{
+		outputPath: outputPath,
+		reportName: reportName,
+		logger:     logger,
+	}
+}
+
+// GenerateReport serializes the coverage statistics and writes it to the json report.
+func (g *jsonReportGenerator) GenerateReport(statistics *Statistics) error {
+	reportFile := filepath.Join(g.outputPath, fmt.Sprintf("%s.json", g.reportName))
+	f, err := os.Create(reportFile)
+	if err != nil {
+		return fmt.Errorf("create report file: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(buildJSONReport(statistics)); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	g.logger.Infof("generate json coverage report: %s", reportFile)
+	return nil
+}
+
+// buildJSONReport converts the statistics into json report.
+// Slices are always initialized so they are encoded as empty arrays instead of null.
+func buildJSONReport(statistics *Statistics) *JSONReport {
+	result := &JSONReport{
+		SchemaVersion:  JSONReportSchemaVersion,
+		Type:           statistics.StatisticsType,
+		ComparedBranch: statistics.ComparedBranch,
+		HeadRevision:   statistics.HeadRevision,
+		Summary: &JSONSummary{
+			TotalLines:             statistics.TotalLines,
+			EffectiveLines:         statistics.TotalEffectiveLines,
+			IgnoredLines:           statistics.TotalIgnoredLines,
+			CoveredLines:           statistics.TotalCoveredLines,
+			CoveredButIgnoredLines: statistics.TotalCoveredButIgnoredLines,
+			Coverage:               statistics.TotalCoverageWithoutIgnore,
+			CoverageWithIgnorance:  statistics.TotalCoveragePercent,
+		},
+		Files:                make([]*JSONFileProfile, 0, len(statistics.CoverageProfile)),
+		ExcludeFiles:         make([]string, 0, len(statistics.ExcludeFiles)),
+		ThresholdViolations:  make([]*JSONThresholdViolation, 0, len(statistics.ThresholdViolations)),
+		RenamedFiles:         make([]*JSONRenamedFile, 0, len(statistics.RenamedFiles)),
+		IndirectCoverageLoss: make([]*JSONIndirectCoverageLoss, 0, len(statistics.IndirectCoverageLoss)),
+		Modules:              make([]*JSONModuleCoverage, 0, len(statistics.Modules)),
+	}
+	result.ExcludeFiles = append(result.ExcludeFiles, statistics.ExcludeFiles...)
+	for _, v := range statistics.ThresholdViolations {
+		result.ThresholdViolations = append(result.ThresholdViolations, &JSONThresholdViolation{
+			Path:      v.Path,
+			Pattern:   v.Pattern,
+			Threshold: v.Threshold,
+			Coverage:  v.Coverage,
+		})
+	}
+	for _, f := range statistics.RenamedFiles {
+		result.RenamedFiles = append(result.RenamedFiles, &JSONRenamedFile{From: f.From, To: f.To})
+	}
+	for _, loss := range statistics.IndirectCoverageLoss {
+		result.IndirectCoverageLoss = append(result.IndirectCoverageLoss, &JSONIndirectCoverageLoss{
+			FileName: loss.FileName,
+			Lines:    append(make([]int, 0, len(loss.Lines)), loss.Lines...),
+		})
+	}
+	for _, m := range statistics.Modules {
+		result.Modules = append(result.Modules, &JSONModuleCoverage{
+			Path:                  m.Path,
+			EffectiveLines:        m.TotalEffectiveLines,
+			CoveredLines:          m.TotalCoveredLines,
+			CoverageWithIgnorance: m.CoveragePercent,
+			Error:                 m.Error,
+		})
+	}
+	if statistics.TestResults != nil {
+		testResults := &JSONTestResults{FailedTests: make([]*JSONFailedTest, 0)}
+		testResults.Passed, testResults.Failed, testResults.Skipped = statistics.TestResults.Counts()
+		for _, test := range statistics.TestResults.FailedTests() {
+			testResults.FailedTests = append(testResults.FailedTests, &JSONFailedTest{
+				Package: test.Package,
+				Name:    test.Name,
+				Elapsed: test.Elapsed,
+				Output:  test.Output,
+			})
+		}
+		result.TestResults = testResults
+	}
+	if selection := statistics.TestSelection; selection != nil {
+		result.TestSelection = &JSONTestSelection{
+			ChangedPackages:  append(make([]string, 0, len(selection.ChangedPackages)), selection.ChangedPackages...),
+			SelectedPackages: append(make([]string, 0, len(selection.SelectedPackages)), selection.SelectedPackages...),
+			TotalPackages:    selection.TotalPackages,
+		}
+	}
+
+	for _, profile := range statistics.CoverageProfile {
+		file := &JSONFileProfile{
+			FileName:               profile.FileName,
+			TotalLines:             profile.TotalLines,
+			EffectiveLines:         profile.TotalEffectiveLines,
+			IgnoredLines:           profile.TotalIgnoredLines,
+			CoveredLines:           profile.CoveredLines,
+			CoveredButIgnoredLines: profile.CoveredButIgnoredLines,
+			Coverage:               percentCovered(profile.TotalLines, profile.CoveredLines, 0),
+			CoverageWithIgnorance:  percentCovered(profile.TotalEffectiveLines, profile.CoveredLines, profile.CoveredButIgnoredLines),
+			ViolationLines:         make([]int, 0),
+			ViolationSections:      make([]*JSONViolationSection, 0, len(profile.ViolationSections)),
+		}
+
+		for _, section := range profile.ViolationSections {
+			s := &JSONViolationSection{
+				StartLine:      section.StartLine,
+				EndLine:        section.EndLine,
+				ViolationLines: make([]int, 0, len(section.ViolationLines)),
+				Contents:       make([]string, 0, len(section.Contents)),
+			}
+			s.ViolationLines = append(s.ViolationLines, section.ViolationLines...)
+			s.Contents = append(s.Contents, section.Contents...)
+
+			file.ViolationLines = append(file.ViolationLines, section.ViolationLines...)
+			file.ViolationSections = append(file.ViolationSections, s)
+		}
+
+		result.Summary.ViolationLines += len(file.ViolationLines)
+		result.Files = append(result.Files, file)
+	}
+
+	return result
+}
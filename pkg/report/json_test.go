@@ -0,0 +1,141 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONReportGenerator(t *testing.T) {
+	t.Run("no coverage profiles", func(t *testing.T) {
+		path := t.TempDir()
+		g := newJSONReportGenerator(path, "coverage", logrus.New())
+
+		err := g.GenerateReport(&Statistics{
+			ComparedBranch: "origin/master",
+			StatisticsType: DiffStatisticsType,
+		})
+		assert.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(path, "coverage.json"))
+		assert.NoError(t, err)
+
+		var raw map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &raw))
+		assert.Equal(t, JSONReportSchemaVersion, raw["schemaVersion"])
+		assert.Equal(t, "diff", raw["type"])
+		assert.Equal(t, "origin/master", raw["comparedBranch"])
+		assert.Equal(t, []interface{}{}, raw["files"], "files should be an empty array rather than null")
+		assert.Equal(t, []interface{}{}, raw["excludeFiles"], "excludeFiles should be an empty array rather than null")
+		assert.Equal(t, []interface{}{}, raw["renamedFiles"], "renamedFiles should be an empty array rather than null")
+	})
+
+	t.Run("renamed files", func(t *testing.T) {
+		result := buildJSONReport(&Statistics{
+			StatisticsType: DiffStatisticsType,
+			RenamedFiles:   []*RenamedFile{{From: "pkg/foo/foo.go", To: "pkg/bar/foo.go"}},
+		})
+		assert.Equal(t, []*JSONRenamedFile{{From: "pkg/foo/foo.go", To: "pkg/bar/foo.go"}}, result.RenamedFiles)
+	})
+
+	t.Run("indirect coverage loss", func(t *testing.T) {
+		result := buildJSONReport(&Statistics{
+			StatisticsType:       DiffStatisticsType,
+			IndirectCoverageLoss: []*IndirectCoverageLoss{{FileName: "foo.go", Lines: []int{3, 6}}},
+		})
+		assert.Equal(t, []*JSONIndirectCoverageLoss{{FileName: "foo.go", Lines: []int{3, 6}}}, result.IndirectCoverageLoss)
+		assert.Equal(t, []*JSONIndirectCoverageLoss{}, buildJSONReport(&Statistics{}).IndirectCoverageLoss)
+	})
+
+	t.Run("have coverage profiles", func(t *testing.T) {
+		path := t.TempDir()
+		g := newJSONReportGenerator(path, "coverage", logrus.New())
+
+		statistics := &Statistics{
+			StatisticsType:              FullStatisticsType,
+			TotalLines:                  32,
+			TotalEffectiveLines:         30,
+			TotalIgnoredLines:           2,
+			TotalCoveredLines:           28,
+			TotalCoveredButIgnoredLines: 1,
+			TotalCoveragePercent:        90,
+			TotalCoverageWithoutIgnore:  87.5,
+			ExcludeFiles:                []string{"exclude.go"},
+			ThresholdViolations: []*ThresholdViolation{
+				{Path: "github.com/Azure/gocover/pkg/bar", Pattern: "**/pkg/bar", Threshold: 80, Coverage: 70},
+			},
+			CoverageProfile: []*CoverageProfile{
+				{
+					FileName:            "foo.go",
+					TotalLines:          20,
+					TotalEffectiveLines: 20,
+					CoveredLines:        20,
+				},
+				{
+					FileName:               "bar.go",
+					TotalLines:             12,
+					TotalEffectiveLines:    10,
+					TotalIgnoredLines:      2,
+					CoveredLines:           8,
+					CoveredButIgnoredLines: 1,
+					ViolationSections: []*ViolationSection{
+						{ViolationLines: []int{2}, StartLine: 1, EndLine: 3, Contents: []string{"foo", "bar", "zoo"}},
+						{ViolationLines: []int{9, 10}, StartLine: 8, EndLine: 10, Contents: []string{"text1", "text2", "text3"}},
+					},
+				},
+			},
+		}
+
+		err := g.GenerateReport(statistics)
+		assert.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(path, "coverage.json"))
+		assert.NoError(t, err)
+
+		result := &JSONReport{}
+		assert.NoError(t, json.Unmarshal(data, result))
+
+		assert.Equal(t, FullStatisticsType, result.Type)
+		assert.Empty(t, result.ComparedBranch)
+		assert.Equal(t, []string{"exclude.go"}, result.ExcludeFiles)
+
+		assert.Equal(t, 32, result.Summary.TotalLines)
+		assert.Equal(t, 30, result.Summary.EffectiveLines)
+		assert.Equal(t, 2, result.Summary.IgnoredLines)
+		assert.Equal(t, 28, result.Summary.CoveredLines)
+		assert.Equal(t, 1, result.Summary.CoveredButIgnoredLines)
+		assert.Equal(t, 3, result.Summary.ViolationLines)
+		assert.Equal(t, 87.5, result.Summary.Coverage)
+		assert.Equal(t, 90.0, result.Summary.CoverageWithIgnorance)
+
+		assert.Len(t, result.Files, 2)
+		assert.Equal(t, "foo.go", result.Files[0].FileName)
+		assert.Equal(t, 100.0, result.Files[0].CoverageWithIgnorance)
+		assert.Empty(t, result.Files[0].ViolationSections)
+
+		bar := result.Files[1]
+		assert.Equal(t, "bar.go", bar.FileName)
+		assert.Equal(t, 66.67, bar.Coverage)
+		assert.Equal(t, 70.0, bar.CoverageWithIgnorance)
+		assert.Equal(t, []int{2, 9, 10}, bar.ViolationLines)
+		assert.Len(t, bar.ViolationSections, 2)
+		assert.Equal(t, 8, bar.ViolationSections[1].StartLine)
+		assert.Equal(t, 10, bar.ViolationSections[1].EndLine)
+		assert.Equal(t, []int{9, 10}, bar.ViolationSections[1].ViolationLines)
+		assert.Equal(t, []string{"text1", "text2", "text3"}, bar.ViolationSections[1].Contents)
+
+		assert.Equal(t, []*JSONThresholdViolation{
+			{Path: "github.com/Azure/gocover/pkg/bar", Pattern: "**/pkg/bar", Threshold: 80, Coverage: 70},
+		}, result.ThresholdViolations)
+	})
+
+	t.Run("create report file fail", func(t *testing.T) {
+		g := newJSONReportGenerator(filepath.Join(t.TempDir(), "nonexist"), "coverage", logrus.New())
+		err := g.GenerateReport(&Statistics{StatisticsType: FullStatisticsType})
+		assert.Error(t, err)
+	})
+}
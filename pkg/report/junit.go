@@ -0,0 +1,183 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// TestResultAction is the result of a test or a test package, it's the same as the action of `go test -json`.
+type TestResultAction string
+
+const (
+	TestPass TestResultAction = "pass"
+	TestFail TestResultAction = "fail"
+	TestSkip TestResultAction = "skip"
+)
+
+// TestResults are the results of the tests run by gocover test.
+type TestResults struct {
+	// Packages are the test packages in the order they finish.
+	Packages []*PackageTestResult
+}
+
+// PackageTestResult represents the result of a test package.
+type PackageTestResult struct {
+	// Package is the import path of the package.
+	Package string
+	// Result is the result of the package.
+	Result TestResultAction
+	// Elapsed is the seconds the package takes.
+	Elapsed float64
+	// Output is the output of the failed package that doesn't belong to any test, such as a build error or a panic.
+	Output string
+	// Tests are the tests of the package in the order they finish.
+	Tests []*TestResult
+}
+
+// TestResult represents the result of a test.
+type TestResult struct {
+	// Name is the name of the test, subtests are named as Parent/Sub.
+	Name string
+	// Result is the result of the test.
+	Result TestResultAction
+	// Elapsed is the seconds the test takes.
+	Elapsed float64
+	// Output is the output of the failed test.
+	Output string
+}
+
+// FailedTest represents a failed test, or a failed package that no test fails, such as a build failure.
+type FailedTest struct {
+	// Package is the import path of the package.
+	Package string
+	// Name is the name of the test, empty for a failed package.
+	Name string
+	// Elapsed is the seconds the test takes.
+	Elapsed float64
+	// Output is the output of the test.
+	Output string
+}
+
+// Counts returns the number of the passed, failed and skipped tests.
+func (r *TestResults) Counts() (passed, failed, skipped int) {
+	for _, pkg := range r.Packages {
+		for _, test := range pkg.Tests {
+			switch test.Result {
+			case TestPass:
+				passed++
+			case TestFail:
+				failed++
+			case TestSkip:
+				skipped++
+			}
+		}
+	}
+	return passed, failed, skipped
+}
+
+// FailedTests returns the failed tests, and the failed packages that no test fails.
+func (r *TestResults) FailedTests() []*FailedTest {
+	var result []*FailedTest
+	for _, pkg := range r.Packages {
+		failed := false
+		for _, test := range pkg.Tests {
+			if test.Result == TestFail {
+				failed = true
+				result = append(result, &FailedTest{Package: pkg.Package, Name: test.Name, Elapsed: test.Elapsed, Output: test.Output})
+			}
+		}
+		if !failed && pkg.Result == TestFail {
+			result = append(result, &FailedTest{Package: pkg.Package, Elapsed: pkg.Elapsed, Output: pkg.Output})
+		}
+	}
+	return result
+}
+
+// junitTestSuites is the root element of the JUnit xml report, in the format that CI systems such as Jenkins
+// and Azure Pipelines accept.
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// WriteJUnit writes the test results in JUnit xml format, each package is a test suite.
+// A failed package that no test fails, such as a build failure, is reported as a failed test case named after the package.
+func WriteJUnit(w io.Writer, results *TestResults) error {
+	suites := &junitTestSuites{}
+	var elapsed float64
+	for _, pkg := range results.Packages {
+		suite := &junitTestSuite{Name: pkg.Package, Time: junitTime(pkg.Elapsed)}
+		for _, test := range pkg.Tests {
+			testCase := &junitTestCase{Name: test.Name, ClassName: pkg.Package, Time: junitTime(test.Elapsed)}
+			switch test.Result {
+			case TestFail:
+				testCase.Failure = &junitMessage{Message: "Failed", Contents: test.Output}
+				suite.Failures++
+			case TestSkip:
+				testCase.Skipped = &junitMessage{Message: "Skipped", Contents: test.Output}
+				suite.Skipped++
+			}
+			suite.Tests++
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		if pkg.Result == TestFail && suite.Failures == 0 {
+			suite.TestCases = append(suite.TestCases, &junitTestCase{
+				Name:      pkg.Package,
+				ClassName: pkg.Package,
+				Time:      junitTime(pkg.Elapsed),
+				Failure:   &junitMessage{Message: "Failed", Contents: pkg.Output},
+			})
+			suite.Tests++
+			suite.Failures++
+		}
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		elapsed += pkg.Elapsed
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = junitTime(elapsed)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return fmt.Errorf("encode junit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
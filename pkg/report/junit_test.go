@@ -0,0 +1,68 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestResults(t *testing.T) {
+	results := &TestResults{Packages: []*PackageTestResult{
+		{Package: "example.com/foo", Result: TestFail, Tests: []*TestResult{
+			{Name: "TestFoo", Result: TestPass},
+			{Name: "TestBar", Result: TestFail, Output: "bar failed"},
+			{Name: "TestZoo", Result: TestSkip},
+		}},
+		{Package: "example.com/bar", Result: TestFail, Output: "panic"},
+		{Package: "example.com/zoo", Result: TestPass, Tests: []*TestResult{{Name: "TestZoo", Result: TestPass}}},
+	}}
+
+	passed, failed, skipped := results.Counts()
+	assert.Equal(t, 2, passed)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, 1, skipped)
+
+	assert.Equal(t, []*FailedTest{
+		{Package: "example.com/foo", Name: "TestBar", Output: "bar failed"},
+		{Package: "example.com/bar", Output: "panic"},
+	}, results.FailedTests())
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := &TestResults{Packages: []*PackageTestResult{
+		{Package: "example.com/foo", Result: TestFail, Elapsed: 1.25, Tests: []*TestResult{
+			{Name: "TestFoo", Result: TestPass, Elapsed: 0.5},
+			{Name: "TestBar", Result: TestFail, Elapsed: 0.75, Output: "bar <failed>"},
+			{Name: "TestZoo", Result: TestSkip},
+		}},
+		{Package: "example.com/bar", Result: TestFail, Output: "build failed"},
+	}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteJUnit(&buf, results))
+
+	var suites junitTestSuites
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	assert.Equal(t, 4, suites.Tests)
+	assert.Equal(t, 2, suites.Failures)
+	assert.Equal(t, 1, suites.Skipped)
+	assert.Equal(t, "1.250", suites.Time)
+	if assert.Len(t, suites.Suites, 2) {
+		foo := suites.Suites[0]
+		assert.Equal(t, "example.com/foo", foo.Name)
+		assert.Equal(t, 3, foo.Tests)
+		if assert.Len(t, foo.TestCases, 3) {
+			assert.Nil(t, foo.TestCases[0].Failure)
+			assert.Equal(t, "bar <failed>", foo.TestCases[1].Failure.Contents)
+			assert.NotNil(t, foo.TestCases[2].Skipped)
+		}
+
+		bar := suites.Suites[1]
+		if assert.Len(t, bar.TestCases, 1) {
+			assert.Equal(t, "example.com/bar", bar.TestCases[0].Name)
+			assert.Equal(t, "build failed", bar.TestCases[0].Failure.Contents)
+		}
+	}
+}
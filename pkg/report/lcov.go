@@ -0,0 +1,102 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// lcovReportGenerator implements a lcov tracefile generator, the tracefile can be consumed by
+// genhtml and editor plugins such as Coverage Gutters.
+// The format can be found at https://ltp.sourceforge.net/coverage/lcov/geninfo.1.php.
+type lcovReportGenerator struct {
+	// outputPath report path
+	outputPath string
+	// reportName report name
+	reportName string
+	// logger
+	logger logrus.FieldLogger
+}
+
+var _ ReportGenerator = (*lcovReportGenerator)(nil)
+
+func newLCOVReportGenerator(outputPath string, reportName string, logger logrus.FieldLogger) ReportGenerator {
+	return &lcovReportGenerator{
+		outputPath: outputPath,
+		reportName: reportName,
+		logger:     logger,
+	}
+}
+
+// GenerateReport writes the coverage statistics to the lcov tracefile.
+func (g *lcovReportGenerator) GenerateReport(statistics *Statistics) error {
+	reportFile := filepath.Join(g.outputPath, fmt.Sprintf("%s.info", g.reportName))
+	f, err := os.Create(reportFile)
+	if err != nil {
+		return fmt.Errorf("create report file: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	writeLCOV(w, statistics)
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	g.logger.Infof("generate lcov coverage report: %s", reportFile)
+	return nil
+}
+
+// writeLCOV writes one record for each file. Functions' and statements' are taken from the coverage profile,
+// so for the diff coverage, only changed statements are written.
+// Ignored statements don't count for coverage, and they are not written as lines.
+func writeLCOV(w io.Writer, statistics *Statistics) {
+	for _, profile := range statistics.CoverageProfile {
+		fmt.Fprint(w, "TN:\n")
+		fmt.Fprintf(w, "SF:%s\n", profile.SourceFile)
+
+		var found, hit int
+		lines := make(map[int]int64)
+		for _, fun := range profile.Functions {
+			var reached int64
+			counted := false
+			for _, st := range fun.Statements {
+				if st.Ignored {
+					continue
+				}
+				counted = true
+				if st.Reached > reached {
+					reached = st.Reached
+				}
+				addLineHits(lines, st)
+			}
+			if !counted {
+				continue
+			}
+
+			found++
+			if reached > 0 {
+				hit++
+			}
+			fmt.Fprintf(w, "FN:%d,%s\n", fun.StartLine, fun.Name)
+			fmt.Fprintf(w, "FNDA:%d,%s\n", reached, fun.Name)
+		}
+		fmt.Fprintf(w, "FNF:%d\n", found)
+		fmt.Fprintf(w, "FNH:%d\n", hit)
+
+		var linesHit int
+		for _, number := range sortedLineNumbers(lines) {
+			if lines[number] > 0 {
+				linesHit++
+			}
+			fmt.Fprintf(w, "DA:%d,%d\n", number, lines[number])
+		}
+		fmt.Fprintf(w, "LF:%d\n", len(lines))
+		fmt.Fprintf(w, "LH:%d\n", linesHit)
+		fmt.Fprint(w, "end_of_record\n")
+	}
+}
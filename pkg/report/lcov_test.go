@@ -0,0 +1,80 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteLCOV(t *testing.T) {
+	t.Run("writeLCOV", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeLCOV(&buf, coberturaStatistics())
+
+		expected := `TN:
+SF:/home/user/gocover/pkg/foo/foo.go
+FN:3,Foo
+FNDA:2,Foo
+FN:10,Bar.Zoo
+FNDA:1,Bar.Zoo
+FNF:2
+FNH:2
+DA:4,2
+DA:5,0
+DA:11,0
+LF:3
+LH:1
+end_of_record
+TN:
+SF:/home/user/gocover/pkg/bar/bar.go
+FN:3,Bar
+FNDA:1,Bar
+FNF:1
+FNH:1
+DA:4,1
+LF:1
+LH:1
+end_of_record
+`
+		assert.Equal(t, expected, buf.String())
+	})
+
+	t.Run("skip functions that all statements are ignored", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeLCOV(&buf, &Statistics{
+			CoverageProfile: []*CoverageProfile{
+				{
+					SourceFile: "/foo.go",
+					Functions: []*FunctionProfile{
+						{Name: "Foo", StartLine: 1, Statements: []*StatementProfile{{StartLine: 2, Reached: 1, Ignored: true}}},
+					},
+				},
+			},
+		})
+		assert.Equal(t, "TN:\nSF:/foo.go\nFNF:0\nFNH:0\nLF:0\nLH:0\nend_of_record\n", buf.String())
+	})
+}
+
+func TestLCOVReportGenerator(t *testing.T) {
+	t.Run("generate lcov report", func(t *testing.T) {
+		path := t.TempDir()
+		g := newLCOVReportGenerator(path, "coverage", logrus.New())
+
+		err := g.GenerateReport(coberturaStatistics())
+		assert.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(path, "coverage.info"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "SF:/home/user/gocover/pkg/foo/foo.go")
+	})
+
+	t.Run("create report file fail", func(t *testing.T) {
+		g := newLCOVReportGenerator(filepath.Join(t.TempDir(), "nonexist"), "coverage", logrus.New())
+		err := g.GenerateReport(coberturaStatistics())
+		assert.Error(t, err)
+	})
+}
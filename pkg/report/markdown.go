@@ -0,0 +1,339 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// markdownMaxFiles is the max number of files rendered in the markdown report.
+	markdownMaxFiles = 20
+	// markdownMaxListItems is the max number of items rendered in each list before the files, such as the renamed files.
+	markdownMaxListItems = 20
+	// markdownMaxBytes is the max size of the markdown report,
+	// it's a bit less than 65536 which is the max length of a github pull request comment.
+	markdownMaxBytes = 60000
+	// uncoveredMarker is appended to the uncovered lines of the code snippets.
+	uncoveredMarker = "  // <- not covered"
+	// uncoveredHeading is the heading of the code snippets.
+	uncoveredHeading = "\n### Uncovered Lines\n\n"
+	// truncatedNote is appended when the report is cut at maxBytes.
+	truncatedNote = "\n_The report is truncated, please check the full report for details._\n"
+)
+
+// markdownReportGenerator implements a markdown style report generator,
+// the report is suitable to be posted as a pull request comment.
+type markdownReportGenerator struct {
+	// outputPath report path
+	outputPath string
+	// reportName report name
+	reportName string
+	// maxFiles max number of files rendered in the report
+	maxFiles int
+	// maxListItems max number of items rendered in each list before the files
+	maxListItems int
+	// maxBytes max size of the report
+	maxBytes int
+	// logger
+	logger logrus.FieldLogger
+}
+
+var _ ReportGenerator = (*markdownReportGenerator)(nil)
+
+func newMarkdownReportGenerator(outputPath string, reportName string, logger logrus.FieldLogger) ReportGenerator {
+	return &markdownReportGenerator{
+		outputPath:   outputPath,
+		reportName:   reportName,
+		maxFiles:     markdownMaxFiles,
+		maxListItems: markdownMaxListItems,
+		maxBytes:     markdownMaxBytes,
+		logger:       logger,
+	}
+}
+
+// GenerateReport renders the coverage statistics and writes it to the markdown report.
+func (g *markdownReportGenerator) GenerateReport(statistics *Statistics) error {
+	reportFile := filepath.Join(g.outputPath, fmt.Sprintf("%s.md", g.reportName))
+	if err := os.WriteFile(reportFile, []byte(g.render(statistics)), 0644); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	g.logger.Infof("generate markdown coverage report: %s", reportFile)
+	return nil
+}
+
+// render renders the statistics into markdown.
+// Files are sorted by coverage in ascending order, so the files that need attention come first,
+// when the report reaches maxFiles or maxBytes, the rest of files are omitted and summarized in the footer.
+// The lists before the files are cut at maxListItems, and the report is cut at maxBytes if they are too long still.
+func (g *markdownReportGenerator) render(statistics *Statistics) string {
+	var header strings.Builder
+
+	if isDiffCoverageReport(statistics.StatisticsType) {
+		fmt.Fprint(&header, "## Diff Coverage\n\n")
+		if statistics.ComparedBranch != "" {
+			headRevision := statistics.HeadRevision
+			if headRevision == "" {
+				headRevision = "HEAD"
+			}
+			fmt.Fprintf(&header, "Diff: `%s...%s`\n\n", statistics.ComparedBranch, headRevision)
+		}
+		if len(statistics.RenamedFiles) != 0 {
+			fmt.Fprint(&header, "<details><summary>Renamed files</summary>\n\n")
+			for _, f := range statistics.RenamedFiles[:g.listItems(len(statistics.RenamedFiles))] {
+				fmt.Fprintf(&header, "- `%s` → `%s`\n", f.From, f.To)
+			}
+			g.writeOmittedItems(&header, len(statistics.RenamedFiles), "renamed files")
+			fmt.Fprint(&header, "\n</details>\n\n")
+		}
+		if len(statistics.IndirectCoverageLoss) != 0 {
+			fmt.Fprint(&header, ":warning: **Indirect Coverage Loss**\n\n")
+			fmt.Fprint(&header, "Unchanged lines that are covered on the compared branch but not covered any more.\n\n")
+			fmt.Fprint(&header, "| Source File | Lines |\n")
+			fmt.Fprint(&header, "| --- | --- |\n")
+			for _, loss := range statistics.IndirectCoverageLoss[:g.listItems(len(statistics.IndirectCoverageLoss))] {
+				fmt.Fprintf(&header, "| %s | %s |\n", loss.FileName, intsJoin(loss.Lines))
+			}
+			g.writeOmittedItems(&header, len(statistics.IndirectCoverageLoss), "files")
+			fmt.Fprint(&header, "\n")
+		}
+	} else {
+		fmt.Fprint(&header, "## Full Coverage\n\n")
+	}
+
+	if selection := statistics.TestSelection; selection != nil {
+		fmt.Fprintf(&header, ":information_source: **Test selection applied**: only the tests of %d of %d packages that reach the changed packages are run, the coverage of the files out of the diff is not complete.\n\n",
+			len(selection.SelectedPackages), selection.TotalPackages)
+		if len(selection.SelectedPackages) != 0 {
+			fmt.Fprint(&header, "<details><summary>Selected packages</summary>\n\n")
+			for _, pkg := range selection.SelectedPackages[:g.listItems(len(selection.SelectedPackages))] {
+				fmt.Fprintf(&header, "- `%s`\n", pkg)
+			}
+			g.writeOmittedItems(&header, len(selection.SelectedPackages), "packages")
+			fmt.Fprint(&header, "\n</details>\n\n")
+		}
+	}
+
+	if statistics.TestResults != nil {
+		passed, failed, skipped := statistics.TestResults.Counts()
+		failedTests := statistics.TestResults.FailedTests()
+		status := ":white_check_mark:"
+		if len(failedTests) != 0 {
+			status = ":x:"
+		}
+		fmt.Fprintf(&header, "%s **Tests**: %d passed, %d failed, %d skipped\n\n", status, passed, failed, skipped)
+		if len(failedTests) != 0 {
+			fmt.Fprint(&header, "| Package | Failed Test | Elapsed (s) |\n")
+			fmt.Fprint(&header, "| --- | --- | --- |\n")
+			for _, test := range failedTests[:g.listItems(len(failedTests))] {
+				name := test.Name
+				if name == "" {
+					name = "(package)"
+				}
+				fmt.Fprintf(&header, "| %s | %s | %.2f |\n", test.Package, markdownEscape(name), test.Elapsed)
+			}
+			g.writeOmittedItems(&header, len(failedTests), "failed tests")
+			fmt.Fprint(&header, "\n")
+		}
+	}
+
+	if len(statistics.Modules) != 0 {
+		fmt.Fprint(&header, "| Module | Coverage (with ignorance) (%) | Covered Lines | Effective Lines |\n")
+		fmt.Fprint(&header, "| --- | --- | --- | --- |\n")
+		for _, m := range statistics.Modules[:g.listItems(len(statistics.Modules))] {
+			if m.Error != "" {
+				fmt.Fprintf(&header, "| %s | :x: %s | - | - |\n", m.Path, markdownEscape(m.Error))
+				continue
+			}
+			fmt.Fprintf(&header, "| %s | %.2f | %d | %d |\n", m.Path, m.CoveragePercent, m.TotalCoveredLines, m.TotalEffectiveLines)
+		}
+		g.writeOmittedItems(&header, len(statistics.Modules), "modules")
+		fmt.Fprint(&header, "\n")
+	}
+
+	if len(statistics.ThresholdViolations) != 0 {
+		fmt.Fprint(&header, ":x: **Threshold Violations**\n\n")
+		fmt.Fprint(&header, "| Path | Rule | Coverage (with ignorance) (%) | Threshold (%) |\n")
+		fmt.Fprint(&header, "| --- | --- | --- | --- |\n")
+		for _, v := range statistics.ThresholdViolations[:g.listItems(len(statistics.ThresholdViolations))] {
+			fmt.Fprintf(&header, "| %s | `%s` | %.2f | %.2f |\n", v.Path, v.Pattern, v.Coverage, v.Threshold)
+		}
+		g.writeOmittedItems(&header, len(statistics.ThresholdViolations), "violations")
+		fmt.Fprint(&header, "\n")
+	}
+
+	if len(statistics.CoverageProfile) == 0 {
+		fmt.Fprint(&header, "No lines with coverage information in this diff.\n")
+		return g.truncate(header.String())
+	}
+
+	if statistics.CoverageBaseline > 0 {
+		status := ":white_check_mark:"
+		if statistics.TotalCoveragePercent < statistics.CoverageBaseline {
+			status = ":x:"
+		}
+		fmt.Fprintf(&header, "%s **Coverage (with ignorance): %.2f%%** (baseline: %.2f%%)\n\n",
+			status, statistics.TotalCoveragePercent, statistics.CoverageBaseline)
+	} else {
+		fmt.Fprintf(&header, "**Coverage (with ignorance): %.2f%%**\n\n", statistics.TotalCoveragePercent)
+	}
+
+	fmt.Fprintf(&header, "Covered %s of %s effective, %s ignored, coverage without ignorance is %.2f%%.\n\n",
+		normalizeLines(statistics.TotalCoveredLines-statistics.TotalCoveredButIgnoredLines),
+		normalizeLines(statistics.TotalEffectiveLines),
+		normalizeLines(statistics.TotalIgnoredLines),
+		statistics.TotalCoverageWithoutIgnore,
+	)
+
+	fmt.Fprint(&header, "| Source File | Coverage (with ignorance) (%) | Coverage (%) | Covered Lines | Effective Lines | Total Lines | Missing Lines |\n")
+	fmt.Fprint(&header, "| --- | --- | --- | --- | --- | --- | --- |\n")
+
+	profiles := make([]*CoverageProfile, len(statistics.CoverageProfile))
+	copy(profiles, statistics.CoverageProfile)
+	sort.SliceStable(profiles, func(i, j int) bool {
+		pi := percentCovered(profiles[i].TotalEffectiveLines, profiles[i].CoveredLines, profiles[i].CoveredButIgnoredLines)
+		pj := percentCovered(profiles[j].TotalEffectiveLines, profiles[j].CoveredLines, profiles[j].CoveredButIgnoredLines)
+		if pi != pj {
+			return pi < pj
+		}
+		return profiles[i].FileName < profiles[j].FileName
+	})
+
+	// the parts after the files are counted in the size as well
+	trailer := len(markdownFooter(len(profiles))) + len(markdownExcludeFiles(len(statistics.ExcludeFiles)))
+
+	var rows, snippets strings.Builder
+	rendered := 0
+	for _, profile := range profiles {
+		row := markdownRow(profile)
+		snippet := markdownSnippet(profile)
+
+		size := header.Len() + rows.Len() + snippets.Len() + len(row) + len(snippet) + trailer
+		if snippets.Len() != 0 || snippet != "" {
+			size += len(uncoveredHeading)
+		}
+		if rendered >= g.maxFiles || size > g.maxBytes {
+			break
+		}
+
+		rows.WriteString(row)
+		snippets.WriteString(snippet)
+		rendered++
+	}
+
+	var b strings.Builder
+	b.WriteString(header.String())
+	b.WriteString(rows.String())
+	if snippets.Len() != 0 {
+		b.WriteString(uncoveredHeading)
+		b.WriteString(snippets.String())
+	}
+	if omitted := len(profiles) - rendered; omitted > 0 {
+		b.WriteString(markdownFooter(omitted))
+	}
+	b.WriteString(markdownExcludeFiles(len(statistics.ExcludeFiles)))
+
+	return g.truncate(b.String())
+}
+
+// listItems returns how many items of the list are rendered.
+func (g *markdownReportGenerator) listItems(total int) int {
+	if total > g.maxListItems {
+		return g.maxListItems
+	}
+	return total
+}
+
+// writeOmittedItems writes how many items of the list are not rendered.
+func (g *markdownReportGenerator) writeOmittedItems(b *strings.Builder, total int, what string) {
+	if omitted := total - g.listItems(total); omitted > 0 {
+		fmt.Fprintf(b, "\n_%d more %s are not shown, please check the full report for details._\n", omitted, what)
+	}
+}
+
+// truncate cuts the report at the last line that fits in maxBytes along with the note,
+// it only happens when the lists before the files are too long.
+func (g *markdownReportGenerator) truncate(report string) string {
+	if len(report) <= g.maxBytes {
+		return report
+	}
+	cut := report[:g.maxBytes-len(truncatedNote)]
+	if i := strings.LastIndex(cut, "\n"); i >= 0 {
+		cut = cut[:i+1]
+	}
+	return cut + truncatedNote
+}
+
+func markdownExcludeFiles(excluded int) string {
+	if excluded == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n%d files are excluded from coverage calculation.\n", excluded)
+}
+
+func markdownRow(profile *CoverageProfile) string {
+	var missing []int
+	for _, section := range profile.ViolationSections {
+		missing = append(missing, section.ViolationLines...)
+	}
+
+	return fmt.Sprintf("| %s | %.2f | %.2f | %d | %d | %d | %s |\n",
+		profile.FileName,
+		percentCovered(profile.TotalEffectiveLines, profile.CoveredLines, profile.CoveredButIgnoredLines),
+		percentCovered(profile.TotalLines, profile.CoveredLines, 0),
+		profile.CoveredLines,
+		profile.TotalEffectiveLines,
+		profile.TotalLines,
+		intsJoin(missing),
+	)
+}
+
+// markdownSnippet renders violation sections of the file as collapsible go code blocks,
+// and the uncovered lines are marked with a trailing comment.
+func markdownSnippet(profile *CoverageProfile) string {
+	if len(profile.ViolationSections) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<details><summary>%s</summary>\n\n", profile.FileName)
+	for _, section := range profile.ViolationSections {
+		violated := make(map[int]bool, len(section.ViolationLines))
+		for _, line := range section.ViolationLines {
+			violated[line] = true
+		}
+
+		var lines []string
+		for i, content := range section.Contents {
+			if violated[section.StartLine+i] {
+				content += uncoveredMarker
+			}
+			lines = append(lines, content)
+		}
+		code := strings.Join(lines, "\n")
+
+		// use a longer fence if the code contains the fence itself, for example, in a raw string.
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+
+		fmt.Fprintf(&b, "Lines %d-%d:\n\n%sgo\n%s\n%s\n\n", section.StartLine, section.EndLine, fence, code, fence)
+	}
+	b.WriteString("</details>\n\n")
+	return b.String()
+}
+
+func markdownFooter(omitted int) string {
+	return fmt.Sprintf("\n_%d more files are not shown, please check the full report for details._\n", omitted)
+}
+
+// markdownEscape makes the text safe in a cell of markdown table.
+func markdownEscape(text string) string {
+	return strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ").Replace(text)
+}
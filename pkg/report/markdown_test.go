@@ -0,0 +1,291 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownReportGenerator(t *testing.T) {
+	t.Run("no coverage profiles", func(t *testing.T) {
+		path := t.TempDir()
+		g := newMarkdownReportGenerator(path, "coverage", logrus.New())
+
+		err := g.GenerateReport(&Statistics{
+			ComparedBranch: "origin/master",
+			StatisticsType: DiffStatisticsType,
+		})
+		assert.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(path, "coverage.md"))
+		assert.NoError(t, err)
+
+		reportString := string(data)
+		assert.Contains(t, reportString, "## Diff Coverage")
+		assert.Contains(t, reportString, "origin/master...HEAD")
+		assert.Contains(t, reportString, "No lines with coverage information in this diff.")
+	})
+
+	t.Run("have diff coverage profiles", func(t *testing.T) {
+		path := t.TempDir()
+		g := newMarkdownReportGenerator(path, "coverage", logrus.New())
+
+		statistics := &Statistics{
+			StatisticsType:       DiffStatisticsType,
+			ComparedBranch:       "origin/master",
+			CoverageBaseline:     80,
+			TotalLines:           32,
+			TotalEffectiveLines:  30,
+			TotalIgnoredLines:    2,
+			TotalCoveredLines:    28,
+			TotalCoveragePercent: 70,
+			ExcludeFiles:         []string{"exclude.go"},
+			CoverageProfile: []*CoverageProfile{
+				{FileName: "foo.go", TotalLines: 20, TotalEffectiveLines: 20, CoveredLines: 20},
+				{
+					FileName:            "bar.go",
+					TotalLines:          12,
+					TotalEffectiveLines: 10,
+					TotalIgnoredLines:   2,
+					CoveredLines:        8,
+					ViolationSections: []*ViolationSection{
+						{ViolationLines: []int{9}, StartLine: 8, EndLine: 10, Contents: []string{"func bar() {", "\tzoo()", "}"}},
+					},
+				},
+			},
+		}
+
+		err := g.GenerateReport(statistics)
+		assert.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(path, "coverage.md"))
+		assert.NoError(t, err)
+
+		reportString := string(data)
+		assert.Contains(t, reportString, ":x: **Coverage (with ignorance): 70.00%** (baseline: 80.00%)")
+		assert.Contains(t, reportString, "| bar.go | 80.00 | 66.67 | 8 | 10 | 12 | 9 |")
+		assert.Contains(t, reportString, "| foo.go | 100.00 | 100.00 | 20 | 20 | 20 |  |")
+		assert.Less(t, strings.Index(reportString, "| bar.go"), strings.Index(reportString, "| foo.go"), "less covered file should come first")
+		assert.Contains(t, reportString, "```go\nfunc bar() {\n\tzoo()"+uncoveredMarker+"\n}\n```")
+		assert.Contains(t, reportString, "1 files are excluded")
+		assert.NotContains(t, reportString, "more files are not shown")
+	})
+
+	t.Run("pass the coverage baseline", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType:       FullStatisticsType,
+			CoverageBaseline:     80,
+			TotalCoveragePercent: 90,
+			CoverageProfile:      []*CoverageProfile{{FileName: "foo.go", TotalLines: 10, TotalEffectiveLines: 10, CoveredLines: 9}},
+		})
+		assert.Contains(t, reportString, "## Full Coverage")
+		assert.Contains(t, reportString, ":white_check_mark: **Coverage (with ignorance): 90.00%** (baseline: 80.00%)")
+	})
+
+	t.Run("revision range", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType: DiffStatisticsType,
+			ComparedBranch: "v1.0.0",
+			HeadRevision:   "v1.1.0",
+		})
+		assert.Contains(t, reportString, "Diff: `v1.0.0...v1.1.0`")
+	})
+
+	t.Run("renamed files", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType: DiffStatisticsType,
+			ComparedBranch: "origin/master",
+			RenamedFiles:   []*RenamedFile{{From: "pkg/foo/foo.go", To: "pkg/bar/foo.go"}},
+		})
+		assert.Contains(t, reportString, "<details><summary>Renamed files</summary>")
+		assert.Contains(t, reportString, "- `pkg/foo/foo.go` → `pkg/bar/foo.go`")
+	})
+
+	t.Run("indirect coverage loss", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType:       DiffStatisticsType,
+			ComparedBranch:       "origin/master",
+			IndirectCoverageLoss: []*IndirectCoverageLoss{{FileName: "github.com/Azure/gocover/pkg/foo/foo.go", Lines: []int{3, 6}}},
+		})
+		assert.Contains(t, reportString, ":warning: **Indirect Coverage Loss**")
+		assert.Contains(t, reportString, "| github.com/Azure/gocover/pkg/foo/foo.go | 3,6 |")
+	})
+
+	t.Run("threshold violations", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType:      FullStatisticsType,
+			CoverageProfile:     []*CoverageProfile{{FileName: "foo.go", TotalLines: 10, TotalEffectiveLines: 10, CoveredLines: 5}},
+			ThresholdViolations: []*ThresholdViolation{{Path: "github.com/Azure/gocover/pkg/foo", Pattern: "**/pkg/foo", Threshold: 90, Coverage: 50}},
+		})
+		assert.Contains(t, reportString, ":x: **Threshold Violations**")
+		assert.Contains(t, reportString, "| github.com/Azure/gocover/pkg/foo | `**/pkg/foo` | 50.00 | 90.00 |")
+	})
+
+	t.Run("threshold violations without coverage profile", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType:      DiffStatisticsType,
+			ThresholdViolations: []*ThresholdViolation{{Path: "github.com/Azure/gocover/pkg/foo", Pattern: "**/pkg/foo", Threshold: 90, Coverage: 50}},
+		})
+		assert.Contains(t, reportString, "No lines with coverage information in this diff.")
+		assert.Contains(t, reportString, "| github.com/Azure/gocover/pkg/foo | `**/pkg/foo` | 50.00 | 90.00 |")
+	})
+
+	t.Run("modules", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType: FullStatisticsType,
+			Modules: []*ModuleCoverage{
+				{Path: "example.com/foo", TotalEffectiveLines: 4, TotalCoveredLines: 3, CoveragePercent: 75},
+				{Path: "example.com/bar", Error: "unit test failed"},
+			},
+		})
+		assert.Contains(t, reportString, "| example.com/foo | 75.00 | 3 | 4 |")
+		assert.Contains(t, reportString, "| example.com/bar | :x: unit test failed | - | - |")
+	})
+
+	t.Run("test selection", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType: DiffStatisticsType,
+			TestSelection: &TestSelection{
+				ChangedPackages:  []string{"example.com/foo"},
+				SelectedPackages: []string{"example.com/bar", "example.com/foo"},
+				TotalPackages:    5,
+			},
+		})
+		assert.Contains(t, reportString, "**Test selection applied**: only the tests of 2 of 5 packages")
+		assert.Contains(t, reportString, "- `example.com/bar`\n- `example.com/foo`\n")
+	})
+
+	t.Run("failed tests", func(t *testing.T) {
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(&Statistics{
+			StatisticsType: FullStatisticsType,
+			TestResults: &TestResults{Packages: []*PackageTestResult{
+				{Package: "example.com/foo", Result: TestFail, Elapsed: 1.5, Tests: []*TestResult{
+					{Name: "TestFoo", Result: TestPass},
+					{Name: "TestBar|Sub", Result: TestFail, Elapsed: 0.5},
+					{Name: "TestZoo", Result: TestSkip},
+				}},
+				{Package: "example.com/bar", Result: TestFail, Elapsed: 0.1, Output: "build failed"},
+			}},
+		})
+		assert.Contains(t, reportString, ":x: **Tests**: 1 passed, 1 failed, 1 skipped")
+		assert.Contains(t, reportString, "| example.com/foo | TestBar\\|Sub | 0.50 |")
+		assert.Contains(t, reportString, "| example.com/bar | (package) | 0.10 |")
+	})
+
+	t.Run("truncate by max files", func(t *testing.T) {
+		statistics := &Statistics{StatisticsType: FullStatisticsType}
+		for i := 0; i < 5; i++ {
+			statistics.CoverageProfile = append(statistics.CoverageProfile, &CoverageProfile{
+				FileName: fmt.Sprintf("file%d.go", i), TotalLines: 10, TotalEffectiveLines: 10, CoveredLines: i,
+			})
+		}
+
+		g := &markdownReportGenerator{maxFiles: 2, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		reportString := g.render(statistics)
+		assert.Contains(t, reportString, "file0.go")
+		assert.Contains(t, reportString, "file1.go")
+		assert.NotContains(t, reportString, "file2.go")
+		assert.Contains(t, reportString, "3 more files are not shown")
+	})
+
+	t.Run("truncate by max bytes", func(t *testing.T) {
+		statistics := &Statistics{StatisticsType: FullStatisticsType}
+		for i := 0; i < 5; i++ {
+			statistics.CoverageProfile = append(statistics.CoverageProfile, &CoverageProfile{
+				FileName: fmt.Sprintf("file%d.go", i), TotalLines: 10, TotalEffectiveLines: 10, CoveredLines: 5,
+				ViolationSections: []*ViolationSection{
+					{ViolationLines: []int{1}, StartLine: 1, EndLine: 1, Contents: []string{strings.Repeat("a", 500)}},
+				},
+			})
+		}
+
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: 2000}
+		reportString := g.render(statistics)
+		assert.LessOrEqual(t, len(reportString), 2000)
+		assert.Contains(t, reportString, "file0.go")
+		assert.NotContains(t, reportString, "file4.go")
+		assert.Contains(t, reportString, "more files are not shown")
+	})
+
+	t.Run("count uncovered lines heading and excluded files", func(t *testing.T) {
+		statistics := &Statistics{StatisticsType: FullStatisticsType, ExcludeFiles: []string{"zz_generated.go"}}
+		for i := 0; i < 3; i++ {
+			statistics.CoverageProfile = append(statistics.CoverageProfile, &CoverageProfile{
+				FileName: fmt.Sprintf("file%d.go", i), TotalLines: 10, TotalEffectiveLines: 10, CoveredLines: 5,
+				ViolationSections: []*ViolationSection{
+					{ViolationLines: []int{1}, StartLine: 1, EndLine: 1, Contents: []string{strings.Repeat("a", 500)}},
+				},
+			})
+		}
+
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: markdownMaxBytes}
+		full := g.render(statistics)
+		for maxBytes := len(full) - 1200; maxBytes <= len(full); maxBytes++ {
+			g.maxBytes = maxBytes
+			reportString := g.render(statistics)
+			if len(reportString) > maxBytes {
+				t.Fatalf("the report should not exceed %d bytes, but get %d bytes", maxBytes, len(reportString))
+			}
+			assert.Contains(t, reportString, "1 files are excluded from coverage calculation.")
+		}
+	})
+
+	t.Run("cut lists before files", func(t *testing.T) {
+		statistics := &Statistics{StatisticsType: DiffStatisticsType, ComparedBranch: "origin/master"}
+		for i := 0; i < 30; i++ {
+			statistics.RenamedFiles = append(statistics.RenamedFiles, &RenamedFile{From: fmt.Sprintf("old%d.go", i), To: fmt.Sprintf("new%d.go", i)})
+			statistics.IndirectCoverageLoss = append(statistics.IndirectCoverageLoss, &IndirectCoverageLoss{FileName: fmt.Sprintf("loss%d.go", i), Lines: []int{1}})
+		}
+
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: 10, maxBytes: markdownMaxBytes}
+		reportString := g.render(statistics)
+		assert.Contains(t, reportString, "- `old9.go` → `new9.go`")
+		assert.NotContains(t, reportString, "old10.go")
+		assert.Contains(t, reportString, "_20 more renamed files are not shown")
+		assert.Contains(t, reportString, "| loss9.go | 1 |")
+		assert.NotContains(t, reportString, "loss10.go")
+		assert.Contains(t, reportString, "_20 more files are not shown")
+	})
+
+	t.Run("truncate long lists by max bytes", func(t *testing.T) {
+		statistics := &Statistics{StatisticsType: DiffStatisticsType, ComparedBranch: "origin/master"}
+		for i := 0; i < 30; i++ {
+			statistics.RenamedFiles = append(statistics.RenamedFiles, &RenamedFile{From: strings.Repeat("a", 100), To: fmt.Sprintf("new%d.go", i)})
+		}
+
+		g := &markdownReportGenerator{maxFiles: markdownMaxFiles, maxListItems: markdownMaxListItems, maxBytes: 1000}
+		reportString := g.render(statistics)
+		assert.LessOrEqual(t, len(reportString), 1000)
+		assert.True(t, strings.HasSuffix(reportString, truncatedNote))
+	})
+}
+
+func TestMarkdownSnippet(t *testing.T) {
+	t.Run("use longer fence when code contains fence", func(t *testing.T) {
+		snippet := markdownSnippet(&CoverageProfile{
+			FileName: "foo.go",
+			ViolationSections: []*ViolationSection{
+				{ViolationLines: []int{2}, StartLine: 1, EndLine: 2, Contents: []string{"s := `", "```"}},
+			},
+		})
+		assert.Contains(t, snippet, "````go\n")
+	})
+
+	t.Run("no violation sections", func(t *testing.T) {
+		assert.Empty(t, markdownSnippet(&CoverageProfile{FileName: "foo.go"}))
+	})
+}
@@ -0,0 +1,237 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sarifVersion        = "2.1.0"
+	sarifSchema         = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifSourceRoot     = "%SRCROOT%"
+	sarifUncoveredRule  = "gocover/uncovered-statement"
+	sarifToolName       = "gocover"
+	sarifToolInfoURI    = "https://github.com/Azure/gocover"
+	sarifLevelWarning   = "warning"
+	sarifSuppressInCode = "inSource"
+)
+
+// sarifLog is the root object of the SARIF log, only the properties used by gocover are defined,
+// the full definition can be found at https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+type sarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               *sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]*sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []*sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver *sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                  `json:"id"`
+	ShortDescription     *sarifMessage           `json:"shortDescription"`
+	DefaultConfiguration *sarifRuleConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifRuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string              `json:"ruleId"`
+	RuleIndex    int                 `json:"ruleIndex"`
+	Level        string              `json:"level"`
+	Message      *sarifMessage       `json:"message"`
+	Locations    []*sarifLocation    `json:"locations"`
+	Suppressions []*sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation *sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion           `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification"`
+}
+
+// sarifReportGenerator implements a SARIF 2.1.0 log generator, so that code scanning tools
+// can show the uncovered statements inline on the pull request.
+type sarifReportGenerator struct {
+	// outputPath report path
+	outputPath string
+	// reportName report name
+	reportName string
+	// logger
+	logger logrus.FieldLogger
+}
+
+var _ ReportGenerator = (*sarifReportGenerator)(nil)
+
+func newSARIFReportGenerator(outputPath string, reportName string, logger logrus.FieldLogger) ReportGenerator {
+	return &sarifReportGenerator{
+		outputPath: outputPath,
+		reportName: reportName,
+		logger:     logger,
+	}
+}
+
+// GenerateReport converts the coverage statistics to SARIF log and writes it to the report.
+func (g *sarifReportGenerator) GenerateReport(statistics *Statistics) error {
+	reportFile := filepath.Join(g.outputPath, fmt.Sprintf("%s.sarif", g.reportName))
+	f, err := os.Create(reportFile)
+	if err != nil {
+		return fmt.Errorf("create report file: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(buildSARIFLog(statistics)); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+
+	g.logger.Infof("generate sarif coverage report: %s", reportFile)
+	return nil
+}
+
+// buildSARIFLog converts each uncovered statement to a result, and each ignored section to a suppressed result
+// that carries the annotation comments as the justification.
+// For diff coverage, the coverage profiles only contain the changed statements,
+// so the results only contain the uncovered changed statements.
+func buildSARIFLog(statistics *Statistics) *sarifLog {
+	run := &sarifRun{
+		Tool: &sarifTool{
+			Driver: &sarifDriver{
+				Name:           sarifToolName,
+				InformationURI: sarifToolInfoURI,
+				Rules: []*sarifRule{
+					{
+						ID:                   sarifUncoveredRule,
+						ShortDescription:     &sarifMessage{Text: "Statement is not covered by tests."},
+						DefaultConfiguration: &sarifRuleConfiguration{Level: sarifLevelWarning},
+					},
+				},
+			},
+		},
+		Results: make([]*sarifResult, 0),
+	}
+	if statistics.RepositoryPath != "" {
+		run.OriginalURIBaseIDs = map[string]*sarifArtifactLocation{
+			// the uri base must end with slash, otherwise the last path segment is dropped when resolving.
+			sarifSourceRoot: {URI: strings.TrimSuffix(fileURI(statistics.RepositoryPath), "/") + "/"},
+		}
+	}
+
+	message := "Statement is not covered by tests."
+	if isDiffCoverageReport(statistics.StatisticsType) {
+		message = "Changed statement is not covered by tests."
+	}
+
+	for _, profile := range statistics.CoverageProfile {
+		artifact := sarifArtifact(statistics.RepositoryPath, profile.SourceFile)
+
+		for _, fun := range profile.Functions {
+			for _, st := range fun.Statements {
+				if st.Ignored || st.Reached > 0 {
+					continue
+				}
+				run.Results = append(run.Results, &sarifResult{
+					RuleID:  sarifUncoveredRule,
+					Level:   sarifLevelWarning,
+					Message: &sarifMessage{Text: message},
+					Locations: []*sarifLocation{
+						{
+							PhysicalLocation: &sarifPhysicalLocation{
+								ArtifactLocation: artifact,
+								Region:           &sarifRegion{StartLine: st.StartLine, EndLine: st.EndLine},
+							},
+						},
+					},
+				})
+			}
+		}
+
+		for _, section := range profile.IgnoredSections {
+			location := &sarifPhysicalLocation{ArtifactLocation: artifact}
+			text := "File is ignored by annotation."
+			if section.StartLine != 0 {
+				location.Region = &sarifRegion{StartLine: section.StartLine, EndLine: section.EndLine}
+				text = fmt.Sprintf("Lines %d-%d are ignored by annotation at line %d.", section.StartLine, section.EndLine, section.AnnotationLine)
+			}
+			run.Results = append(run.Results, &sarifResult{
+				RuleID:    sarifUncoveredRule,
+				Level:     sarifLevelWarning,
+				Message:   &sarifMessage{Text: text},
+				Locations: []*sarifLocation{{PhysicalLocation: location}},
+				Suppressions: []*sarifSuppression{
+					{Kind: sarifSuppressInCode, Justification: section.Comments},
+				},
+			})
+		}
+	}
+
+	return &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []*sarifRun{run},
+	}
+}
+
+// sarifArtifact returns the location of the file relative to the repository,
+// it falls back to the absolute path when the file is not in the repository.
+func sarifArtifact(repositoryPath, sourceFile string) *sarifArtifactLocation {
+	if repositoryPath != "" {
+		if rel, err := filepath.Rel(repositoryPath, sourceFile); err == nil && !strings.HasPrefix(rel, "..") {
+			return &sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: sarifSourceRoot}
+		}
+	}
+	return &sarifArtifactLocation{URI: fileURI(sourceFile)}
+}
+
+// fileURI converts the absolute path to a file uri.
+func fileURI(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // windows path, such as C:/foo
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
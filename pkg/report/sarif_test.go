@@ -0,0 +1,83 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSARIFLog(t *testing.T) {
+	t.Run("buildSARIFLog", func(t *testing.T) {
+		statistics := coberturaStatistics()
+		statistics.CoverageProfile[0].IgnoredSections = []*IgnoredSection{
+			{Annotation: "//+gocover:ignore:block", Comments: "unreachable", AnnotationLine: 5, StartLine: 6, EndLine: 6},
+		}
+		statistics.CoverageProfile[1].IgnoredSections = []*IgnoredSection{
+			{Annotation: "//+gocover:ignore:file", Comments: "generated code"},
+		}
+
+		log := buildSARIFLog(statistics)
+		assert.Equal(t, "2.1.0", log.Version)
+		assert.Len(t, log.Runs, 1)
+
+		run := log.Runs[0]
+		assert.Equal(t, "file:///home/user/gocover/", run.OriginalURIBaseIDs["%SRCROOT%"].URI)
+		assert.Len(t, run.Results, 4)
+
+		uncovered := run.Results[0]
+		assert.Equal(t, sarifUncoveredRule, uncovered.RuleID)
+		assert.Equal(t, "Statement is not covered by tests.", uncovered.Message.Text)
+		assert.Equal(t, &sarifArtifactLocation{URI: "pkg/foo/foo.go", URIBaseID: "%SRCROOT%"}, uncovered.Locations[0].PhysicalLocation.ArtifactLocation)
+		assert.Equal(t, &sarifRegion{StartLine: 5, EndLine: 5}, uncovered.Locations[0].PhysicalLocation.Region)
+		assert.Empty(t, uncovered.Suppressions)
+
+		assert.Equal(t, &sarifRegion{StartLine: 11, EndLine: 11}, run.Results[1].Locations[0].PhysicalLocation.Region)
+
+		block := run.Results[2]
+		assert.Equal(t, &sarifRegion{StartLine: 6, EndLine: 6}, block.Locations[0].PhysicalLocation.Region)
+		assert.Equal(t, []*sarifSuppression{{Kind: "inSource", Justification: "unreachable"}}, block.Suppressions)
+
+		file := run.Results[3]
+		assert.Equal(t, "pkg/bar/bar.go", file.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+		assert.Nil(t, file.Locations[0].PhysicalLocation.Region)
+		assert.Equal(t, []*sarifSuppression{{Kind: "inSource", Justification: "generated code"}}, file.Suppressions)
+	})
+
+	t.Run("diff coverage", func(t *testing.T) {
+		statistics := coberturaStatistics()
+		statistics.StatisticsType = DiffStatisticsType
+
+		log := buildSARIFLog(statistics)
+		assert.Len(t, log.Runs[0].Results, 2)
+		assert.Equal(t, "Changed statement is not covered by tests.", log.Runs[0].Results[0].Message.Text)
+	})
+
+	t.Run("file outside of repository", func(t *testing.T) {
+		assert.Equal(t, &sarifArtifactLocation{URI: "file:///tmp/foo.go"}, sarifArtifact("/home/user/gocover", "/tmp/foo.go"))
+	})
+}
+
+func TestSARIFReportGenerator(t *testing.T) {
+	t.Run("GenerateReport", func(t *testing.T) {
+		dir := t.TempDir()
+		g := newSARIFReportGenerator(dir, "coverage", logrus.New())
+		if err := g.GenerateReport(coberturaStatistics()); err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+
+		bs, err := os.ReadFile(filepath.Join(dir, "coverage.sarif"))
+		if err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+
+		var log map[string]interface{}
+		if err := json.Unmarshal(bs, &log); err != nil {
+			t.Fatalf("should not error, but get: %s", err)
+		}
+		assert.Equal(t, "https://json.schemastore.org/sarif-2.1.0.json", log["$schema"])
+	})
+}
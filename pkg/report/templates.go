@@ -49,7 +49,84 @@ var htmlCoverageReport = "" +
 
     {{ if IsDiffCoverageReport .StatisticsType }}
         <h1>Diff Coverage</h1>
-        <p>Diff: {{ .ComparedBranch }}...HEAD</p>
+        {{ if .ComparedBranch }}
+        <p>Diff: {{ .ComparedBranch }}...{{ if .HeadRevision }}{{ .HeadRevision }}{{ else }}HEAD{{ end }}</p>
+        {{ end }}
+        {{ if .RenamedFiles }}
+        <p><b>Renamed files</b>:</p>
+        <ul>
+            {{ range .RenamedFiles }}
+            <li>{{ .From }} &rarr; {{ .To }}</li>
+            {{ end }}
+        </ul>
+        {{ end }}
+        {{ if .IndirectCoverageLoss }}
+        <p><b>Indirect Coverage Loss</b>: unchanged lines that are covered on the compared branch but not covered any more.</p>
+        <ul>
+            {{ range .IndirectCoverageLoss }}
+            <li>{{ .FileName }}: {{ IntsJoin .Lines }}</li>
+            {{ end }}
+        </ul>
+        {{ end }}
+    {{ end }}
+
+    {{ with .TestSelection }}
+        <p><b>Test Selection Applied</b>: only the tests of {{ len .SelectedPackages }} of {{ .TotalPackages }} packages that reach the changed packages are run, the coverage of the files out of the diff is not complete.</p>
+        <ul>
+            {{ range .SelectedPackages }}
+            <li>{{ . }}</li>
+            {{ end }}
+        </ul>
+    {{ end }}
+
+    {{ if .TestResults }}
+        {{ with .TestResults.FailedTests }}
+        <p><b>Failed Tests</b>:</p>
+        <ul>
+            {{ range . }}
+            <li>{{ .Package }} {{ if .Name }}{{ .Name }}{{ else }}(package){{ end }}
+                <pre>{{ .Output }}</pre>
+            </li>
+            {{ end }}
+        </ul>
+        {{ end }}
+    {{ end }}
+
+    {{ if .Modules }}
+        <p><b>Modules</b>:</p>
+        <ul>
+            {{ range .Modules }}
+            {{ if .Error }}
+            <li>{{ .Path }}: failed, {{ .Error }}</li>
+            {{ else }}
+            <li>{{ .Path }}: {{ .CoveragePercent }}% ({{ .TotalCoveredLines }} of {{ .TotalEffectiveLines }} covered)</li>
+            {{ end }}
+            {{ end }}
+        </ul>
+    {{ end }}
+
+    {{ if .ThresholdViolations }}
+        <p><b>Threshold Violations</b>:</p>
+        <table border="1" class="threshold-violations">
+            <thead>
+                <tr>
+                    <th>Path</th>
+                    <th>Rule</th>
+                    <th>Coverage (with ignorance) (%)</th>
+                    <th>Threshold (%)</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{ range .ThresholdViolations }}
+                <tr>
+                    <td>{{ .Path }}</td>
+                    <td>{{ .Pattern }}</td>
+                    <td>{{ printf "%.2f" .Coverage }}</td>
+                    <td>{{ printf "%.2f" .Threshold }}</td>
+                </tr>
+                {{ end }}
+            </tbody>
+        </table>
     {{ end }}
 
     {{ if .CoverageProfile }}
@@ -117,14 +194,35 @@ var htmlCoverageReport = "" +
 
         {{ range .CoverageProfile }}
             <div class="src-snippet">
-                {{ if lt (PercentCovered .TotalEffectiveLines .CoveredLines .CoveredButIgnoredLines) 100.0 }}
+                {{ $violated := lt (PercentCovered .TotalEffectiveLines .CoveredLines .CoveredButIgnoredLines) 100.0 }}
+                {{ if or $violated .CoveringTests }}
                 <div class="src-name" id="{{.FileName}}">{{ .FileName }}</div>
+                {{ end }}
+                {{ if $violated }}
                 <div class="snippets">
                     {{range .CodeSnippet}}
                     {{ . }}
                     {{ end }}
                 </div>
                 {{ end }}
+                {{ if .CoveringTests }}
+                <table border="1" class="covering-tests">
+                    <thead>
+                        <tr>
+                            <th>Lines</th>
+                            <th>Covered By</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        {{ range .CoveringTests }}
+                        <tr>
+                            <td>{{ if eq .StartLine .EndLine }}{{ .StartLine }}{{ else }}{{ .StartLine }}-{{ .EndLine }}{{ end }}</td>
+                            <td>{{ range $i, $test := .Tests }}{{ if $i }}<br />{{ end }}{{ $test }}{{ end }}</td>
+                        </tr>
+                        {{ end }}
+                    </tbody>
+                </table>
+                {{ end }}
             </div>
         {{ end }}
 
@@ -13,6 +13,10 @@ type CoverageTree interface {
 	// FindOrCreate returns the leaf node that represents the source file (go) if found,
 	// otherwise, it will creates the all the nodes along the path to the leaf, and finally return it.
 	FindOrCreate(file string) *TreeNode
+	// FindOrCreateInModule is like FindOrCreate, but the file is relative to the module of modulePath.
+	// When the module is not the module of the tree, such as a module of go.work workspace, the module is
+	// a direct sub node of the root named with module path, so the nested modules don't count in their parent modules.
+	FindOrCreateInModule(modulePath string, file string) *TreeNode
 	Find(pkgPath string) *TreeNode
 	CollectCoverageData()
 	All() []*AllInformation
@@ -69,6 +73,7 @@ type AllInformation struct {
 	TotalCoveredLines          int64
 	TotalViolationLines        int64
 	TotalCoveredButIgnoreLines int64
+	IsLeaf                     bool // whether the path is a source file or a directory
 }
 
 func (p *coverageTree) Statistics() *AllInformation {
@@ -105,6 +110,7 @@ func (p *coverageTree) All() []*AllInformation {
 			TotalCoveredLines:          root.TotalCoveredLines,
 			TotalViolationLines:        root.TotalViolationLines,
 			TotalCoveredButIgnoreLines: root.TotalCoveredButIgnoreLines,
+			IsLeaf:                     root.isLeaf,
 		})
 
 		for _, v := range root.Nodes {
@@ -133,11 +139,27 @@ func (p *coverageTree) Find(pkgPath string) *TreeNode {
 }
 
 func (p *coverageTree) FindOrCreate(file string) *TreeNode {
-	trimed := strings.TrimPrefix(file, p.ModuleHostPath)
-	dir, f := filepath.Split(trimed)
+	return findOrCreate(p.Root, strings.TrimPrefix(file, p.ModuleHostPath))
+}
+
+func (p *coverageTree) FindOrCreateInModule(modulePath string, file string) *TreeNode {
+	if modulePath == p.ModuleHostPath {
+		return p.FindOrCreate(file)
+	}
+
+	moduleNode, ok := p.Root.Nodes[modulePath]
+	if !ok {
+		moduleNode = NewTreeNode(modulePath, false)
+		p.Root.Nodes[modulePath] = moduleNode
+	}
+	return findOrCreate(moduleNode, file)
+}
+
+func findOrCreate(root *TreeNode, file string) *TreeNode {
+	dir, f := filepath.Split(file)
 	tokens := strings.Split(strings.Trim(dir, seperator), seperator)
 
-	currentNode := p.Root
+	currentNode := root
 	for _, name := range tokens {
 		if node, ok := currentNode.Nodes[name]; ok {
 			currentNode = node
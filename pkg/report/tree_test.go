@@ -137,6 +137,45 @@ func TestCoverageTree(t *testing.T) {
 		}
 	})
 
+	t.Run("FindOrCreateInModule", func(t *testing.T) {
+		coverageTree := NewCoverageTree("")
+		node := coverageTree.FindOrCreateInModule("github.com/Azure/gocover", "pkg/util/bar.go")
+		if node.Name != "bar.go" {
+			t.Errorf("expect name of leaf node bar.go, but get %s", node.Name)
+		}
+		node.TotalLines = 10
+		node.TotalEffectiveLines = 10
+		node.TotalCoveredLines = 5
+		nested := coverageTree.FindOrCreateInModule("github.com/Azure/gocover/nested", "bar.go")
+		nested.TotalLines = 4
+		nested.TotalEffectiveLines = 4
+		nested.TotalCoveredLines = 4
+		coverageTree.CollectCoverageData()
+
+		modules := make(map[string]int64)
+		for _, info := range coverageTree.All() {
+			modules[info.Path] = info.TotalCoveredLines
+		}
+		// the nested module is not counted in its parent module
+		if modules["github.com/Azure/gocover"] != 5 {
+			t.Errorf("expect 5 covered lines of module, but get %d", modules["github.com/Azure/gocover"])
+		}
+		if modules["github.com/Azure/gocover/nested"] != 4 {
+			t.Errorf("expect 4 covered lines of nested module, but get %d", modules["github.com/Azure/gocover/nested"])
+		}
+		if modules["github.com/Azure/gocover/pkg/util/bar.go"] != 5 {
+			t.Errorf("expect 5 covered lines of file, but get %d", modules["github.com/Azure/gocover/pkg/util/bar.go"])
+		}
+		if coverageTree.Statistics().TotalCoveredLines != 9 {
+			t.Errorf("expect 9 covered lines of workspace, but get %d", coverageTree.Statistics().TotalCoveredLines)
+		}
+
+		moduleTree := NewCoverageTree("github.com/Azure/gocover")
+		if moduleTree.FindOrCreateInModule("github.com/Azure/gocover", "pkg/util/bar.go") != moduleTree.FindOrCreate("pkg/util/bar.go") {
+			t.Errorf("should same node for the module of tree")
+		}
+	})
+
 	// TODO: handle empty string
 	t.Run("FindOrCreate", func(t *testing.T) {
 		coverageTree := NewCoverageTree("github.com/Azure/gocover")
@@ -192,6 +231,15 @@ func TestCoverageTree(t *testing.T) {
 		if len(all) != 8 {
 			t.Errorf("should have 8 items, but get %d", len(all))
 		}
+		var leaves int
+		for _, info := range all {
+			if info.IsLeaf {
+				leaves++
+			}
+		}
+		if leaves != 4 {
+			t.Errorf("should have 4 leaves, but get %d", leaves)
+		}
 	})
 
 	t.Run("Find", func(t *testing.T) {
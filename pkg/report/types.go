@@ -16,6 +16,10 @@ const (
 type Statistics struct {
 	// ComparedBranch the branch that diff compared with.
 	ComparedBranch string
+	// HeadRevision the revision that contains the changes, HEAD if it's empty.
+	HeadRevision string
+	// CoverageBaseline is the expected coverage percent, zero means no baseline.
+	CoverageBaseline float64
 	// TotalLines represents the total lines that count for coverage.
 	TotalLines int
 	// TotalEffectiveLines indicates effective lines for the coverage profile.
@@ -38,12 +42,82 @@ type Statistics struct {
 	StatisticsType StatisticsType
 	// exclude files that won't take participate to coverage calculation.
 	ExcludeFiles []string
+	// RepositoryPath is the absolute path of the git repository.
+	RepositoryPath string
+	// ThresholdViolations are the packages and files that violate their threshold rules.
+	ThresholdViolations []*ThresholdViolation
+	// RenamedFiles are the go files renamed or moved in the diff.
+	RenamedFiles []*RenamedFile
+	// IndirectCoverageLoss are the files that some lines unchanged in the diff are not covered any more.
+	IndirectCoverageLoss []*IndirectCoverageLoss
+	// Modules are the coverage of each module when the report covers several modules of a workspace.
+	Modules []*ModuleCoverage
+	// TestResults are the results of the tests run by gocover test, nil if the tests are not run by gocover.
+	TestResults *TestResults
+	// TestSelection is how the tests are selected by the changes, nil if all the tests are run.
+	TestSelection *TestSelection
+}
+
+// TestSelection represents the test packages selected by the changes of diff coverage,
+// only the tests that reach the changed packages are run, so the coverage of other packages is not complete.
+type TestSelection struct {
+	// ChangedPackages are the import paths of the packages that contain changed files.
+	ChangedPackages []string
+	// SelectedPackages are the import paths of the packages whose tests are run.
+	SelectedPackages []string
+	// TotalPackages is the number of the packages that have tests.
+	TotalPackages int
+}
+
+// ModuleCoverage represents the coverage of a module in the workspace.
+type ModuleCoverage struct {
+	// Path is the module path.
+	Path string
+	// TotalEffectiveLines indicates effective lines of the module.
+	TotalEffectiveLines int64
+	// TotalCoveredLines indicates covered lines of the module, the lines covered but ignored are not counted.
+	TotalCoveredLines int64
+	// CoveragePercent is the coverage percent (with ignorance) of the module.
+	CoveragePercent float64
+	// Error is the failure of running the tests of the module, the coverage of the module is missing if it's not empty.
+	Error string
+}
+
+// IndirectCoverageLoss represents the unchanged lines of a file, which are covered on the compared branch
+// but not covered on HEAD, for example, because a test is deleted or the call path is changed.
+type IndirectCoverageLoss struct {
+	// FileName is the file path prefixed with module path.
+	FileName string
+	// Lines are the start lines of the statements that are not covered any more.
+	Lines []int
+}
+
+// RenamedFile represents a file that is renamed or moved, only the lines edited after the renaming count for diff coverage.
+type RenamedFile struct {
+	// From is the file path relative to the repository before renaming.
+	From string
+	// To is the file path relative to the repository after renaming.
+	To string
+}
+
+// ThresholdViolation represents a package or file whose coverage is lower than the threshold of its rule.
+type ThresholdViolation struct {
+	// Path is the package or file path prefixed with module path.
+	Path string
+	// Pattern is the pattern of the rule that matches the path.
+	Pattern string
+	// Threshold is the expected coverage percent of the rule.
+	Threshold float64
+	// Coverage is the coverage percent (with ignorance) of the path.
+	Coverage float64
 }
 
 // CoverageProfile represents the test coverage information for a file.
 type CoverageProfile struct {
 	// FileName indicates which file belongs to this coverage profile.
 	FileName string
+	// SourceFile is the absolute path of the file.
+	SourceFile string
 	// TotalLines indicates total lines of the entire repo/module.
 	TotalLines int
 	// TotalEffectiveLines indicates effective lines for the coverage profile.
@@ -60,6 +134,46 @@ type CoverageProfile struct {
 	ViolationSections []*ViolationSection
 	// CodeSnippet represents the output of the ViolationSections, it's calculated from ViolationSections.
 	CodeSnippet []template.HTML
+	// Functions indicates the functions that count for coverage and their statements.
+	Functions []*FunctionProfile
+	// IgnoredSections indicates the sections ignored by annotation that contain statements of Functions.
+	IgnoredSections []*IgnoredSection
+	// CoveringTests indicates the tests that cover the statements of Functions, only available with coverage attribution.
+	CoveringTests []*CoveringTests
+}
+
+// CoveringTests represents the tests that cover the statements among [StartLine, EndLine].
+type CoveringTests struct {
+	// StartLine indicates the start line of the first statement.
+	StartLine int
+	// EndLine indicates the end line of the last statement.
+	EndLine int
+	// Tests are the names of the tests, or the packages if the tests of each package are attributed as a whole.
+	Tests []string
+}
+
+// FunctionProfile represents the test coverage information for a function.
+type FunctionProfile struct {
+	// Name is the name of the function, it's in the form of T.N if the function has a receiver.
+	Name string
+	// StartLine indicates the start line of the function.
+	StartLine int
+	// EndLine indicates the end line of the function.
+	EndLine int
+	// Statements indicates the statements that count for coverage.
+	Statements []*StatementProfile
+}
+
+// StatementProfile represents the test coverage information for a statement.
+type StatementProfile struct {
+	// StartLine indicates the start line of the statement.
+	StartLine int
+	// EndLine indicates the end line of the statement.
+	EndLine int
+	// Reached indicates the number of times the statement was reached.
+	Reached int64
+	// Ignored indicates whether the statement is ignored by annotation.
+	Ignored bool
 }
 
 // ViolationSection represents a portion of the change that miss unit test coverage.
@@ -73,3 +187,17 @@ type ViolationSection struct {
 	// Contents contains [StartLine..EndLine] lines from the source file.
 	Contents []string
 }
+
+// IgnoredSection represents a portion of the file that is ignored by annotation.
+type IgnoredSection struct {
+	// Annotation is the concrete ignore annotation.
+	Annotation string
+	// Comments is the comments about the ignorance.
+	Comments string
+	// AnnotationLine indicates the line the annotation locates at, it's zero when the whole file is ignored.
+	AnnotationLine int
+	// StartLine indicates the start line of the section, it's zero when the whole file is ignored.
+	StartLine int
+	// EndLine indicates the end line of the section, it's zero when the whole file is ignored.
+	EndLine int
+}